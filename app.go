@@ -4,12 +4,17 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"time"
 
 	"wa-bot-wails/whatsapp"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
+// shutdownTimeout bounds how long the app waits for pending work on exit. It is the only
+// shutdown deadline: the WhatsApp manager drains within the context it is given.
+const shutdownTimeout = 15 * time.Second
+
 // App struct
 type App struct {
 	ctx       context.Context
@@ -44,6 +49,28 @@ func (a *App) startup(ctx context.Context) {
 	}
 }
 
+// beforeClose is called when the window is about to close. Returning false lets it close;
+// the frontend is told so it can show that pending work is being finished.
+func (a *App) beforeClose(ctx context.Context) bool {
+	runtime.EventsEmit(ctx, "app:shutting_down", "Finishing pending work before exit")
+	return false
+}
+
+// shutdown is called when the app is terminating. Pending auto-replies, scheduled
+// tasks and sends are drained for up to shutdownTimeout before everything is closed.
+func (a *App) shutdown(ctx context.Context) {
+	if a.waManager == nil {
+		return
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := a.waManager.Shutdown(shutdownCtx); err != nil {
+		log.Printf("WhatsApp manager shutdown: %v", err)
+	}
+}
+
 // GetContacts returns the list of WhatsApp contacts
 func (a *App) GetContacts() ([]whatsapp.Contact, error) {
 	if a.waManager == nil {
@@ -69,6 +96,36 @@ func (a *App) listenForWhatsAppEvents() {
 			runtime.EventsEmit(a.ctx, "whatsapp:disconnected", event.Message)
 		case "error":
 			runtime.EventsEmit(a.ctx, "whatsapp:error", event.Message)
+		case "code":
+			runtime.EventsEmit(a.ctx, "whatsapp:code", event.Data)
+		case "pair_success":
+			runtime.EventsEmit(a.ctx, "whatsapp:pair_success", event.Message)
+		case "pair_error":
+			runtime.EventsEmit(a.ctx, "whatsapp:pair_error", event.Message)
+		case "message":
+			runtime.EventsEmit(a.ctx, "whatsapp:message", event.Payload)
+		case "chat_update":
+			runtime.EventsEmit(a.ctx, "whatsapp:chat_update", event.Payload)
+		case "unread":
+			runtime.EventsEmit(a.ctx, "whatsapp:unread", event.Payload)
+		case "presence":
+			runtime.EventsEmit(a.ctx, "whatsapp:presence", event.Payload)
+		case "typing":
+			runtime.EventsEmit(a.ctx, "whatsapp:typing", event.Payload)
+		case "group":
+			runtime.EventsEmit(a.ctx, "whatsapp:group", event.Payload)
+		case "history_sync":
+			runtime.EventsEmit(a.ctx, "whatsapp:history_sync", event.Payload)
+		case "logged_out":
+			runtime.EventsEmit(a.ctx, "whatsapp:logged_out", event.Message)
+		case "draft":
+			runtime.EventsEmit(a.ctx, "whatsapp:draft", event.Payload)
+		case "autoreply_pause":
+			runtime.EventsEmit(a.ctx, "whatsapp:autoreply_pause", event.Payload)
+		case "transcript":
+			runtime.EventsEmit(a.ctx, "whatsapp:transcript", event.Payload)
+		case "budget_exceeded":
+			runtime.EventsEmit(a.ctx, "whatsapp:budget_exceeded", event.Payload)
 		}
 	}
 }
@@ -119,6 +176,16 @@ func (a *App) RequestPairingCode(phoneNumber string) (string, error) {
 	return a.waManager.RequestPairingCode(phoneNumber)
 }
 
+// CancelPairing aborts a pending phone number pairing attempt
+func (a *App) CancelPairing() error {
+	if a.waManager == nil {
+		return fmt.Errorf("WhatsApp manager not initialized")
+	}
+
+	a.waManager.CancelPairing()
+	return nil
+}
+
 // GetConnectionStatus returns current connection status
 func (a *App) GetConnectionStatus() *whatsapp.ConnectionStatus {
 	if a.waManager == nil {
@@ -137,6 +204,42 @@ func (a *App) DisconnectWhatsApp() error {
 	return a.waManager.Disconnect()
 }
 
+// LogoutWhatsApp unlinks the current device from the phone and deletes its local credentials
+func (a *App) LogoutWhatsApp(purgeMessages bool) error {
+	if a.waManager == nil {
+		return fmt.Errorf("WhatsApp manager not initialized")
+	}
+
+	return a.waManager.Logout(purgeMessages)
+}
+
+// ListDevices returns all linked devices stored locally
+func (a *App) ListDevices() ([]whatsapp.DeviceInfo, error) {
+	if a.waManager == nil {
+		return nil, fmt.Errorf("WhatsApp manager not initialized")
+	}
+
+	return a.waManager.ListDevices()
+}
+
+// SwitchDevice connects a different stored device
+func (a *App) SwitchDevice(deviceJID string) error {
+	if a.waManager == nil {
+		return fmt.Errorf("WhatsApp manager not initialized")
+	}
+
+	return a.waManager.SwitchDevice(deviceJID)
+}
+
+// LogoutDevice unlinks a stored device and deletes its local credentials
+func (a *App) LogoutDevice(deviceJID string, purgeMessages bool) error {
+	if a.waManager == nil {
+		return fmt.Errorf("WhatsApp manager not initialized")
+	}
+
+	return a.waManager.LogoutDevice(deviceJID, purgeMessages)
+}
+
 // GetConnectionEvents returns a channel for connection events
 func (a *App) GetConnectionEvents() <-chan whatsapp.ConnectionEvent {
 	if a.waManager == nil {
@@ -155,6 +258,49 @@ func (a *App) GetChats() ([]whatsapp.Chat, error) {
 	return a.waManager.GetChats()
 }
 
+// GetMessages returns the latest messages of a chat, newest first
+func (a *App) GetMessages(chatID string, limit int) ([]whatsapp.Message, error) {
+	if a.waManager == nil {
+		return nil, fmt.Errorf("WhatsApp manager not initialized")
+	}
+
+	return a.waManager.GetMessages(chatID, limit)
+}
+
+// MarkChatAsRead resets the unread counter of a chat
+func (a *App) MarkChatAsRead(chatID string) error {
+	if a.waManager == nil {
+		return fmt.Errorf("WhatsApp manager not initialized")
+	}
+
+	return a.waManager.MarkChatAsRead(chatID)
+}
+
+// SubscribePresence subscribes to online/last seen updates of a contact
+func (a *App) SubscribePresence(jid string) error {
+	if a.waManager == nil {
+		return fmt.Errorf("WhatsApp manager not initialized")
+	}
+
+	return a.waManager.SubscribePresence(jid)
+}
+
+// GetHistorySyncConfig gets the history sync limits
+func (a *App) GetHistorySyncConfig() (*whatsapp.HistorySyncConfig, error) {
+	if a.waManager == nil {
+		return nil, fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.GetHistorySyncConfig(), nil
+}
+
+// UpdateHistorySyncConfig updates the history sync limits
+func (a *App) UpdateHistorySyncConfig(config *whatsapp.HistorySyncConfig) error {
+	if a.waManager == nil {
+		return fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.UpdateHistorySyncConfig(config)
+}
+
 // Auto-reply methods
 
 // GetAutoReplyConfig gets the current auto-reply configuration
@@ -173,6 +319,302 @@ func (a *App) UpdateAutoReplyConfig(config *whatsapp.AutoReplyConfig) error {
 	return a.waManager.UpdateAutoReplyConfig(config)
 }
 
+// GetAutoReplyMode returns whether auto-reply is currently "open", "closed" or on "vacation"
+func (a *App) GetAutoReplyMode() (string, error) {
+	if a.waManager == nil {
+		return "", fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.GetAutoReplyMode(), nil
+}
+
+// GetAIProviders returns the names of the available AI providers
+func (a *App) GetAIProviders() ([]string, error) {
+	if a.waManager == nil {
+		return nil, fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.GetAIProviders(), nil
+}
+
+// TestAIConnection sends a test message to an AI provider and returns its reply
+func (a *App) TestAIConnection(provider string) (string, error) {
+	if a.waManager == nil {
+		return "", fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.TestAIConnection(provider)
+}
+
+// GetReplyDrafts returns the AI reply drafts with the given status, all drafts if empty
+func (a *App) GetReplyDrafts(status string) ([]whatsapp.ReplyDraft, error) {
+	if a.waManager == nil {
+		return nil, fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.GetReplyDrafts(status)
+}
+
+// ApproveReplyDraft sends a pending draft, with the edited text if not empty
+func (a *App) ApproveReplyDraft(id, text string) error {
+	if a.waManager == nil {
+		return fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.ApproveReplyDraft(id, text)
+}
+
+// RejectReplyDraft discards a pending draft
+func (a *App) RejectReplyDraft(id string) error {
+	if a.waManager == nil {
+		return fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.RejectReplyDraft(id)
+}
+
+// GetReplyProfiles returns the per-contact auto-reply profiles
+func (a *App) GetReplyProfiles() ([]whatsapp.ReplyProfile, error) {
+	if a.waManager == nil {
+		return nil, fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.GetReplyProfiles()
+}
+
+// SaveReplyProfile creates a reply profile, or updates it when the ID is set
+func (a *App) SaveReplyProfile(profile *whatsapp.ReplyProfile) (*whatsapp.ReplyProfile, error) {
+	if a.waManager == nil {
+		return nil, fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.SaveReplyProfile(profile)
+}
+
+// DeleteReplyProfile removes a reply profile
+func (a *App) DeleteReplyProfile(id int64) error {
+	if a.waManager == nil {
+		return fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.DeleteReplyProfile(id)
+}
+
+// GetContactTags returns the tags of a contact, used to assign reply profiles
+func (a *App) GetContactTags(jid string) ([]string, error) {
+	if a.waManager == nil {
+		return nil, fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.GetContactTags(jid)
+}
+
+// SetContactTags replaces the tags of a contact
+func (a *App) SetContactTags(jid string, tags []string) error {
+	if a.waManager == nil {
+		return fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.SetContactTags(jid, tags)
+}
+
+// GetReplyRules returns the keyword and regex reply rules
+func (a *App) GetReplyRules() ([]whatsapp.ReplyRule, error) {
+	if a.waManager == nil {
+		return nil, fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.GetReplyRules()
+}
+
+// SaveReplyRule creates a reply rule, or updates it when the ID is set
+func (a *App) SaveReplyRule(rule *whatsapp.ReplyRule) (*whatsapp.ReplyRule, error) {
+	if a.waManager == nil {
+		return nil, fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.SaveReplyRule(rule)
+}
+
+// DeleteReplyRule removes a reply rule
+func (a *App) DeleteReplyRule(id int64) error {
+	if a.waManager == nil {
+		return fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.DeleteReplyRule(id)
+}
+
+// SendMessage sends a message typed in the app, pausing auto-reply in the chat
 func (a *App) SendMessage(chatID, text string) error {
-	return a.waManager.SendMessage(chatID, text)
+	if a.waManager == nil {
+		return fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.SendManualMessage(chatID, text)
+}
+
+// PauseAutoReply suspends auto-reply in a chat for the given minutes, 0 until resumed
+func (a *App) PauseAutoReply(chatID string, minutes int) error {
+	if a.waManager == nil {
+		return fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.PauseAutoReply(chatID, minutes, "operator")
+}
+
+// ResumeAutoReply lifts the auto-reply pause of a chat
+func (a *App) ResumeAutoReply(chatID string) error {
+	if a.waManager == nil {
+		return fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.ResumeAutoReply(chatID)
+}
+
+// GetPausedChats returns the chats where auto-reply is suspended
+func (a *App) GetPausedChats() ([]whatsapp.ChatPause, error) {
+	if a.waManager == nil {
+		return nil, fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.GetPausedChats()
+}
+
+// ImportKnowledgeFiles lets the user pick documents and adds them to the knowledge base
+func (a *App) ImportKnowledgeFiles() ([]whatsapp.KnowledgeDocument, error) {
+	if a.waManager == nil {
+		return nil, fmt.Errorf("WhatsApp manager not initialized")
+	}
+
+	paths, err := runtime.OpenMultipleFilesDialog(a.ctx, runtime.OpenDialogOptions{
+		Title: "Import knowledge base documents",
+		Filters: []runtime.FileFilter{
+			{DisplayName: "Documents (*.md, *.txt, *.pdf, *.csv)", Pattern: "*.md;*.markdown;*.txt;*.pdf;*.csv"},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file dialog: %v", err)
+	}
+
+	docs := make([]whatsapp.KnowledgeDocument, 0, len(paths))
+	for _, path := range paths {
+		doc, err := a.waManager.ImportKnowledgeFile(path)
+		if err != nil {
+			return docs, err
+		}
+		docs = append(docs, *doc)
+	}
+	return docs, nil
+}
+
+// GetKnowledgeDocuments returns the documents of the knowledge base
+func (a *App) GetKnowledgeDocuments() ([]whatsapp.KnowledgeDocument, error) {
+	if a.waManager == nil {
+		return nil, fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.GetKnowledgeDocuments()
+}
+
+// DeleteKnowledgeDocument removes a document from the knowledge base
+func (a *App) DeleteKnowledgeDocument(id int64) error {
+	if a.waManager == nil {
+		return fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.DeleteKnowledgeDocument(id)
+}
+
+// SearchKnowledge returns the knowledge base chunks that match a query
+func (a *App) SearchKnowledge(query string) ([]whatsapp.KnowledgeMatch, error) {
+	if a.waManager == nil {
+		return nil, fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.SearchKnowledge(query)
+}
+
+// GetReplyLog returns the latest AI replies with the documents they cited
+func (a *App) GetReplyLog(chatID string, limit int) ([]whatsapp.ReplyLogEntry, error) {
+	if a.waManager == nil {
+		return nil, fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.GetReplyLog(chatID, limit)
+}
+
+// GetToolAuditLog returns the latest tool calls made by the assistant
+func (a *App) GetToolAuditLog(chatID string, limit int) ([]whatsapp.ToolAuditEntry, error) {
+	if a.waManager == nil {
+		return nil, fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.GetToolAuditLog(chatID, limit)
+}
+
+// GetUsageReport returns the AI usage between from and to (unix seconds), grouped by
+// "day", "model", "provider" or "chat"
+func (a *App) GetUsageReport(from, to int64, groupBy string) (*whatsapp.UsageReport, error) {
+	if a.waManager == nil {
+		return nil, fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.GetUsageReport(from, to, groupBy)
+}
+
+// GetUsageStatus returns the AI spending of the current day and month against the budgets
+func (a *App) GetUsageStatus() (*whatsapp.UsageStatus, error) {
+	if a.waManager == nil {
+		return nil, fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.GetUsageStatus()
+}
+
+// GetUsageLog returns the latest AI calls with their tokens, latency and cost
+func (a *App) GetUsageLog(chatID string, limit int) ([]whatsapp.UsageEntry, error) {
+	if a.waManager == nil {
+		return nil, fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.GetUsageLog(chatID, limit)
+}
+
+// GetReplyDecisions returns the latest auto-reply decisions of a chat, or of all chats
+func (a *App) GetReplyDecisions(chatID string, limit int) ([]whatsapp.ReplyDecision, error) {
+	if a.waManager == nil {
+		return nil, fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.GetReplyDecisions(chatID, limit)
+}
+
+// ReplayMessage shows how auto-reply would answer a stored message now, without sending
+func (a *App) ReplayMessage(messageID string) (*whatsapp.ReplyDecision, error) {
+	if a.waManager == nil {
+		return nil, fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.ReplayMessage(messageID)
+}
+
+// GetProviderHealth returns the failure counts and circuit breaker state of the AI providers
+func (a *App) GetProviderHealth() ([]whatsapp.ProviderHealth, error) {
+	if a.waManager == nil {
+		return nil, fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.GetProviderHealth()
+}
+
+// GetChatMemory returns the summary and key facts remembered about a chat
+func (a *App) GetChatMemory(chatJID string) (*whatsapp.ChatMemory, error) {
+	if a.waManager == nil {
+		return nil, fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.GetChatMemory(chatJID)
+}
+
+// UpdateChatMemory replaces the summary and key facts remembered about a chat
+func (a *App) UpdateChatMemory(chatJID, summary string, facts []string) (*whatsapp.ChatMemory, error) {
+	if a.waManager == nil {
+		return nil, fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.UpdateChatMemory(chatJID, summary, facts)
+}
+
+// RefreshChatMemory updates the memory of a chat with its latest messages
+func (a *App) RefreshChatMemory(chatJID string) (*whatsapp.ChatMemory, error) {
+	if a.waManager == nil {
+		return nil, fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.RefreshChatMemory(chatJID)
+}
+
+// DeleteChatMemory makes the assistant forget a chat
+func (a *App) DeleteChatMemory(chatJID string) error {
+	if a.waManager == nil {
+		return fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.DeleteChatMemory(chatJID)
+}
+
+// GetIntentStats returns how many incoming messages of the last days were classified with each intent
+func (a *App) GetIntentStats(days int) ([]whatsapp.IntentCount, error) {
+	if a.waManager == nil {
+		return nil, fmt.Errorf("WhatsApp manager not initialized")
+	}
+	return a.waManager.GetIntentStats(days)
 }
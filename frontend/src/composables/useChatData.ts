@@ -56,6 +56,29 @@ EventsOn('whatsapp:error', (message: string) => {
   connectionStatus.value = 'disconnected'
 })
 
+EventsOn('whatsapp:logged_out', (message: string) => {
+  console.log('🚪 WhatsApp logged out:', message)
+  connectionStatus.value = 'disconnected'
+  isLinked.value = false
+  chats.value = []
+})
+
+// Listen for phone number pairing events
+EventsOn('whatsapp:code', (code: string) => {
+  pairingCode.value = code
+})
+
+EventsOn('whatsapp:pair_success', (message: string) => {
+  console.log('✅ Pairing succeeded:', message)
+  pairingCode.value = ''
+})
+
+EventsOn('whatsapp:pair_error', (message: string) => {
+  console.error('❌ Pairing failed:', message)
+  pairingCode.value = ''
+  if (!isLinked.value) connectionStatus.value = 'disconnected'
+})
+
 console.log('✅ WhatsApp event listeners setup complete')
 
 // Listen for test startup event
@@ -144,15 +167,95 @@ interface FrontendChat {
 
 interface FrontendMessage {
   id: number
+  messageId?: string
   author: string
   text: string
   time: string
   mine: boolean
   type?: string
+  transcript?: string
 }
 
 const chats = ref<FrontendChat[]>([])
 const messagesByChat = reactive<Record<number, FrontendMessage[]>>({})
+const typingByChat = reactive<Record<string, boolean>>({})
+const presenceByJid = reactive<Record<string, { isOnline: boolean, lastSeen?: string }>>({})
+const historySyncProgress = ref(0)
+
+// ---- REALTIME EVENTS ----
+EventsOn('whatsapp:message', (msg: whatsapp.Message) => {
+  const chat = chats.value.find(c => c.chatId === msg.chatId)
+  if (!chat) return
+  if (!messagesByChat[chat.id]) {
+    if (chat.id !== selectedId.value) return
+    messagesByChat[chat.id] = []
+  }
+  const list = messagesByChat[chat.id]
+  // Messages are kept newest first, like GetMessages returns them
+  list.unshift({
+    id: Date.now(),
+    messageId: msg.id,
+    author: msg.author,
+    text: msg.text,
+    time: msg.time,
+    mine: msg.mine,
+    type: msg.type,
+    transcript: msg.transcript
+  })
+})
+
+EventsOn('whatsapp:transcript', (update: { chatId: string, messageId: string, text: string }) => {
+  const chat = chats.value.find(c => c.chatId === update.chatId)
+  const message = chat && messagesByChat[chat.id]?.find(m => m.messageId === update.messageId)
+  if (message) message.transcript = update.text
+})
+
+EventsOn('whatsapp:chat_update', (chat: whatsapp.Chat) => {
+  const existing = chats.value.find(c => c.chatId === chat.id)
+  if (existing) {
+    existing.name = chat.name
+    existing.last = chat.last
+    existing.time = chat.time
+    existing.unread = existing.id === selectedId.value ? 0 : chat.unread
+    // Move the updated chat to the top of the list
+    chats.value = [existing, ...chats.value.filter(c => c !== existing)]
+    return
+  }
+  const nextId = chats.value.reduce((max, c) => Math.max(max, c.id), 0) + 1
+  chats.value.unshift({
+    id: nextId,
+    chatId: chat.id,
+    name: chat.name,
+    last: chat.last,
+    time: chat.time,
+    unread: chat.unread,
+    isGroup: chat.isGroup
+  })
+})
+
+EventsOn('whatsapp:unread', (update: { chatId: string, unread: number }) => {
+  const chat = chats.value.find(c => c.chatId === update.chatId)
+  if (chat && chat.id !== selectedId.value) chat.unread = update.unread
+})
+
+EventsOn('whatsapp:typing', (update: { chatId: string, isTyping: boolean }) => {
+  typingByChat[update.chatId] = update.isTyping
+})
+
+EventsOn('whatsapp:presence', (update: { jid: string, isOnline: boolean, lastSeen?: string }) => {
+  presenceByJid[update.jid] = { isOnline: update.isOnline, lastSeen: update.lastSeen }
+})
+
+EventsOn('whatsapp:history_sync', (progress: whatsapp.HistorySyncProgress) => {
+  historySyncProgress.value = progress.progress
+  // Reload the chat list so imported conversations show up
+  loadChats()
+})
+
+EventsOn('whatsapp:group', (update: { chatId: string, name?: string }) => {
+  const chat = chats.value.find(c => c.chatId === update.chatId)
+  if (chat && update.name) chat.name = update.name
+})
 
 // Load chats from backend
 async function loadChats() {
@@ -204,11 +307,13 @@ async function loadMessages(chatId) {
     // Transform backend data to frontend format
     const messages = backendMessages.map((msg, index) => ({
       id: index + 1,
+      messageId: msg.id,
       author: msg.author,
       text: msg.text,
       time: msg.time,
       mine: msg.mine,
-      type: msg.type
+      type: msg.type,
+      transcript: msg.transcript
     }))
     
     messagesByChat[chatId] = messages
@@ -254,25 +359,10 @@ async function sendMessage() {
   const messageText = draft.value
   const time = new Date().toLocaleTimeString('id-ID', { hour: '2-digit', minute: '2-digit' })
   
-  // Create message object for frontend
-  const newMessage: FrontendMessage = {
-    id: Date.now(),
-    author: 'Me',
-    text: messageText,
-    time: time,
-    mine: true
-  }
-  
   try {
-    // Send message to backend
+    // Send message to backend; the sent message comes back through the whatsapp:message event
     await SendMessage(activeChat.value.chatId, messageText)
     
-    // Update frontend state
-    if (!messagesByChat[selectedId.value]) {
-      messagesByChat[selectedId.value] = []
-    }
-    messagesByChat[selectedId.value].push(newMessage)
-    
     // Update chat preview
     const chat = chats.value.find(c => c.id === selectedId.value)
     if (chat) {
@@ -302,6 +392,9 @@ export function useChatData(){
     phoneNumber,
     chats, 
     messagesByChat, 
+    typingByChat,
+    presenceByJid,
+    historySyncProgress,
     selectedId, 
     q, 
     draft, 
@@ -0,0 +1,378 @@
+package whatsapp
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Approval modes of AI replies
+const (
+	ApprovalAuto       = "auto"       // replies are sent directly
+	ApprovalSupervised = "supervised" // replies wait in the approval queue
+)
+
+// Draft statuses
+const (
+	DraftPending  = "pending"
+	DraftSending  = "sending"
+	DraftSent     = "sent"
+	DraftRejected = "rejected"
+)
+
+// ApprovalConfig controls whether AI replies need the operator's approval
+type ApprovalConfig struct {
+	Mode          string            `json:"mode"`            // default mode, "auto" or "supervised"
+	AutoSendAfter int               `json:"auto_send_after"` // seconds until a pending draft is sent anyway, 0 waits forever
+	ContactModes  map[string]string `json:"contact_modes"`   // phone number -> mode, overrides Mode
+}
+
+// GetDefaultApprovalConfig returns the default approval settings, sending replies directly
+func GetDefaultApprovalConfig() ApprovalConfig {
+	return ApprovalConfig{
+		Mode:         ApprovalAuto,
+		ContactModes: map[string]string{},
+	}
+}
+
+// Validate checks the approval modes
+func (c *ApprovalConfig) Validate() error {
+	if c.Mode == "" {
+		c.Mode = ApprovalAuto
+	}
+	if c.Mode != ApprovalAuto && c.Mode != ApprovalSupervised {
+		return fmt.Errorf("unsupported approval mode: %s", c.Mode)
+	}
+	for number, mode := range c.ContactModes {
+		if mode != ApprovalAuto && mode != ApprovalSupervised {
+			return fmt.Errorf("unsupported approval mode for %s: %s", number, mode)
+		}
+	}
+	if c.AutoSendAfter < 0 {
+		return fmt.Errorf("auto-send timeout cannot be negative")
+	}
+	return nil
+}
+
+// supervised reports whether replies to the contact need approval
+func (c *ApprovalConfig) supervised(phoneNumber string) bool {
+	if mode, ok := c.ContactModes[phoneNumber]; ok {
+		return mode == ApprovalSupervised
+	}
+	return c.Mode == ApprovalSupervised
+}
+
+// ReplyDraft is an AI reply waiting for the operator's approval
+type ReplyDraft struct {
+	ID        string `json:"id"`
+	ChatJID   string `json:"chat_jid"`
+	ChatName  string `json:"chat_name"`
+	MessageID string `json:"message_id"` // incoming message the draft answers
+	Question  string `json:"question"`
+	Text      string `json:"text"`
+	Status    string `json:"status"`
+	CreatedAt int64  `json:"created_at"`
+	ExpiresAt int64  `json:"expires_at"` // auto-send time, 0 if never
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// Backoff of auto-sends that failed, e.g. while WhatsApp is not connected
+const (
+	autoSendRetryDelay    = 30 * time.Second
+	autoSendMaxRetryDelay = 10 * time.Minute
+)
+
+// ApprovalQueue holds AI replies until they are approved, rejected or auto-sent
+type ApprovalQueue struct {
+	manager *Manager
+
+	mu      sync.Mutex
+	timers  map[string]*time.Timer
+	stopped bool
+	sending sync.WaitGroup // auto-sends in progress, registered under mu
+}
+
+// NewApprovalQueue creates an approval queue sending through the manager
+func NewApprovalQueue(manager *Manager) *ApprovalQueue {
+	return &ApprovalQueue{
+		manager: manager,
+		timers:  make(map[string]*time.Timer),
+	}
+}
+
+// Restore puts drafts whose sending was interrupted by a crash back in the queue. Their
+// auto-send timers are armed by Resume once WhatsApp is connected.
+func (q *ApprovalQueue) Restore() error {
+	drafts, err := q.manager.messageDB.GetReplyDrafts(DraftSending)
+	if err != nil {
+		return err
+	}
+
+	for _, draft := range drafts {
+		if _, err := q.manager.messageDB.UpdateReplyDraftStatus(draft.ID, DraftSending, DraftPending, draft.Text); err != nil {
+			q.manager.log.Errorf("Failed to restore draft %s: %v", draft.ID, err)
+		}
+	}
+	return nil
+}
+
+// Resume arms the auto-send timers of pending drafts that have none, e.g. after a restart.
+// It is called when WhatsApp connects, so overdue drafts are not sent before they can be.
+func (q *ApprovalQueue) Resume() {
+	drafts, err := q.manager.messageDB.GetReplyDrafts(DraftPending)
+	if err != nil {
+		q.manager.log.Errorf("Failed to load pending drafts: %v", err)
+		return
+	}
+
+	for i := range drafts {
+		q.mu.Lock()
+		_, armed := q.timers[drafts[i].ID]
+		q.mu.Unlock()
+		if !armed {
+			q.schedule(&drafts[i])
+		}
+	}
+}
+
+// Add queues a new draft and notifies the frontend
+func (q *ApprovalQueue) Add(draft *ReplyDraft, autoSendAfter time.Duration) error {
+	now := time.Now()
+	draft.ID = fmt.Sprintf("draft_%d", now.UnixNano())
+	draft.Status = DraftPending
+	draft.CreatedAt = now.Unix()
+	draft.UpdatedAt = now.Unix()
+	if autoSendAfter > 0 {
+		draft.ExpiresAt = now.Add(autoSendAfter).Unix()
+	}
+
+	if err := q.manager.messageDB.SaveReplyDraft(draft); err != nil {
+		return err
+	}
+
+	q.schedule(draft)
+	q.notify(draft)
+	return nil
+}
+
+// Approve sends the draft, replacing its text when text is not empty
+func (q *ApprovalQueue) Approve(id, text string) error {
+	draft, err := q.manager.messageDB.GetReplyDraft(id)
+	if err != nil {
+		return err
+	}
+	if text != "" {
+		draft.Text = text
+	}
+
+	// Claim the draft so a concurrent approve or auto-send doesn't send it twice
+	claimed, err := q.manager.messageDB.UpdateReplyDraftStatus(id, DraftPending, DraftSending, draft.Text)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return fmt.Errorf("draft %s is no longer pending", id)
+	}
+	q.cancelTimer(id)
+
+	if sent, err := q.manager.sendReply(q.manager.autoReply.GetConfig(), draft.ChatJID, draft.Text); err != nil {
+		if len(sent) == 0 {
+			// Nothing went out, put it back so the operator can retry
+			if _, revertErr := q.manager.messageDB.UpdateReplyDraftStatus(id, DraftSending, DraftPending, draft.Text); revertErr != nil {
+				q.manager.log.Errorf("Failed to restore draft %s: %v", id, revertErr)
+			}
+			return err
+		}
+		// Part of the reply was delivered, retrying would send it twice
+		q.manager.log.Errorf("Draft %s was only partly sent: %v", id, err)
+	}
+
+	if _, err := q.manager.messageDB.UpdateReplyDraftStatus(id, DraftSending, DraftSent, draft.Text); err != nil {
+		q.manager.log.Errorf("Failed to mark draft %s as sent: %v", id, err)
+	}
+	draft.Status = DraftSent
+	q.notify(draft)
+	return nil
+}
+
+// Reject discards the draft
+func (q *ApprovalQueue) Reject(id string) error {
+	draft, err := q.manager.messageDB.GetReplyDraft(id)
+	if err != nil {
+		return err
+	}
+
+	claimed, err := q.manager.messageDB.UpdateReplyDraftStatus(id, DraftPending, DraftRejected, draft.Text)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return fmt.Errorf("draft %s is no longer pending", id)
+	}
+	q.cancelTimer(id)
+
+	draft.Status = DraftRejected
+	q.notify(draft)
+	return nil
+}
+
+// Stop cancels all auto-send timers and waits for the auto-sends already running.
+// Pending drafts stay queued for the next run.
+func (q *ApprovalQueue) Stop() {
+	q.mu.Lock()
+	q.stopped = true
+	q.clearTimers()
+	q.mu.Unlock()
+
+	q.sending.Wait()
+}
+
+// Clear cancels all auto-send timers, e.g. before the drafts are purged
+func (q *ApprovalQueue) Clear() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.clearTimers()
+}
+
+// clearTimers stops and forgets all timers. q.mu must be held.
+func (q *ApprovalQueue) clearTimers() {
+	for id, timer := range q.timers {
+		timer.Stop()
+		delete(q.timers, id)
+	}
+}
+
+// schedule arms the auto-send timer of a draft
+func (q *ApprovalQueue) schedule(draft *ReplyDraft) {
+	if draft.ExpiresAt == 0 {
+		return
+	}
+
+	delay := time.Until(time.Unix(draft.ExpiresAt, 0))
+	if delay < 0 {
+		delay = 0
+	}
+	q.arm(draft.ID, delay, 0)
+}
+
+// arm starts the auto-send timer of a draft, unless the queue was stopped
+func (q *ApprovalQueue) arm(id string, delay time.Duration, attempt int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.stopped {
+		return
+	}
+	if timer, ok := q.timers[id]; ok {
+		timer.Stop()
+	}
+
+	q.timers[id] = time.AfterFunc(delay, func() {
+		q.mu.Lock()
+		delete(q.timers, id)
+		if q.stopped {
+			q.mu.Unlock()
+			return
+		}
+		q.sending.Add(1)
+		q.mu.Unlock()
+		defer q.sending.Done()
+
+		q.autoSend(id, attempt)
+	})
+}
+
+// autoSend sends a draft whose auto-send time has come. If it could not be sent and is
+// still pending, the send is retried with a growing delay. Drafts of chats the operator
+// took over are discarded instead.
+func (q *ApprovalQueue) autoSend(id string, attempt int) {
+	if draft, err := q.manager.messageDB.GetReplyDraft(id); err == nil && q.manager.isAutoReplyPaused(draft.ChatJID) {
+		if err := q.Reject(id); err != nil {
+			fmt.Printf("Failed to discard draft %s of a paused chat: %v\n", id, err)
+		}
+		return
+	}
+
+	err := q.Approve(id, "")
+	if err == nil {
+		return
+	}
+
+	draft, getErr := q.manager.messageDB.GetReplyDraft(id)
+	if getErr != nil || draft.Status != DraftPending {
+		fmt.Printf("Failed to auto-send draft %s: %v\n", id, err)
+		return
+	}
+
+	delay := autoSendRetryDelay << attempt
+	if delay > autoSendMaxRetryDelay || delay <= 0 {
+		delay = autoSendMaxRetryDelay
+	}
+	fmt.Printf("Failed to auto-send draft %s, retrying in %v: %v\n", id, delay, err)
+	q.arm(id, delay, attempt+1)
+}
+
+// cancelTimer stops the auto-send timer of a draft
+func (q *ApprovalQueue) cancelTimer(id string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if timer, ok := q.timers[id]; ok {
+		timer.Stop()
+		delete(q.timers, id)
+	}
+}
+
+// notify tells the frontend that a draft was added or changed
+func (q *ApprovalQueue) notify(draft *ReplyDraft) {
+	q.manager.emitEvent(ConnectionEvent{
+		Type:    "draft",
+		Message: "Reply draft " + draft.Status,
+		Payload: *draft,
+	})
+}
+
+// Approval queue methods
+
+// GetReplyDrafts returns the drafts with the given status, all drafts if status is empty
+func (m *Manager) GetReplyDrafts(status string) ([]ReplyDraft, error) {
+	if m.messageDB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	return m.messageDB.GetReplyDrafts(status)
+}
+
+// ApproveReplyDraft sends a pending draft, optionally with edited text
+func (m *Manager) ApproveReplyDraft(id, text string) error {
+	if m.approvals == nil {
+		return fmt.Errorf("approval queue not initialized")
+	}
+	return m.approvals.Approve(id, text)
+}
+
+// RejectReplyDraft discards a pending draft
+func (m *Manager) RejectReplyDraft(id string) error {
+	if m.approvals == nil {
+		return fmt.Errorf("approval queue not initialized")
+	}
+	return m.approvals.Reject(id)
+}
+
+// queueReplyDraft puts an AI reply into the approval queue instead of sending it
+func (m *Manager) queueReplyDraft(chatJID, messageID, question, text string, autoSendAfter time.Duration) error {
+	if m.approvals == nil || m.messageDB == nil {
+		return fmt.Errorf("approval queue not initialized")
+	}
+
+	chatName := m.getContactName(chatJID)
+	if chat, err := m.messageDB.GetChat(chatJID); err == nil && chat != nil && chat.Name != "" {
+		chatName = chat.Name
+	}
+
+	return m.approvals.Add(&ReplyDraft{
+		ChatJID:   chatJID,
+		ChatName:  chatName,
+		MessageID: messageID,
+		Question:  question,
+		Text:      text,
+	}, autoSendAfter)
+}
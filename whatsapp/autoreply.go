@@ -1,15 +1,15 @@
 package whatsapp
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	waProto "go.mau.fi/whatsmeow/binary/proto"
 	"go.mau.fi/whatsmeow/types"
 	"go.mau.fi/whatsmeow/types/events"
 )
@@ -17,30 +17,152 @@ import (
 // AutoReplyConfig holds configuration for auto-reply feature
 type AutoReplyConfig struct {
 	Enabled           bool     `json:"enabled"`
-	AIProvider        string   `json:"ai_provider"` // "openai" or "ollama"
+	AIProvider        string   `json:"ai_provider"` // "openai", "ollama", "openai_compatible", "anthropic", "gemini"
 	OpenAIAPIKey      string   `json:"openai_api_key"`
 	OpenAIModel       string   `json:"openai_model"`
 	OllamaURL         string   `json:"ollama_url"`
 	OllamaModel       string   `json:"ollama_model"`
 	WhitelistNumbers  []string `json:"whitelist_numbers"`
 	SystemPrompt      string   `json:"system_prompt"`
-	ResponseDelay     int      `json:"response_delay"` // seconds
-	MaxResponseLength int      `json:"max_response_length"`
+	ResponseDelay     int      `json:"response_delay"`      // seconds
+	MaxResponseLength int      `json:"max_response_length"` // characters per message, longer replies are split
+	MaxReplyParts     int      `json:"max_reply_parts"`     // messages per reply, 0 is unlimited
+
+	// Conversation memory: previous turns of the chat sent along with the new message
+	ContextTurns       int `json:"context_turns"`        // 0 disables history
+	ContextTokenBudget int `json:"context_token_budget"` // 0 means no limit
+
+	// Settings of the additional providers
+	OpenAICompatible ProviderSettings `json:"openai_compatible"` // LM Studio, vLLM, llama.cpp server
+	Anthropic        ProviderSettings `json:"anthropic"`
+	Gemini           ProviderSettings `json:"gemini"`
+
+	// Opening hours, away message and vacation window
+	BusinessHours BusinessHoursConfig `json:"business_hours"`
+
+	// Opt-in replies in group chats
+	GroupReply GroupReplyConfig `json:"group_reply"`
+
+	// Operator approval of AI replies before they are sent
+	Approval ApprovalConfig `json:"approval"`
+
+	// Pausing auto-reply in chats we answer ourselves
+	Takeover TakeoverConfig `json:"takeover"`
+
+	// Rate limits, loop detection, debouncing and concurrency cap
+	Flood FloodConfig `json:"flood"`
+
+	// Documents retrieved to ground AI replies
+	Knowledge KnowledgeConfig `json:"knowledge"`
+
+	// Function calling with built-in and HTTP tools
+	Tools ToolsConfig `json:"tools"`
+
+	// Speech-to-text of incoming voice notes
+	Transcription TranscriptionConfig `json:"transcription"`
+
+	// Images passed to vision-capable models
+	Vision VisionConfig `json:"vision"`
+
+	// Price table and budgets of AI calls
+	Usage UsageConfig `json:"usage"`
+
+	// Streamed generation and early sending of replies
+	Streaming StreamingConfig `json:"streaming"`
+
+	// Retries, fallback providers and circuit breaker of AI calls
+	Failover FailoverConfig `json:"failover"`
+
+	// Per-chat summaries and key facts added to the prompt
+	Memory MemoryConfig `json:"memory"`
+
+	// Intent classification and routing of incoming messages
+	Intents IntentConfig `json:"intents"`
 }
 
 // AutoReplyManager handles automatic replies using AI
 type AutoReplyManager struct {
-	config *AutoReplyConfig
-	client *http.Client
+	config   *AutoReplyConfig
+	client   *http.Client
+	rules    *RuleEngine
+	profiles *ProfileResolver
+	flood    *FloodGuard
+	breaker  *CircuitBreaker
+
+	// ctx is cancelled on shutdown to abort in-flight AI requests and delays
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	stopping atomic.Bool
+	stopMu   sync.Mutex // orders track against Stop, so no work is added while Stop waits
 }
 
 // NewAutoReplyManager creates a new auto-reply manager
 func NewAutoReplyManager(config *AutoReplyConfig) *AutoReplyManager {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &AutoReplyManager{
 		config: config,
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		rules:    NewRuleEngine(),
+		profiles: NewProfileResolver(),
+		flood:    NewFloodGuard(),
+		breaker:  NewCircuitBreaker(),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Stop stops accepting new messages and waits for pending replies until ctx expires.
+// Replies still running at the deadline are cancelled.
+func (arm *AutoReplyManager) Stop(ctx context.Context) error {
+	arm.stopMu.Lock()
+	arm.stopping.Store(true)
+	arm.stopMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		arm.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		arm.cancel()
+		return nil
+	case <-ctx.Done():
+	}
+
+	// Deadline reached, abort remaining work and give it a moment to unwind
+	arm.cancel()
+	select {
+	case <-done:
+		return nil
+	case <-time.After(2 * time.Second):
+		return fmt.Errorf("auto-reply did not stop in time")
+	}
+}
+
+// track adds a unit of background work that Stop waits for, returning false if the
+// manager is already stopping. The caller calls arm.wg.Done when the work is finished.
+func (arm *AutoReplyManager) track() bool {
+	arm.stopMu.Lock()
+	defer arm.stopMu.Unlock()
+	if arm.stopping.Load() {
+		return false
+	}
+	arm.wg.Add(1)
+	return true
+}
+
+// sleep waits for the given duration, returning false if the manager is shutting down
+func (arm *AutoReplyManager) sleep(d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-arm.ctx.Done():
+		return false
 	}
 }
 
@@ -53,14 +175,34 @@ func (arm *AutoReplyManager) UpdateConfig(config *AutoReplyConfig) {
 func (arm *AutoReplyManager) GetConfig() *AutoReplyConfig {
 	if arm.config == nil {
 		return &AutoReplyConfig{
-			Enabled:           false,
-			AIProvider:        "openai",
-			OpenAIModel:       "gpt-3.5-turbo",
-			OllamaURL:         "http://localhost:11434",
-			OllamaModel:       "llama2",
-			SystemPrompt:      "You are a helpful WhatsApp assistant. Keep responses concise and friendly.",
-			ResponseDelay:     2,
-			MaxResponseLength: 500,
+			Enabled:            false,
+			AIProvider:         "openai",
+			OpenAIModel:        "gpt-3.5-turbo",
+			OllamaURL:          "http://localhost:11434",
+			OllamaModel:        "llama2",
+			SystemPrompt:       "You are a helpful WhatsApp assistant. Keep responses concise and friendly.",
+			ResponseDelay:      2,
+			MaxResponseLength:  500,
+			MaxReplyParts:      3,
+			ContextTurns:       10,
+			ContextTokenBudget: 2000,
+			OpenAICompatible:   ProviderSettings{BaseURL: "http://localhost:1234/v1"},
+			Anthropic:          ProviderSettings{BaseURL: anthropicBaseURL, Model: "claude-3-5-haiku-latest"},
+			Gemini:             ProviderSettings{BaseURL: geminiBaseURL, Model: "gemini-1.5-flash"},
+			BusinessHours:      GetDefaultBusinessHoursConfig(),
+			GroupReply:         GetDefaultGroupReplyConfig(),
+			Approval:           GetDefaultApprovalConfig(),
+			Takeover:           GetDefaultTakeoverConfig(),
+			Flood:              GetDefaultFloodConfig(),
+			Knowledge:          GetDefaultKnowledgeConfig(),
+			Tools:              GetDefaultToolsConfig(),
+			Transcription:      GetDefaultTranscriptionConfig(),
+			Vision:             GetDefaultVisionConfig(),
+			Usage:              GetDefaultUsageConfig(),
+			Streaming:          GetDefaultStreamingConfig(),
+			Failover:           GetDefaultFailoverConfig(),
+			Memory:             GetDefaultMemoryConfig(),
+			Intents:            GetDefaultIntentConfig(),
 		}
 	}
 	return arm.config
@@ -69,375 +211,609 @@ func (arm *AutoReplyManager) GetConfig() *AutoReplyConfig {
 // GetDefaultAutoReplyConfig returns default auto-reply configuration
 func GetDefaultAutoReplyConfig() *AutoReplyConfig {
 	return &AutoReplyConfig{
-		Enabled:           false,
-		AIProvider:        "openai",
-		OpenAIModel:       "gpt-3.5-turbo",
-		OllamaURL:         "http://localhost:11434",
-		OllamaModel:       "llama2",
-		SystemPrompt:      "You are a helpful WhatsApp assistant. Keep responses concise and friendly.",
-		ResponseDelay:     2,
-		MaxResponseLength: 500,
+		Enabled:            false,
+		AIProvider:         "openai",
+		OpenAIModel:        "gpt-3.5-turbo",
+		OllamaURL:          "http://localhost:11434",
+		OllamaModel:        "llama2",
+		SystemPrompt:       "You are a helpful WhatsApp assistant. Keep responses concise and friendly.",
+		ResponseDelay:      2,
+		MaxResponseLength:  500,
+		MaxReplyParts:      3,
+		ContextTurns:       10,
+		ContextTokenBudget: 2000,
+		OpenAICompatible:   ProviderSettings{BaseURL: "http://localhost:1234/v1"},
+		Anthropic:          ProviderSettings{BaseURL: anthropicBaseURL, Model: "claude-3-5-haiku-latest"},
+		Gemini:             ProviderSettings{BaseURL: geminiBaseURL, Model: "gemini-1.5-flash"},
+		BusinessHours:      GetDefaultBusinessHoursConfig(),
+		GroupReply:         GetDefaultGroupReplyConfig(),
+		Approval:           GetDefaultApprovalConfig(),
+		Takeover:           GetDefaultTakeoverConfig(),
+		Flood:              GetDefaultFloodConfig(),
+		Knowledge:          GetDefaultKnowledgeConfig(),
+		Tools:              GetDefaultToolsConfig(),
+		Transcription:      GetDefaultTranscriptionConfig(),
+		Vision:             GetDefaultVisionConfig(),
+		Usage:              GetDefaultUsageConfig(),
+		Streaming:          GetDefaultStreamingConfig(),
+		Failover:           GetDefaultFailoverConfig(),
+		Memory:             GetDefaultMemoryConfig(),
+		Intents:            GetDefaultIntentConfig(),
+	}
+}
+
+// configSections maps the structured config sections to their storage name.
+// They are persisted as JSON so nested settings don't need their own tables.
+func (c *AutoReplyConfig) configSections() map[string]interface{} {
+	return map[string]interface{}{
+		"business_hours": &c.BusinessHours,
+		"group_reply":    &c.GroupReply,
+		"approval":       &c.Approval,
+		"takeover":       &c.Takeover,
+		"flood":          &c.Flood,
+		"knowledge":      &c.Knowledge,
+		"tools":          &c.Tools,
+		"transcription":  &c.Transcription,
+		"vision":         &c.Vision,
+		"usage":          &c.Usage,
+		"streaming":      &c.Streaming,
+		"failover":       &c.Failover,
+		"memory":         &c.Memory,
+		"intents":        &c.Intents,
 	}
 }
 
+// replyJob is an AI reply being prepared for an incoming message
+type replyJob struct {
+	config       *AutoReplyConfig // global config with the resolved profile applied
+	temperature  *float64
+	chatJID      string
+	sender       string // phone number of the sender
+	senderJID    string
+	messageID    string
+	question     string
+	conversation []ChatMessage
+	images       []*waProto.ImageMessage // attached for vision-capable models
+	decision     *ReplyDecision
+}
+
 // ProcessIncomingMessage processes incoming messages and generates AI responses with retry logic
 func (arm *AutoReplyManager) ProcessIncomingMessage(evt *events.Message, manager *Manager) error {
-	if !arm.shouldReply(evt) {
+	if arm.stopping.Load() || arm.config == nil {
 		return nil
 	}
 
-	// Extract message text
-	messageText := arm.extractMessageText(evt)
-	if messageText == "" {
+	// Messages we send from the phone or another device hand the chat over to us
+	if evt.Info.IsFromMe {
+		arm.handleOwnMessage(evt, manager)
 		return nil
 	}
 
-	// Add delay before responding (run in goroutine to not block)
-	go func() {
-		chatJID := evt.Info.Chat.String()
-
-		// Show typing indicator before delay
-		if err := manager.SendChatPresence(chatJID, types.ChatPresenceComposing); err != nil {
-			fmt.Printf("Failed to send typing status: %v\n", err)
+	// Voice notes are answered with their transcript once it is ready
+	if arm.transcribes(evt) {
+		config := arm.config
+		if !arm.track() {
+			return nil
 		}
+		go func() {
+			defer arm.wg.Done()
+			transcript := manager.transcribeVoiceNote(arm.ctx, config, evt)
+			if arm.stopping.Load() {
+				return
+			}
+			if transcript == "" {
+				if arm.config.Enabled {
+					manager.recordDecision(newDecision(evt, "", false), DecisionSkipped, "transcription_failed")
+				}
+				return
+			}
+			arm.processMessage(evt, manager, transcript, false)
+		}()
+		return nil
+	}
 
-		if arm.config.ResponseDelay > 0 {
-			time.Sleep(time.Duration(arm.config.ResponseDelay) * time.Second)
+	// Classifying with the AI takes a request, so the message is handled in the background
+	if arm.config.Intents.classifiesWithAI(evt.Info.IsGroup) {
+		text := arm.extractMessageText(evt)
+		if !arm.track() {
+			return nil
 		}
+		go func() {
+			defer arm.wg.Done()
+			arm.processMessage(evt, manager, text, false)
+		}()
+		return nil
+	}
 
-		// Retry logic for AI response generation
-		maxRetries := 3
-		var response string
-		var err error
+	arm.processMessage(evt, manager, arm.extractMessageText(evt), false)
+	return nil
+}
 
-		for attempt := 1; attempt <= maxRetries; attempt++ {
-			// Keep typing status active
-			if err := manager.SendChatPresence(chatJID, types.ChatPresenceComposing); err != nil {
-				fmt.Printf("Failed to send typing status: %v\n", err)
-			}
+// processMessage decides how to answer an incoming message with the given text and records
+// the decision. A dry run goes through the same steps without sending anything or touching
+// the rate limits, and answers synchronously.
+func (arm *AutoReplyManager) processMessage(evt *events.Message, manager *Manager, messageText string, dryRun bool) *ReplyDecision {
+	chatJID := evt.Info.Chat.String()
+	sender := evt.Info.Sender.User
+	decision := newDecision(evt, messageText, dryRun)
 
-			response, err = arm.generateAIResponse(messageText)
-			if err == nil {
-				break
-			}
+	if !arm.config.Enabled {
+		return manager.recordDecision(decision, DecisionSkipped, "disabled")
+	}
+	if strings.TrimSpace(messageText) == "" {
+		return manager.recordDecision(decision, DecisionSkipped, "empty")
+	}
 
-			// Check if it's a rate limit error and wait before retrying
-			if strings.Contains(err.Error(), "rate limit") && attempt < maxRetries {
-				waitTime := time.Duration(attempt*30) * time.Second // Exponential backoff
-				fmt.Printf("Rate limit hit, waiting %v before retry %d/%d\n", waitTime, attempt+1, maxRetries)
-				time.Sleep(waitTime)
-				continue
-			}
+	if manager.isAutoReplyPaused(chatJID) {
+		return manager.recordDecision(decision, DecisionSkipped, "paused")
+	}
 
-			// For other errors, don't retry immediately
-			if attempt < maxRetries {
-				time.Sleep(time.Duration(attempt*5) * time.Second)
-			}
+	// Another bot answering our replies instantly would keep both sides talking forever.
+	// Group messages are only checked once they are addressed to us.
+	if !evt.Info.IsGroup && !dryRun && arm.detectLoop(manager, chatJID) {
+		return manager.recordDecision(decision, DecisionSkipped, "loop_detected")
+	}
 
-			// Keep typing status active during retry delay
-			if err := manager.SendChatPresence(chatJID, types.ChatPresenceComposing); err != nil {
-				fmt.Printf("Failed to send typing status: %v\n", err)
-			}
-		}
+	// Resolve the profile assigned to the contact, group or contact tags
+	profile := arm.profiles.Resolve(chatJID, sender, evt.Info.IsGroup, manager.contactTags(evt.Info.Sender))
+	if !arm.allowsSender(profile, sender, evt.Info.IsGroup) {
+		return manager.recordDecision(decision, DecisionSkipped, "not_whitelisted")
+	}
 
-		// Clear typing status in case of error
-		if err != nil {
-			if err := manager.SendChatPresence(chatJID, types.ChatPresencePaused); err != nil {
-				fmt.Printf("Failed to clear typing status: %v\n", err)
+	// Tag the message with its intent, which decides where it goes. Only messages we may
+	// answer are classified.
+	var route *IntentRoute
+	if arm.config.Intents.Enabled {
+		decision.Intent = arm.classifyIntent(manager, evt, messageText, dryRun)
+		route = arm.config.Intents.route(decision.Intent)
+		if route != nil && route.Action == IntentIgnore {
+			return manager.recordDecision(decision, DecisionSkipped, "intent_ignored")
+		}
+	}
+	if route != nil && route.Profile != "" {
+		if named := arm.profiles.Named(route.Profile); named != nil {
+			// The routed profile's own allow and deny lists apply too
+			if !arm.allowsSender(named, sender, evt.Info.IsGroup) {
+				return manager.recordDecision(decision, DecisionSkipped, "not_whitelisted")
 			}
-			fmt.Printf("Failed to generate AI response after %d attempts: %v\n", maxRetries, err)
-			return
+			profile = named
+		} else {
+			fmt.Printf("Reply profile %q of intent %s not found\n", route.Profile, route.Name)
 		}
-
-		// Send response via WhatsApp
-		if err := manager.SendMessage(evt.Info.Chat.String(), response); err != nil {
-			fmt.Printf("Failed to send AI response: %v\n", err)
-			// Clear typing status in case of send error
-			if err := manager.SendChatPresence(chatJID, types.ChatPresencePaused); err != nil {
-				fmt.Printf("Failed to clear typing status: %v\n", err)
+	}
+	if profile != nil {
+		decision.Profile = profile.Name
+	}
+	config := arm.config.withProfile(profile)
+
+	// Messages of intents for a human are drafted for approval
+	if route != nil && route.Action == IntentHuman {
+		config.Approval = ApprovalConfig{Mode: ApprovalSupervised}
+	}
+
+	// Canned replies from the rule table take precedence over the AI
+	rule := arm.rules.Match(RuleMessage{
+		ChatJID: chatJID,
+		Sender:  sender,
+		IsGroup: evt.Info.IsGroup,
+		Text:    messageText,
+		Intent:  decision.Intent,
+	})
+	if rule != nil && !dryRun && !arm.flood.reserveReply(arm.config.Flood, chatJID, time.Now()) {
+		rule = nil
+	}
+	if rule != nil {
+		decision.Rule = rule.Name
+		stop := rule.Action == "stop" || (route != nil && route.Action == IntentRules)
+		if dryRun {
+			if stop {
+				decision.Response = rule.ResponseText
+				return manager.recordDecision(decision, DecisionRule, "rule_match")
 			}
-			return
+		} else if arm.track() {
+			go func() {
+				defer arm.wg.Done()
+				id, err := manager.sendRuleReply(chatJID, rule)
+				if err != nil {
+					if stop {
+						manager.recordDecision(decision, DecisionFailed, err.Error())
+					}
+					return
+				}
+				arm.flood.recordSent(chatJID, time.Now())
+				if stop {
+					decision.SentMessageIDs = []string{id}
+					manager.recordDecision(decision, DecisionRule, "rule_match")
+				}
+			}()
 		}
 
-		// Clear typing status after successful send
-		if err := manager.SendChatPresence(chatJID, types.ChatPresencePaused); err != nil {
-			fmt.Printf("Failed to clear typing status: %v\n", err)
+		if stop {
+			return decision
 		}
-	}()
-
-	return nil
-}
-
-// shouldReply determines if we should reply to this message
-func (arm *AutoReplyManager) shouldReply(evt *events.Message) bool {
-	if arm.config == nil || !arm.config.Enabled {
-		return false
 	}
-
-	// Don't reply to our own messages
-	if evt.Info.IsFromMe {
-		return false
+	if route != nil && route.Action == IntentRules {
+		return manager.recordDecision(decision, DecisionSkipped, "no_rule_for_intent")
 	}
 
-	// Don't reply to group messages (for now)
+	// In groups the AI only answers when addressed
+	var senderName func(jid string) string
 	if evt.Info.IsGroup {
-		return false
+		question, ok := arm.groupTrigger(evt, manager, messageText)
+		if !ok || question == "" {
+			return manager.recordDecision(decision, DecisionSkipped, "group_not_addressed")
+		}
+		if !dryRun && arm.detectLoop(manager, chatJID) {
+			return manager.recordDecision(decision, DecisionSkipped, "loop_detected")
+		}
+		messageText = question
+		senderName = manager.getContactName
 	}
 
-	// Extract message text
-	messageText := arm.extractMessageText(evt)
-	if strings.TrimSpace(messageText) == "" {
-		return false
-	}
+	// Outside business hours a fixed away message replaces the AI
+	if mode := arm.config.BusinessHours.Mode(time.Now()); mode != ModeOpen {
+		message := arm.config.BusinessHours.awayMessage(mode)
+		if message == "" {
+			return manager.recordDecision(decision, DecisionSkipped, mode)
+		}
+		decision.Response = message
+		if dryRun {
+			return manager.recordDecision(decision, DecisionAway, mode)
+		}
+		if !arm.claimAwayReply(manager.messageDB, chatJID, time.Now()) {
+			return manager.recordDecision(decision, DecisionSkipped, "away_cooldown")
+		}
 
-	// Check whitelist if configured
-	if len(arm.config.WhitelistNumbers) > 0 {
-		phoneNumber := evt.Info.Sender.User
-		for _, whitelisted := range arm.config.WhitelistNumbers {
-			if phoneNumber == whitelisted {
-				return true
-			}
+		if !arm.track() {
+			return decision
 		}
-		return false
+		go func() {
+			defer arm.wg.Done()
+			id, err := manager.SendText(chatJID, message)
+			if err != nil {
+				fmt.Printf("Failed to send away message: %v\n", err)
+				manager.recordDecision(decision, DecisionFailed, err.Error())
+				return
+			}
+			decision.SentMessageIDs = []string{id}
+			manager.recordDecision(decision, DecisionAway, mode)
+		}()
+		return decision
 	}
 
-	return true
-}
-
-// extractMessageText extracts text from various message types
-func (arm *AutoReplyManager) extractMessageText(evt *events.Message) string {
-	if evt == nil || evt.Message == nil {
-		return ""
+	// Spending over a budget stops AI replies until the period ends
+	if manager.budgetExceeded(config) {
+		return manager.recordDecision(decision, DecisionSkipped, "budget_exceeded")
 	}
 
-	// Handle different message types
-	if evt.Message.GetConversation() != "" {
-		return evt.Message.GetConversation()
+	// Build the conversation from recent messages of this chat
+	if senderName != nil {
+		name := evt.Info.PushName
+		if name == "" {
+			name = senderName(evt.Info.Sender.String())
+		}
+		messageText = name + ": " + messageText
 	}
 
-	if extMsg := evt.Message.GetExtendedTextMessage(); extMsg != nil {
-		return extMsg.GetText()
+	// A replayed message only sees the history it was answered with
+	var before int64
+	if dryRun {
+		before = evt.Info.Timestamp.Unix()
 	}
 
-	// For other message types, return a placeholder
-	if evt.Message.GetImageMessage() != nil {
-		return "[Image message]"
-	}
-	if evt.Message.GetVideoMessage() != nil {
-		return "[Video message]"
+	job := &replyJob{
+		config:       config,
+		chatJID:      chatJID,
+		sender:       sender,
+		senderJID:    evt.Info.Sender.ToNonAD().String(),
+		messageID:    evt.Info.ID,
+		question:     messageText,
+		conversation: buildConversation(config, manager.messageDB, chatJID, evt.Info.ID, messageText, before, senderName),
+		decision:     decision,
 	}
-	if evt.Message.GetAudioMessage() != nil {
-		return "[Audio message]"
+	if profile != nil {
+		job.temperature = profile.Temperature
 	}
-	if evt.Message.GetDocumentMessage() != nil {
-		return "[Document message]"
+	if image := evt.Message.GetImageMessage(); image != nil && config.Vision.Enabled {
+		job.images = []*waProto.ImageMessage{image}
 	}
 
-	return ""
-}
-
-// generateAIResponse generates a response using the configured AI provider
-func (arm *AutoReplyManager) generateAIResponse(messageText string) (string, error) {
-	switch arm.config.AIProvider {
-	case "openai":
-		return arm.generateOpenAIResponse(messageText)
-	case "ollama":
-		return arm.generateOllamaResponse(messageText)
-	default:
-		return "", fmt.Errorf("unsupported AI provider: %s", arm.config.AIProvider)
+	if dryRun {
+		arm.respond(manager, job)
+		return decision
+	}
+	// Bursts of messages are answered once, after the chat has been quiet for a moment
+	if !arm.track() {
+		return decision
+	}
+	manager.recordDecision(decision, DecisionPending, "")
+	if delay := time.Duration(arm.config.Flood.DebounceSeconds) * time.Second; delay > 0 {
+		replaced, merged := arm.flood.debounce(chatJID, delay, job, func(latest *replyJob) {
+			defer arm.wg.Done()
+			arm.respond(manager, latest)
+		})
+		if replaced != nil {
+			manager.recordDecision(replaced.decision, DecisionSkipped, "debounced")
+		}
+		if merged {
+			arm.wg.Done()
+		}
+		return decision
 	}
-}
-
-// OpenAI API structures
-type OpenAIRequest struct {
-	Model     string          `json:"model"`
-	Messages  []OpenAIMessage `json:"messages"`
-	MaxTokens int             `json:"max_tokens,omitempty"`
-}
 
-type OpenAIMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}
+	// Add delay before responding (run in goroutine to not block)
+	go func() {
+		defer arm.wg.Done()
+		arm.respond(manager, job)
+	}()
 
-type OpenAIResponse struct {
-	Choices []OpenAIChoice `json:"choices"`
+	return decision
 }
 
-type OpenAIChoice struct {
-	Message OpenAIMessage `json:"message"`
-}
+// respond generates the AI reply of a job and sends it, or queues it for approval.
+// For a dry run the reply is only recorded on the job's decision.
+func (arm *AutoReplyManager) respond(manager *Manager, job *replyJob) {
+	chatJID := job.chatJID
+	config := job.config
+	decision := job.decision
+	dryRun := decision.DryRun
 
-// generateOpenAIResponse generates response using OpenAI API with enhanced error handling
-func (arm *AutoReplyManager) generateOpenAIResponse(messageText string) (string, error) {
-	if arm.config.OpenAIAPIKey == "" {
-		return "", fmt.Errorf("OpenAI API key not configured")
+	if !dryRun && !arm.flood.reserveReply(config.Flood, chatJID, time.Now()) {
+		fmt.Printf("Reply limit reached for %s, not replying\n", chatJID)
+		manager.recordDecision(decision, DecisionSkipped, "rate_limited")
+		return
 	}
 
-	messages := []OpenAIMessage{
-		{Role: "system", Content: arm.config.SystemPrompt},
-		{Role: "user", Content: messageText},
-	}
+	// Show typing indicator before delay
+	arm.setPresence(manager, job, types.ChatPresenceComposing)
 
-	request := OpenAIRequest{
-		Model:     arm.config.OpenAIModel,
-		Messages:  messages,
-		MaxTokens: arm.config.MaxResponseLength / 4, // Rough token estimation
+	if config.ResponseDelay > 0 && !dryRun {
+		if !arm.sleep(time.Duration(config.ResponseDelay) * time.Second) {
+			manager.recordDecision(decision, DecisionSkipped, "stopped")
+			return
+		}
 	}
 
-	jsonData, err := json.Marshal(request)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %v", err)
-	}
+	// Ground the reply in the knowledge base
+	conversation, sources := arm.withKnowledge(manager, job)
+	conversation = manager.withMemory(job, conversation)
+	conversation = manager.attachImages(arm.ctx, config.Vision, conversation, job.images)
+	decision.Prompt = conversation
 
-	// Create request with timeout context
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	// Replies waiting for approval, and dry runs, may only use tools without side effects
+	supervised := config.Approval.supervised(job.sender)
+	tools := manager.newToolSession(job, supervised || dryRun)
 
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %v", err)
+	// Wait for a free AI request slot
+	release, ok := arm.flood.acquire(arm.ctx, config.Flood.MaxConcurrent)
+	if !ok {
+		arm.setPresence(manager, job, types.ChatPresencePaused)
+		manager.recordDecision(decision, DecisionSkipped, "stopped")
+		return
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+arm.config.OpenAIAPIKey)
-
-	resp, err := arm.client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("network error: %v", err)
+	// Try the providers of the fallback chain until one answers
+	response, provider, stream, err := arm.generateWithFailover(manager, job, conversation, tools)
+	release()
+	if provider == "" {
+		provider = config.AIProvider
 	}
-	defer resp.Body.Close()
+	decision.Provider = provider
+	decision.Model = config.modelOf(provider)
 
-	body, err := io.ReadAll(resp.Body)
+	// Clear typing status in case of error
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %v", err)
+		arm.setPresence(manager, job, types.ChatPresencePaused)
+		fmt.Printf("Failed to generate AI response: %v\n", err)
+		if stream.sent() {
+			arm.flood.recordSent(chatJID, time.Now())
+			decision.SentMessageIDs = stream.sentIDs
+		} else if id := arm.sendApology(manager, job); id != "" {
+			decision.SentMessageIDs = []string{id}
+		}
+		manager.recordDecision(decision, DecisionFailed, err.Error())
+		return
+	}
+	decision.RawOutput = response
+	decision.SentMessageIDs = stream.sentIDs
+	response = markdownToWhatsApp(strings.TrimSpace(response))
+	decision.Response = response
+
+	// Supervised contacts get a draft for the operator to approve instead
+	if supervised {
+		if dryRun {
+			manager.recordDecision(decision, DecisionDrafted, "approval_required")
+			return
+		}
+		autoSendAfter := time.Duration(config.Approval.AutoSendAfter) * time.Second
+		if err := manager.queueReplyDraft(chatJID, job.messageID, job.question, response, autoSendAfter); err != nil {
+			fmt.Printf("Failed to queue AI response for approval: %v\n", err)
+			manager.recordDecision(decision, DecisionFailed, err.Error())
+		} else {
+			manager.logReply(job, response, sources)
+			manager.recordDecision(decision, DecisionDrafted, "approval_required")
+		}
+		arm.setPresence(manager, job, types.ChatPresencePaused)
+		return
 	}
 
-	// Enhanced error handling for different HTTP status codes
-	switch resp.StatusCode {
-	case http.StatusOK:
-		// Success, continue processing
-	case http.StatusUnauthorized:
-		return "", fmt.Errorf("invalid API key")
-	case http.StatusTooManyRequests:
-		return "", fmt.Errorf("rate limit exceeded, please try again later")
-	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable:
-		return "", fmt.Errorf("OpenAI service temporarily unavailable")
-	default:
-		return "", fmt.Errorf("OpenAI API error (status %d): %s", resp.StatusCode, string(body))
+	// We may have taken over the chat while the reply was generated. A handoff by the
+	// assistant itself still sends the reply announcing it.
+	if manager.isAutoReplyPaused(chatJID) && !tools.handedOffToHuman() {
+		arm.setPresence(manager, job, types.ChatPresencePaused)
+		manager.recordDecision(decision, DecisionSkipped, "handed_over")
+		return
 	}
 
-	var openAIResp OpenAIResponse
-	if err := json.Unmarshal(body, &openAIResp); err != nil {
-		return "", fmt.Errorf("failed to parse response: %v", err)
+	if dryRun {
+		manager.recordDecision(decision, DecisionReplied, "")
+		return
 	}
 
-	if len(openAIResp.Choices) == 0 {
-		return "", fmt.Errorf("no response choices returned from OpenAI")
+	// Send response via WhatsApp, split into natural parts. A paragraph sent early is not repeated.
+	if rest := stream.remainder(decision.RawOutput); rest != "" {
+		sent, err := manager.sendReply(config, chatJID, markdownToWhatsApp(rest))
+		decision.SentMessageIDs = append(decision.SentMessageIDs, sent...)
+		if err != nil {
+			fmt.Printf("Failed to send AI response: %v\n", err)
+			// Clear typing status in case of send error
+			arm.setPresence(manager, job, types.ChatPresencePaused)
+			if len(decision.SentMessageIDs) > 0 {
+				arm.flood.recordSent(chatJID, time.Now())
+			}
+			manager.recordDecision(decision, DecisionFailed, err.Error())
+			return
+		}
 	}
+	arm.flood.recordSent(chatJID, time.Now())
+	manager.logReply(job, response, sources)
+	manager.recordDecision(decision, DecisionReplied, "")
 
-	response := openAIResp.Choices[0].Message.Content
-	return arm.truncateResponse(response), nil
+	// Clear typing status after successful send
+	arm.setPresence(manager, job, types.ChatPresencePaused)
 }
 
-// Ollama API structures
-type OllamaRequest struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
-	Stream bool   `json:"stream"`
+// setPresence shows or clears the typing indicator in the chat of a job. Dry runs stay invisible.
+func (arm *AutoReplyManager) setPresence(manager *Manager, job *replyJob, state types.ChatPresence) {
+	if job.decision.DryRun {
+		return
+	}
+	if err := manager.SendChatPresence(job.chatJID, state); err != nil {
+		fmt.Printf("Failed to update typing status: %v\n", err)
+	}
 }
 
-type OllamaResponse struct {
-	Response string `json:"response"`
-	Done     bool   `json:"done"`
+// detectLoop records an incoming message for loop detection and pauses the chat when it
+// looks like another bot answering our replies
+func (arm *AutoReplyManager) detectLoop(manager *Manager, chatJID string) bool {
+	if !arm.flood.detectLoop(arm.config.Flood, chatJID, time.Now()) {
+		return false
+	}
+	if err := manager.PauseAutoReply(chatJID, arm.config.Flood.LoopPauseMinutes, "loop_detected"); err != nil {
+		fmt.Printf("Failed to pause auto-reply after loop detection: %v\n", err)
+	}
+	return true
 }
 
-// generateOllamaResponse generates response using Ollama API with enhanced error handling
-func (arm *AutoReplyManager) generateOllamaResponse(messageText string) (string, error) {
-	if arm.config.OllamaURL == "" {
-		return "", fmt.Errorf("ollama URL not configured")
+// allowsSender checks the sender against the profile's access lists, or the global
+// whitelist when no profile applies. Groups are gated by the group allow-list instead.
+func (arm *AutoReplyManager) allowsSender(profile *ReplyProfile, phoneNumber string, isGroup bool) bool {
+	if profile != nil {
+		return profile.allows(phoneNumber)
 	}
+	if isGroup || len(arm.config.WhitelistNumbers) == 0 {
+		return true
+	}
+	return containsString(arm.config.WhitelistNumbers, phoneNumber)
+}
 
-	prompt := fmt.Sprintf("%s\n\nUser: %s\nAssistant:", arm.config.SystemPrompt, messageText)
-
-	request := OllamaRequest{
-		Model:  arm.config.OllamaModel,
-		Prompt: prompt,
-		Stream: false,
+// extractMessageText extracts text from various message types
+func (arm *AutoReplyManager) extractMessageText(evt *events.Message) string {
+	if evt == nil || evt.Message == nil {
+		return ""
 	}
 
-	jsonData, err := json.Marshal(request)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %v", err)
+	// Handle different message types
+	if evt.Message.GetConversation() != "" {
+		return evt.Message.GetConversation()
 	}
 
-	// Create request with timeout context
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second) // Longer timeout for local models
-	defer cancel()
+	if extMsg := evt.Message.GetExtendedTextMessage(); extMsg != nil {
+		return extMsg.GetText()
+	}
 
-	url := strings.TrimSuffix(arm.config.OllamaURL, "/") + "/api/generate"
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %v", err)
+	// For other message types, return a placeholder
+	if image := evt.Message.GetImageMessage(); image != nil {
+		if caption := strings.TrimSpace(image.GetCaption()); caption != "" {
+			return caption
+		}
+		return "[Image message]"
+	}
+	if evt.Message.GetVideoMessage() != nil {
+		return "[Video message]"
+	}
+	if evt.Message.GetAudioMessage() != nil {
+		return "[Audio message]"
+	}
+	if evt.Message.GetDocumentMessage() != nil {
+		return "[Document message]"
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	return ""
+}
 
-	resp, err := arm.client.Do(req)
+// generateAIResponse generates a response to the conversation using the named provider.
+// With a tool session, providers that support function calling may use the tools.
+// Without tools the reply is streamed to stream when streaming is enabled.
+// The response is the raw model output, still in Markdown.
+func (arm *AutoReplyManager) generateAIResponse(manager *Manager, job *replyJob, name string, conversation []ChatMessage, tools *toolSession, stream *replyStream) (string, error) {
+	config := job.config
+	provider, err := NewProvider(name, config, arm.client)
 	if err != nil {
-		return "", fmt.Errorf("network error (check if Ollama is running): %v", err)
+		// A provider that is not set up won't work on a retry either
+		return "", providerError(ErrorPermanent, "%v", err)
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response: %v", err)
+	purpose := "reply"
+	if job.decision.DryRun {
+		purpose = "replay"
 	}
+	provider = manager.meterProvider(provider, config, job.chatJID, purpose)
 
-	// Enhanced error handling for different HTTP status codes
-	switch resp.StatusCode {
-	case http.StatusOK:
-		// Success, continue processing
-	case http.StatusNotFound:
-		return "", fmt.Errorf("model '%s' not found in Ollama", arm.config.OllamaModel)
-	case http.StatusInternalServerError:
-		return "", fmt.Errorf("ollama internal error: %s", string(body))
-	case http.StatusServiceUnavailable:
-		return "", fmt.Errorf("ollama service unavailable")
-	default:
-		return "", fmt.Errorf("ollama API error (status %d): %s", resp.StatusCode, string(body))
+	req := GenerateRequest{
+		Messages:    conversation,
+		MaxTokens:   config.maxReplyTokens(),
+		Temperature: job.temperature,
 	}
-
-	var ollamaResp OllamaResponse
-	if err := json.Unmarshal(body, &ollamaResp); err != nil {
-		return "", fmt.Errorf("failed to parse response: %v", err)
+	var response string
+	if caller, ok := provider.(ToolCaller); ok && tools != nil {
+		response, err = tools.run(arm.ctx, caller, req)
+	} else if stream != nil && config.Streaming.Enabled {
+		response, err = generateStream(arm.ctx, provider, req, stream.onChunk)
+	} else {
+		response, err = provider.Generate(arm.ctx, req)
 	}
-
-	if ollamaResp.Response == "" {
-		return "", fmt.Errorf("empty response from Ollama")
+	if err != nil {
+		return "", err
 	}
 
-	return arm.truncateResponse(ollamaResp.Response), nil
+	return response, nil
 }
 
-// truncateResponse truncates response to max length
-func (arm *AutoReplyManager) truncateResponse(response string) string {
-	response = strings.TrimSpace(response)
-	if len(response) > arm.config.MaxResponseLength {
-		return response[:arm.config.MaxResponseLength] + "..."
+// TestAIConnection tests the AI service connection
+func (arm *AutoReplyManager) TestAIConnection(manager *Manager) error {
+	if arm.config == nil {
+		return fmt.Errorf("auto-reply not configured")
 	}
-	return response
+
+	_, err := arm.TestProvider(manager, arm.config.AIProvider)
+	return err
 }
 
-// TestAIConnection tests the AI service connection
-func (arm *AutoReplyManager) TestAIConnection() error {
+// TestProvider sends a test message to the named provider and returns its reply
+func (arm *AutoReplyManager) TestProvider(manager *Manager, name string) (string, error) {
 	if arm.config == nil {
-		return fmt.Errorf("auto-reply not configured")
+		return "", fmt.Errorf("auto-reply not configured")
+	}
+
+	provider, err := NewProvider(name, arm.config, arm.client)
+	if err != nil {
+		return "", err
 	}
+	provider = manager.meterProvider(provider, arm.config, "", "test")
 
 	testMessage := "Hello, this is a test message."
-	_, err := arm.generateAIResponse(testMessage)
-	return err
+	response, err := provider.Generate(arm.ctx, GenerateRequest{
+		Messages: []ChatMessage{
+			{Role: "system", Content: arm.config.SystemPrompt},
+			{Role: "user", Content: testMessage},
+		},
+		MaxTokens: arm.config.maxReplyTokens(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join(arm.config.replyParts(markdownToWhatsApp(response)), "\n\n"), nil
 }
 
 // IsWhitelisted checks if a phone number is in the whitelist
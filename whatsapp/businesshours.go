@@ -0,0 +1,230 @@
+package whatsapp
+
+import (
+	"fmt"
+	"time"
+)
+
+// Auto-reply modes depending on the business hours calendar
+const (
+	ModeOpen     = "open"     // inside opening hours, the AI assistant answers
+	ModeClosed   = "closed"   // outside opening hours or on a holiday, the away message is sent
+	ModeVacation = "vacation" // inside the vacation window, the vacation message is sent
+)
+
+const dateLayout = "2006-01-02"
+
+// BusinessHoursConfig holds the opening hours calendar of the auto-reply
+type BusinessHoursConfig struct {
+	Enabled           bool           `json:"enabled"`
+	TimeZone          string         `json:"time_zone"` // IANA name, empty for the system time zone
+	Weekly            []OpeningHours `json:"weekly"`
+	Holidays          []string       `json:"holidays"` // closed dates as "2006-01-02"
+	AwayMessage       string         `json:"away_message"`
+	AwayCooldownHours int            `json:"away_cooldown_hours"` // 0 sends the away message every time
+	Vacation          VacationConfig `json:"vacation"`
+}
+
+// OpeningHours is an opening range on a weekday. A close time before the open time
+// continues past midnight into the next day.
+type OpeningHours struct {
+	Weekday int    `json:"weekday"` // 0 = Sunday
+	Open    string `json:"open"`    // "09:00"
+	Close   string `json:"close"`   // "17:00", "24:00" for midnight
+}
+
+// VacationConfig is a dated out-of-office window, inclusive on both ends
+type VacationConfig struct {
+	Enabled bool   `json:"enabled"`
+	Start   string `json:"start"` // "2006-01-02"
+	End     string `json:"end"`
+	Message string `json:"message"`
+}
+
+// GetDefaultBusinessHoursConfig returns a Monday to Friday, 9 to 17 calendar, disabled
+func GetDefaultBusinessHoursConfig() BusinessHoursConfig {
+	weekly := make([]OpeningHours, 0, 5)
+	for day := time.Monday; day <= time.Friday; day++ {
+		weekly = append(weekly, OpeningHours{Weekday: int(day), Open: "09:00", Close: "17:00"})
+	}
+
+	return BusinessHoursConfig{
+		Enabled:           false,
+		Weekly:            weekly,
+		AwayMessage:       "Thanks for your message! We're currently closed and will get back to you during business hours.",
+		AwayCooldownHours: 12,
+		Vacation: VacationConfig{
+			Message: "Thanks for your message! We're on vacation and will reply when we're back.",
+		},
+	}
+}
+
+// Validate checks the time zone, opening hours and dates of the calendar
+func (c *BusinessHoursConfig) Validate() error {
+	if _, err := c.location(); err != nil {
+		return err
+	}
+
+	for _, hours := range c.Weekly {
+		if hours.Weekday < 0 || hours.Weekday > 6 {
+			return fmt.Errorf("invalid weekday: %d", hours.Weekday)
+		}
+		if _, err := parseClock(hours.Open); err != nil {
+			return err
+		}
+		if _, err := parseClock(hours.Close); err != nil {
+			return err
+		}
+	}
+
+	for _, day := range c.Holidays {
+		if _, err := time.Parse(dateLayout, day); err != nil {
+			return fmt.Errorf("invalid holiday date %q", day)
+		}
+	}
+
+	if c.AwayCooldownHours < 0 {
+		return fmt.Errorf("away cooldown cannot be negative")
+	}
+
+	if c.Vacation.Enabled {
+		start, err := time.Parse(dateLayout, c.Vacation.Start)
+		if err != nil {
+			return fmt.Errorf("invalid vacation start date %q", c.Vacation.Start)
+		}
+		end, err := time.Parse(dateLayout, c.Vacation.End)
+		if err != nil {
+			return fmt.Errorf("invalid vacation end date %q", c.Vacation.End)
+		}
+		if end.Before(start) {
+			return fmt.Errorf("vacation ends before it starts")
+		}
+	}
+
+	return nil
+}
+
+// Mode returns the auto-reply mode at the given time
+func (c *BusinessHoursConfig) Mode(now time.Time) string {
+	loc, err := c.location()
+	if err != nil {
+		loc = time.Local
+	}
+	now = now.In(loc)
+	today := now.Format(dateLayout)
+
+	// Dates in the layout compare correctly as strings
+	if c.Vacation.Enabled && today >= c.Vacation.Start && today <= c.Vacation.End {
+		return ModeVacation
+	}
+
+	if !c.Enabled {
+		return ModeOpen
+	}
+
+	for _, day := range c.Holidays {
+		if day == today {
+			return ModeClosed
+		}
+	}
+
+	if c.isOpen(now) {
+		return ModeOpen
+	}
+	return ModeClosed
+}
+
+// isOpen reports whether the time falls inside one of the weekly opening ranges
+func (c *BusinessHoursConfig) isOpen(now time.Time) bool {
+	weekday := int(now.Weekday())
+	minute := now.Hour()*60 + now.Minute()
+
+	for _, hours := range c.Weekly {
+		open, err := parseClock(hours.Open)
+		if err != nil {
+			continue
+		}
+		closeAt, err := parseClock(hours.Close)
+		if err != nil {
+			continue
+		}
+
+		if open < closeAt {
+			if weekday == hours.Weekday && minute >= open && minute < closeAt {
+				return true
+			}
+			continue
+		}
+
+		// Overnight range
+		if weekday == hours.Weekday && minute >= open {
+			return true
+		}
+		if weekday == (hours.Weekday+1)%7 && minute < closeAt {
+			return true
+		}
+	}
+
+	return false
+}
+
+// location returns the configured time zone
+func (c *BusinessHoursConfig) location() (*time.Location, error) {
+	if c.TimeZone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(c.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %v", c.TimeZone, err)
+	}
+	return loc, nil
+}
+
+// parseClock converts "15:04" to minutes since midnight, accepting "24:00"
+func parseClock(value string) (int, error) {
+	var hour, minute int
+	if _, err := fmt.Sscanf(value, "%d:%d", &hour, &minute); err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	if hour < 0 || minute < 0 || minute > 59 || hour > 24 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return hour*60 + minute, nil
+}
+
+// awayMessage returns the message to send in the given mode
+func (c *BusinessHoursConfig) awayMessage(mode string) string {
+	if mode == ModeVacation {
+		return c.Vacation.Message
+	}
+	return c.AwayMessage
+}
+
+// claimAwayReply reports whether an away message may be sent to the chat now and records it.
+// Each chat gets at most one away message per cooldown period.
+func (arm *AutoReplyManager) claimAwayReply(db *MessageDB, chatJID string, now time.Time) bool {
+	if db == nil {
+		return true
+	}
+
+	cooldown := time.Duration(arm.config.BusinessHours.AwayCooldownHours) * time.Hour
+	if cooldown > 0 {
+		last, err := db.GetAwayReplyTime(chatJID)
+		if err != nil {
+			fmt.Printf("Failed to load away reply time: %v\n", err)
+		} else if last > 0 && now.Sub(time.Unix(last, 0)) < cooldown {
+			return false
+		}
+	}
+
+	if err := db.SetAwayReplyTime(chatJID, now); err != nil {
+		fmt.Printf("Failed to store away reply time: %v\n", err)
+	}
+	return true
+}
+
+// GetAutoReplyMode returns the current auto-reply mode: "open", "closed" or "vacation"
+func (m *Manager) GetAutoReplyMode() string {
+	config := m.GetAutoReplyConfig()
+	return config.BusinessHours.Mode(time.Now())
+}
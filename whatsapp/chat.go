@@ -3,8 +3,13 @@ package whatsapp
 import (
 	"context"
 	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
 	"time"
 
+	"go.mau.fi/whatsmeow"
 	waProto "go.mau.fi/whatsmeow/binary/proto"
 	"go.mau.fi/whatsmeow/types"
 )
@@ -35,29 +40,132 @@ func (m *Manager) getChatsFromMessageDB() ([]Chat, error) {
 	// Convert StoredChat to Chat format
 	var chats []Chat
 	for _, stored := range storedChats {
-		// Get last message for display
-		lastMsg, _ := m.messageDB.GetLastMessage(stored.JID)
+		chats = append(chats, m.chatFromStored(stored))
+	}
+
+	return chats, nil
+}
+
+// GetChat retrieves a single chat from the message database
+func (m *Manager) GetChat(chatID string) (*Chat, error) {
+	if m.messageDB == nil {
+		return nil, fmt.Errorf("message database not initialized")
+	}
+
+	stored, err := m.messageDB.GetChat(chatID)
+	if err != nil || stored == nil {
+		return nil, err
+	}
+
+	chat := m.chatFromStored(*stored)
+	return &chat, nil
+}
 
-		lastText := "No messages"
-		timeStr := ""
-		if lastMsg != nil {
-			lastText = lastMsg.Content
-			timeStr = m.formatMessageTime(lastMsg.Timestamp)
+// GetMessages returns the latest messages of a chat from the message database, newest first
+func (m *Manager) GetMessages(chatID string, limit int) ([]Message, error) {
+	if m.messageDB == nil {
+		return nil, fmt.Errorf("message database not initialized")
+	}
+
+	stored, err := m.messageDB.GetChatMessages(chatID, limit, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load messages: %v", err)
+	}
+
+	names := make(map[string]string)
+	messages := make([]Message, 0, len(stored))
+	for _, msg := range stored {
+		text := msg.Content
+		if msg.Caption != "" {
+			text = msg.Caption
 		}
 
-		chat := Chat{
-			ID:      stored.JID,
-			Name:    stored.Name,
-			Last:    lastText,
-			Time:    timeStr,
-			Unread:  stored.UnreadCount,
-			Avatar:  "",
-			IsGroup: stored.IsGroup,
+		author := "Me"
+		if !msg.IsFromMe {
+			name, ok := names[msg.SenderJID]
+			if !ok {
+				name = m.getContactName(msg.SenderJID)
+				names[msg.SenderJID] = name
+			}
+			author = name
 		}
-		chats = append(chats, chat)
+
+		messages = append(messages, Message{
+			ID:         msg.ID,
+			ChatID:     msg.ChatJID,
+			Author:     author,
+			Text:       text,
+			Time:       m.formatMessageTime(msg.Timestamp),
+			IsMine:     msg.IsFromMe,
+			Type:       msg.MessageType,
+			Transcript: msg.Transcript,
+		})
 	}
 
-	return chats, nil
+	return messages, nil
+}
+
+// chatFromStored converts a StoredChat into the frontend Chat format
+func (m *Manager) chatFromStored(stored StoredChat) Chat {
+	// Get last message for display
+	lastMsg, _ := m.messageDB.GetLastMessage(stored.JID)
+
+	lastText := "No messages"
+	timeStr := ""
+	if lastMsg != nil {
+		lastText = lastMsg.Content
+		timeStr = m.formatMessageTime(lastMsg.Timestamp)
+	}
+
+	return Chat{
+		ID:       stored.JID,
+		Name:     stored.Name,
+		Last:     lastText,
+		Time:     timeStr,
+		Unread:   stored.UnreadCount,
+		Avatar:   "",
+		IsGroup:  stored.IsGroup,
+		Archived: stored.Archived,
+		Pinned:   stored.Pinned,
+		Muted:    stored.MutedUntil > time.Now().Unix(),
+	}
+}
+
+// MarkChatAsRead resets the unread counter of a chat and notifies the frontend
+func (m *Manager) MarkChatAsRead(chatID string) error {
+	if m.messageDB == nil {
+		return fmt.Errorf("message database not initialized")
+	}
+
+	if err := m.messageDB.MarkChatAsRead(chatID); err != nil {
+		return fmt.Errorf("failed to mark chat as read: %v", err)
+	}
+
+	m.emitChatUpdate(chatID)
+	return nil
+}
+
+// SubscribePresence subscribes to online/last seen updates of a contact
+func (m *Manager) SubscribePresence(jid string) error {
+	if m.client == nil || !m.client.IsConnected() {
+		return fmt.Errorf("WhatsApp client not connected")
+	}
+
+	contactJID, err := types.ParseJID(jid)
+	if err != nil {
+		return fmt.Errorf("invalid JID: %v", err)
+	}
+
+	// Presence updates are only delivered while we are marked as available
+	if err := m.client.SendPresence(types.PresenceAvailable); err != nil {
+		return fmt.Errorf("failed to send presence: %v", err)
+	}
+
+	if err := m.client.SubscribePresence(contactJID); err != nil {
+		return fmt.Errorf("failed to subscribe presence: %v", err)
+	}
+
+	return nil
 }
 
 func (m *Manager) formatMessageTime(timestamp int64) string {
@@ -152,43 +260,162 @@ func (m *Manager) SendChatPresence(chatJID string, presence types.ChatPresence)
 }
 
 func (m *Manager) SendMessage(chatID, text string) error {
+	_, err := m.SendText(chatID, text)
+	return err
+}
+
+// SendText sends a text message and returns its message ID
+func (m *Manager) SendText(chatID, text string) (string, error) {
+	// Create message
+	msg := &waProto.Message{
+		Conversation: &text,
+	}
+
+	return m.sendAndRecord(chatID, msg, &StoredMessage{
+		MessageType: "text",
+		Content:     text,
+	})
+}
+
+// SendMedia uploads a local file and sends it as an image, video, audio or document message
+func (m *Manager) SendMedia(chatID, filePath, mediaType, caption string) (string, error) {
+	return m.sendMedia(context.Background(), chatID, filePath, mediaType, caption)
+}
+
+// sendMedia sends a media message, giving up if ctx ends before the message goes out
+func (m *Manager) sendMedia(ctx context.Context, chatID, filePath, mediaType, caption string) (string, error) {
 	if m.client == nil || !m.client.IsConnected() {
-		return fmt.Errorf("WhatsApp client not connected")
+		return "", fmt.Errorf("WhatsApp client not connected")
 	}
 
-	// Parse JID from chatID
-	jid, err := types.ParseJID(chatID)
+	data, err := os.ReadFile(filePath)
 	if err != nil {
-		return fmt.Errorf("invalid chat ID: %v", err)
+		return "", fmt.Errorf("failed to read media file: %v", err)
 	}
 
-	// Create message
-	msg := &waProto.Message{
-		Conversation: &text,
+	mimeType := mime.TypeByExtension(filepath.Ext(filePath))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+
+	var appInfo whatsmeow.MediaType
+	switch mediaType {
+	case "image":
+		appInfo = whatsmeow.MediaImage
+	case "video":
+		appInfo = whatsmeow.MediaVideo
+	case "audio":
+		appInfo = whatsmeow.MediaAudio
+	case "document":
+		appInfo = whatsmeow.MediaDocument
+	default:
+		return "", fmt.Errorf("unsupported media type: %s", mediaType)
+	}
+
+	uploaded, err := m.client.Upload(ctx, data, appInfo)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload media: %v", err)
+	}
+
+	msg := &waProto.Message{}
+	switch mediaType {
+	case "image":
+		msg.ImageMessage = &waProto.ImageMessage{
+			Caption:       &caption,
+			Mimetype:      &mimeType,
+			URL:           &uploaded.URL,
+			DirectPath:    &uploaded.DirectPath,
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    &uploaded.FileLength,
+		}
+	case "video":
+		msg.VideoMessage = &waProto.VideoMessage{
+			Caption:       &caption,
+			Mimetype:      &mimeType,
+			URL:           &uploaded.URL,
+			DirectPath:    &uploaded.DirectPath,
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    &uploaded.FileLength,
+		}
+	case "audio":
+		msg.AudioMessage = &waProto.AudioMessage{
+			Mimetype:      &mimeType,
+			URL:           &uploaded.URL,
+			DirectPath:    &uploaded.DirectPath,
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    &uploaded.FileLength,
+		}
+	case "document":
+		fileName := filepath.Base(filePath)
+		msg.DocumentMessage = &waProto.DocumentMessage{
+			Caption:       &caption,
+			FileName:      &fileName,
+			Mimetype:      &mimeType,
+			URL:           &uploaded.URL,
+			DirectPath:    &uploaded.DirectPath,
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    &uploaded.FileLength,
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	content, _, _, _, _ := m.messageDB.extractMessageContent(msg)
+	return m.sendAndRecord(chatID, msg, &StoredMessage{
+		MessageType: mediaType,
+		Content:     content,
+		MediaPath:   filePath,
+		MediaType:   mediaType,
+		Caption:     caption,
+	})
+}
+
+// sendAndRecord sends a message, stores it in the message database and notifies the frontend.
+// record only needs the content fields, the rest is filled in here.
+func (m *Manager) sendAndRecord(chatID string, msg *waProto.Message, record *StoredMessage) (string, error) {
+	if !m.trackSend() {
+		return "", fmt.Errorf("application is shutting down")
+	}
+	defer m.sendWG.Done()
+
+	if m.client == nil || !m.client.IsConnected() {
+		return "", fmt.Errorf("WhatsApp client not connected")
+	}
+
+	// Parse JID from chatID
+	jid, err := types.ParseJID(chatID)
+	if err != nil {
+		return "", fmt.Errorf("invalid chat ID: %v", err)
 	}
 
 	// Send message
 	response, err := m.client.SendMessage(context.Background(), jid, msg)
 	if err != nil {
-		return fmt.Errorf("failed to send message: %v", err)
+		return "", fmt.Errorf("failed to send message: %v", err)
 	}
 
 	// Store the sent message in the database
 	if m.messageDB != nil {
 		now := time.Now()
-		storedMsg := &StoredMessage{
-			ID:          response.ID,
-			ChatJID:     jid.String(),
-			SenderJID:   "me",
-			MessageType: "text",
-			Content:     text,
-			Timestamp:   now.Unix(),
-			IsFromMe:    true,
-			IsGroup:     jid.Server == "g.us",
-			CreatedAt:   now,
-		}
-
-		err = m.messageDB.StoreDirectMessage(storedMsg)
+		record.ID = response.ID
+		record.ChatJID = jid.String()
+		record.SenderJID = "me"
+		record.Timestamp = now.Unix()
+		record.IsFromMe = true
+		record.IsGroup = jid.Server == "g.us"
+		record.CreatedAt = now
+
+		err = m.messageDB.StoreDirectMessage(record)
 		if err != nil {
 			// Log the error but don't fail the send operation
 			m.log.Errorf("Failed to store sent message in database: %v", err)
@@ -223,7 +450,26 @@ func (m *Manager) SendMessage(chatID, text string) error {
 		if err := m.messageDB.UpsertChat(chat); err != nil {
 			m.log.Errorf("Failed to update chat in database: %v", err)
 		}
+
+		text := record.Content
+		if record.Caption != "" {
+			text = record.Caption
+		}
+		m.emitEvent(ConnectionEvent{
+			Type:    "message",
+			Message: "Message sent",
+			Payload: Message{
+				ID:     response.ID,
+				ChatID: jid.String(),
+				Author: "Me",
+				Text:   text,
+				Time:   m.formatMessageTime(now.Unix()),
+				IsMine: true,
+				Type:   record.MessageType,
+			},
+		})
+		m.emitChatUpdate(jid.String())
 	}
 
-	return nil
+	return response.ID, nil
 }
@@ -5,8 +5,10 @@ import (
 	"encoding/base64"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/skip2/go-qrcode"
@@ -26,12 +28,29 @@ type Manager struct {
 	autoReply *AutoReplyManager
 	scheduler *Scheduler
 	messageDB *MessageDB
+	approvals *ApprovalQueue
+	knowledge *KnowledgeBase
+	usage     *UsageTracker
+
+	historyConfig *HistorySyncConfig
+
+	pairing    *pairingSession
+	pairingMux sync.Mutex
+
+	// sendWG tracks messages being sent so shutdown can wait for them. sendMu makes
+	// checking closing and adding to sendWG atomic with respect to shutdown.
+	sendWG    sync.WaitGroup
+	sendMu    sync.Mutex
+	closing   bool
+	closeOnce sync.Once
+	closeErr  error
 }
 
 type ConnectionEvent struct {
-	Type    string `json:"type"` // "connected", "disconnected", "qr", "code", "error"
-	Message string `json:"message"`
-	Data    string `json:"data,omitempty"`
+	Type    string      `json:"type"` // "connected", "disconnected", "qr", "code", "pair_success", "pair_error", "error", "message", "chat_update", "unread", "presence", "typing", "group", "history_sync", "logged_out", "draft", "autoreply_pause", "transcript", "budget_exceeded"
+	Message string      `json:"message"`
+	Data    string      `json:"data,omitempty"`
+	Payload interface{} `json:"payload,omitempty"` // Message, Chat, PresenceUpdate, TypingUpdate, UnreadUpdate, GroupUpdate, HistorySyncProgress, ReplyDraft, ChatPause, TranscriptUpdate, BudgetAlert
 }
 
 type ConnectionStatus struct {
@@ -41,13 +60,16 @@ type ConnectionStatus struct {
 }
 
 type Chat struct {
-	ID      string `json:"id"`
-	Name    string `json:"name"`
-	Last    string `json:"last"`
-	Time    string `json:"time"`
-	Unread  int    `json:"unread"`
-	IsGroup bool   `json:"isGroup"`
-	Avatar  string `json:"avatar,omitempty"`
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Last     string `json:"last"`
+	Time     string `json:"time"`
+	Unread   int    `json:"unread"`
+	IsGroup  bool   `json:"isGroup"`
+	Avatar   string `json:"avatar,omitempty"`
+	Archived bool   `json:"archived"`
+	Pinned   bool   `json:"pinned"`
+	Muted    bool   `json:"muted"`
 }
 
 type Message struct {
@@ -58,6 +80,8 @@ type Message struct {
 	Time   string `json:"time"`
 	IsMine bool   `json:"mine"`
 	Type   string `json:"type"` // text, image, audio, etc
+
+	Transcript string `json:"transcript,omitempty"` // text of a transcribed voice note
 }
 
 func NewManager(dbPath string) (*Manager, error) {
@@ -87,7 +111,7 @@ func NewManager(dbPath string) (*Manager, error) {
 		container: container,
 		log:       waLog.Noop,
 		qrChan:    make(chan string, 1),
-		eventChan: make(chan ConnectionEvent, 10),
+		eventChan: make(chan ConnectionEvent, 100),
 		messageDB: messageDB,
 	}
 
@@ -101,6 +125,21 @@ func NewManager(dbPath string) (*Manager, error) {
 
 	// Initialize auto-reply manager with loaded config
 	manager.autoReply = NewAutoReplyManager(config)
+	manager.reloadReplyRules()
+	manager.reloadReplyProfiles()
+	manager.autoReply.startMemoryRefresh(manager)
+
+	// Embedding local documents can take a while, so the knowledge base gets its own client
+	manager.knowledge = NewKnowledgeBase(messageDB, &http.Client{Timeout: 2 * time.Minute})
+	manager.usage = NewUsageTracker(messageDB)
+	manager.knowledge.meter = manager.meterEmbedder
+
+	// Initialize the approval queue and recover drafts of an interrupted run; their
+	// auto-send timers are armed once connected
+	manager.approvals = NewApprovalQueue(manager)
+	if err := manager.approvals.Restore(); err != nil {
+		fmt.Printf("Failed to restore reply drafts: %v\n", err)
+	}
 
 	// Initialize scheduler
 	manager.scheduler = NewScheduler(manager, log.New(os.Stdout, "[Scheduler] ", log.LstdFlags))
@@ -110,6 +149,11 @@ func NewManager(dbPath string) (*Manager, error) {
 		return nil, fmt.Errorf("failed to start scheduler: %v", err)
 	}
 
+	// Restore tasks saved on the previous shutdown
+	if err := manager.scheduler.LoadState(); err != nil {
+		fmt.Printf("Failed to restore scheduled tasks: %v\n", err)
+	}
+
 	// Setup event handlers
 	client.AddEventHandler(manager.handleEvent)
 
@@ -118,6 +162,8 @@ func NewManager(dbPath string) (*Manager, error) {
 
 // handleEvent handles WhatsApp events including incoming messages
 func (m *Manager) handleEvent(evt interface{}) {
+	defer m.handleRealtimeEvent(evt)
+
 	switch v := evt.(type) {
 	case *events.Message:
 		// Store incoming message in database
@@ -132,17 +178,20 @@ func (m *Manager) handleEvent(evt interface{}) {
 			}
 		}
 
+	case *events.HistorySync:
+		m.handleHistorySync(v)
+
 	case *events.Connected:
-		m.eventChan <- ConnectionEvent{
+		m.emitEvent(ConnectionEvent{
 			Type:    "connected",
 			Message: "WhatsApp connected successfully",
-		}
+		})
 
 	case *events.Disconnected:
-		m.eventChan <- ConnectionEvent{
+		m.emitEvent(ConnectionEvent{
 			Type:    "disconnected",
 			Message: "WhatsApp disconnected",
-		}
+		})
 
 	case *events.QR:
 		// Generate QR code and send to channel
@@ -154,13 +203,16 @@ func (m *Manager) handleEvent(evt interface{}) {
 		}
 
 		qrBase64 := base64.StdEncoding.EncodeToString(png)
-		m.qrChan <- qrBase64
+		select {
+		case m.qrChan <- qrBase64:
+		default:
+		}
 
-		m.eventChan <- ConnectionEvent{
+		m.emitEvent(ConnectionEvent{
 			Type:    "qr",
 			Message: "QR code generated",
 			Data:    qrBase64,
-		}
+		})
 	}
 }
 
@@ -213,19 +265,10 @@ func (m *Manager) ConnectExistingDevice() error {
 		return fmt.Errorf("failed to connect: %v", err)
 	}
 
-	// Wait for connection to be established
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	select {
-	case <-ctx.Done():
+	// Wait for connection to be established. The event channel is shared with the
+	// realtime events the frontend listens to, so it can't be read here.
+	if !m.client.WaitForConnection(30 * time.Second) {
 		return fmt.Errorf("connection timeout")
-	case event := <-m.eventChan:
-		if event.Type == "connected" {
-			return nil
-		} else if event.Type == "error" {
-			return fmt.Errorf("connection error: %s", event.Message)
-		}
 	}
 
 	return nil
@@ -250,6 +293,8 @@ func (m *Manager) StartNewConnection() error {
 
 func (m *Manager) addEventHandlers() {
 	m.client.AddEventHandler(func(evt interface{}) {
+		defer m.handleRealtimeEvent(evt)
+
 		switch v := evt.(type) {
 		case *events.Message:
 			// Store incoming message in database
@@ -262,17 +307,24 @@ func (m *Manager) addEventHandlers() {
 					m.log.Errorf("Failed to process auto-reply: %v", err)
 				}
 			}
+		case *events.HistorySync:
+			m.handleHistorySync(v)
 		case *events.Connected:
-			m.eventChan <- ConnectionEvent{
+			m.emitEvent(ConnectionEvent{
 				Type:    "connected",
 				Message: "Successfully connected to WhatsApp",
-			}
+			})
 		case *events.Disconnected:
-			m.eventChan <- ConnectionEvent{
+			m.emitEvent(ConnectionEvent{
 				Type:    "disconnected",
 				Message: "Disconnected from WhatsApp",
-			}
+			})
 		case *events.QR:
+			// Phone number pairing ignores the QR codes of the login websocket
+			if m.IsPairing() {
+				return
+			}
+
 			qrString := v.Codes[0]
 			m.log.Infof("QR code: %s", qrString)
 
@@ -293,16 +345,16 @@ func (m *Manager) addEventHandlers() {
 			qrBase64 := base64.StdEncoding.EncodeToString(qrPNG)
 			qrDataURL := "data:image/png;base64," + qrBase64
 
-			m.eventChan <- ConnectionEvent{
+			m.emitEvent(ConnectionEvent{
 				Type:    "qr",
 				Message: "QR Code generated",
 				Data:    qrDataURL,
-			}
+			})
 		case *events.PairSuccess:
-			m.eventChan <- ConnectionEvent{
+			m.emitEvent(ConnectionEvent{
 				Type:    "connected",
 				Message: "Device paired successfully",
-			}
+			})
 		}
 	})
 }
@@ -325,20 +377,6 @@ func (m *Manager) generateQRCode(code string) (string, error) {
 	return dataURL, nil
 }
 
-func (m *Manager) RequestPairingCode(phoneNumber string) (string, error) {
-	if m.client == nil {
-		return "", fmt.Errorf("client not initialized")
-	}
-
-	// Request pairing code
-	code, err := m.client.PairPhone(context.Background(), phoneNumber, true, whatsmeow.PairClientChrome, "Chrome (Linux)")
-	if err != nil {
-		return "", fmt.Errorf("failed to request pairing code: %v", err)
-	}
-
-	return code, nil
-}
-
 func (m *Manager) GetConnectionStatus() *ConnectionStatus {
 	if m.client == nil || !m.client.IsConnected() {
 		return &ConnectionStatus{IsConnected: false}
@@ -421,6 +459,43 @@ func (m *Manager) UpdateAutoReplyConfig(config *AutoReplyConfig) error {
 		return fmt.Errorf("database not initialized")
 	}
 
+	if config.MaxResponseLength < 0 || config.MaxReplyParts < 0 {
+		return fmt.Errorf("reply length limits cannot be negative")
+	}
+	if err := config.BusinessHours.Validate(); err != nil {
+		return fmt.Errorf("invalid business hours: %v", err)
+	}
+	if err := config.Approval.Validate(); err != nil {
+		return fmt.Errorf("invalid approval settings: %v", err)
+	}
+	if err := config.Flood.Validate(); err != nil {
+		return fmt.Errorf("invalid flood protection settings: %v", err)
+	}
+	if err := config.Knowledge.Validate(); err != nil {
+		return fmt.Errorf("invalid knowledge base settings: %v", err)
+	}
+	if err := config.Tools.Validate(); err != nil {
+		return fmt.Errorf("invalid tool settings: %v", err)
+	}
+	if err := config.Transcription.Validate(); err != nil {
+		return fmt.Errorf("invalid transcription settings: %v", err)
+	}
+	if err := config.Vision.Validate(); err != nil {
+		return fmt.Errorf("invalid vision settings: %v", err)
+	}
+	if err := config.Usage.Validate(); err != nil {
+		return fmt.Errorf("invalid usage settings: %v", err)
+	}
+	if err := config.Failover.Validate(); err != nil {
+		return fmt.Errorf("invalid failover settings: %v", err)
+	}
+	if err := config.Memory.Validate(); err != nil {
+		return fmt.Errorf("invalid memory settings: %v", err)
+	}
+	if err := config.Intents.Validate(); err != nil {
+		return fmt.Errorf("invalid intent settings: %v", err)
+	}
+
 	// Update the autoReply manager
 	if m.autoReply == nil {
 		m.autoReply = NewAutoReplyManager(config)
@@ -432,22 +507,114 @@ func (m *Manager) UpdateAutoReplyConfig(config *AutoReplyConfig) error {
 	return m.messageDB.SaveConfig(config)
 }
 
+// GetAIProviders returns the names of all registered AI providers
+func (m *Manager) GetAIProviders() []string {
+	return ProviderNames()
+}
+
+// TestAIConnection sends a test message to the given provider using the saved configuration
+func (m *Manager) TestAIConnection(provider string) (string, error) {
+	config := m.GetAutoReplyConfig()
+	if provider == "" {
+		provider = config.AIProvider
+	}
+
+	return m.autoReply.TestProvider(m, provider)
+}
+
 // Contact management methods
 
-func (m *Manager) Close() error {
-	if m.client != nil {
-		m.client.Disconnect()
+// Shutdown drains pending work until ctx expires, then persists state and releases resources.
+// Auto-replies are drained first since they may still send messages, then the scheduler,
+// then any in-flight sends. It is safe to call more than once.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.closeOnce.Do(func() {
+		m.closeErr = m.shutdown(ctx)
+	})
+	return m.closeErr
+}
+
+func (m *Manager) shutdown(ctx context.Context) error {
+	var errs []string
+
+	// Stop accepting auto-replies and cancel the ones still running at the deadline
+	if m.autoReply != nil {
+		if err := m.autoReply.Stop(ctx); err != nil {
+			errs = append(errs, err.Error())
+		}
 	}
 
-	// Stop scheduler
+	// Pending drafts stay queued, their auto-send timers are re-armed on the next start.
+	// Auto-sends already running are waited for so their drafts are marked as sent.
+	if m.approvals != nil {
+		if !waitWithContext(ctx, m.approvals.Stop) {
+			errs = append(errs, "auto-sent drafts did not finish in time")
+		}
+	}
+
+	// Stop scheduler, waiting for running tasks, and persist its state
 	if m.scheduler != nil {
-		m.scheduler.Stop()
+		if !waitWithContext(ctx, m.scheduler.Stop) {
+			errs = append(errs, "scheduler did not stop in time")
+		}
+		if err := m.scheduler.SaveState(); err != nil {
+			errs = append(errs, fmt.Sprintf("failed to save scheduler state: %v", err))
+		}
+	}
+
+	// Reject new sends and wait for the in-flight ones
+	m.sendMu.Lock()
+	m.closing = true
+	m.sendMu.Unlock()
+	if !waitWithContext(ctx, m.sendWG.Wait) {
+		errs = append(errs, "pending messages were not sent in time")
+	}
+
+	if m.client != nil {
+		m.client.Disconnect()
 	}
 
 	// Close message database
 	if m.messageDB != nil {
-		m.messageDB.Close()
+		if err := m.messageDB.Close(); err != nil {
+			errs = append(errs, fmt.Sprintf("failed to close message database: %v", err))
+		}
 	}
 
+	if err := m.container.Close(); err != nil {
+		errs = append(errs, fmt.Sprintf("failed to close session store: %v", err))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("shutdown incomplete: %s", strings.Join(errs, "; "))
+	}
 	return nil
 }
+
+// trackSend registers a send so shutdown waits for it. It returns false once shutting down.
+func (m *Manager) trackSend() bool {
+	m.sendMu.Lock()
+	defer m.sendMu.Unlock()
+
+	if m.closing {
+		return false
+	}
+	m.sendWG.Add(1)
+	return true
+}
+
+// waitWithContext runs fn and waits for it to return, giving up when ctx expires
+func waitWithContext(ctx context.Context, fn func()) bool {
+	done := make(chan struct{})
+	go func() {
+		fn()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
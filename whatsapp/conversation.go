@@ -0,0 +1,118 @@
+package whatsapp
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// ChatMessage is a single turn of the conversation sent to the AI provider
+type ChatMessage struct {
+	Role    string `json:"role"` // "system", "user", "assistant" or "tool"
+	Content string `json:"content"`
+
+	// Function calling: the calls an assistant turn made, or the call a tool turn answers
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+	Name       string     `json:"name,omitempty"` // tool name of a tool turn
+
+	// Images of a user turn, for vision-capable models
+	Images []ChatImage `json:"-"`
+}
+
+// estimateTokens roughly estimates the token count of a text (about 4 characters per token)
+func estimateTokens(text string) int {
+	return utf8.RuneCountInString(text)/4 + 1
+}
+
+// buildConversation builds the messages sent to the AI: the system prompt, recent turns
+// of the chat from the message database, and the incoming message. Our own messages
+// become assistant turns. Older turns are dropped to stay within the token budget.
+// In group chats senderName resolves participant names, which prefix the user turns.
+// A non-zero before (unix seconds) limits the history to messages sent until then.
+func buildConversation(config *AutoReplyConfig, db *MessageDB, chatJID, currentMessageID, messageText string, before int64, senderName func(jid string) string) []ChatMessage {
+	system := ChatMessage{Role: "system", Content: config.SystemPrompt}
+	if senderName != nil {
+		system.Content = strings.TrimSpace(system.Content + "\n\n" + groupSystemPrompt)
+	}
+	current := ChatMessage{Role: "user", Content: messageText}
+
+	history := loadHistory(config, db, chatJID, currentMessageID, before, senderName)
+
+	// Drop the oldest turns until the conversation fits the budget
+	if budget := config.ContextTokenBudget; budget > 0 {
+		used := estimateTokens(system.Content) + estimateTokens(current.Content)
+		keep := 0
+		for i := len(history) - 1; i >= 0; i-- {
+			used += estimateTokens(history[i].Content)
+			if used > budget {
+				break
+			}
+			keep++
+		}
+		history = history[len(history)-keep:]
+	}
+
+	messages := make([]ChatMessage, 0, len(history)+2)
+	messages = append(messages, system)
+	messages = append(messages, history...)
+	messages = append(messages, current)
+	return messages
+}
+
+// loadHistory returns up to ContextTurns previous messages of the chat, oldest first
+func loadHistory(config *AutoReplyConfig, db *MessageDB, chatJID, currentMessageID string, before int64, senderName func(jid string) string) []ChatMessage {
+	if db == nil || config.ContextTurns <= 0 {
+		return nil
+	}
+
+	// Fetch one extra row since the incoming message is already stored
+	var stored []StoredMessage
+	var err error
+	if before > 0 {
+		stored, err = db.GetChatMessagesBefore(chatJID, before, config.ContextTurns+1)
+	} else {
+		stored, err = db.GetChatMessages(chatJID, config.ContextTurns+1, 0)
+	}
+	if err != nil {
+		return nil
+	}
+
+	names := make(map[string]string)
+
+	var history []ChatMessage
+	for i := len(stored) - 1; i >= 0; i-- {
+		msg := stored[i]
+		if msg.ID == currentMessageID {
+			continue
+		}
+
+		content := strings.TrimSpace(msg.Content)
+		if msg.Transcript != "" {
+			content = msg.Transcript
+		}
+		if msg.Caption != "" {
+			content = strings.TrimSpace(content + " " + msg.Caption)
+		}
+		if content == "" {
+			continue
+		}
+
+		role := "user"
+		if msg.IsFromMe {
+			role = "assistant"
+		} else if senderName != nil {
+			name, ok := names[msg.SenderJID]
+			if !ok {
+				name = senderName(msg.SenderJID)
+				names[msg.SenderJID] = name
+			}
+			content = name + ": " + content
+		}
+		history = append(history, ChatMessage{Role: role, Content: content})
+	}
+
+	if len(history) > config.ContextTurns {
+		history = history[len(history)-config.ContextTurns:]
+	}
+	return history
+}
@@ -2,7 +2,9 @@ package whatsapp
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -87,110 +89,1265 @@ func (m *MessageDB) createTables() error {
 			phone_number TEXT PRIMARY KEY,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)`,
+		`CREATE TABLE IF NOT EXISTS provider_settings (
+			provider TEXT PRIMARY KEY,
+			base_url TEXT,
+			api_key TEXT,
+			model TEXT,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS scheduled_tasks (
+			id TEXT PRIMARY KEY,
+			data TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS history_sync_config (
+			id TEXT PRIMARY KEY,
+			enabled BOOLEAN NOT NULL DEFAULT 1,
+			max_days INTEGER NOT NULL DEFAULT 0,
+			max_messages_per_chat INTEGER NOT NULL DEFAULT 0,
+			include_groups BOOLEAN NOT NULL DEFAULT 1,
+			include_archived BOOLEAN NOT NULL DEFAULT 1,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS config_sections (
+			section TEXT PRIMARY KEY,
+			data TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS away_replies (
+			chat_jid TEXT PRIMARY KEY,
+			sent_at INTEGER NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS reply_drafts (
+			id TEXT PRIMARY KEY,
+			chat_jid TEXT NOT NULL,
+			chat_name TEXT,
+			message_id TEXT,
+			question TEXT,
+			text TEXT NOT NULL,
+			status TEXT NOT NULL,
+			created_at INTEGER NOT NULL,
+			expires_at INTEGER NOT NULL DEFAULT 0,
+			updated_at INTEGER NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS reply_profiles (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			data TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS contact_tags (
+			jid TEXT NOT NULL,
+			tag TEXT NOT NULL,
+			PRIMARY KEY (jid, tag)
+		)`,
+		`CREATE TABLE IF NOT EXISTS chat_pauses (
+			chat_jid TEXT PRIMARY KEY,
+			paused_until INTEGER NOT NULL DEFAULT 0,
+			reason TEXT,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS reply_rules (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT,
+			match_type TEXT NOT NULL,
+			pattern TEXT NOT NULL,
+			case_sensitive BOOLEAN NOT NULL DEFAULT 0,
+			scope TEXT NOT NULL DEFAULT 'all',
+			scope_value TEXT,
+			priority INTEGER NOT NULL DEFAULT 0,
+			response_text TEXT,
+			media_path TEXT,
+			media_type TEXT,
+			action TEXT NOT NULL DEFAULT 'stop',
+			enabled BOOLEAN NOT NULL DEFAULT 1,
+			hit_count INTEGER NOT NULL DEFAULT 0,
+			last_hit_at INTEGER NOT NULL DEFAULT 0,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS knowledge_documents (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			file_type TEXT NOT NULL,
+			size INTEGER NOT NULL DEFAULT 0,
+			chunk_count INTEGER NOT NULL DEFAULT 0,
+			embedding_model TEXT NOT NULL,
+			created_at INTEGER NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS knowledge_chunks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			document_id INTEGER NOT NULL,
+			chunk_index INTEGER NOT NULL,
+			content TEXT NOT NULL,
+			embedding BLOB NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_knowledge_chunks_document ON knowledge_chunks(document_id)`,
+		`CREATE TABLE IF NOT EXISTS reply_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_jid TEXT NOT NULL,
+			message_id TEXT,
+			question TEXT,
+			response TEXT NOT NULL,
+			sources TEXT,
+			created_at INTEGER NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_reply_log_chat_jid ON reply_log(chat_jid)`,
+		`CREATE TABLE IF NOT EXISTS tool_audit (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_jid TEXT NOT NULL,
+			message_id TEXT,
+			tool TEXT NOT NULL,
+			arguments TEXT,
+			result TEXT,
+			error TEXT,
+			duration_ms INTEGER NOT NULL DEFAULT 0,
+			created_at INTEGER NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_tool_audit_chat_jid ON tool_audit(chat_jid)`,
+		`CREATE TABLE IF NOT EXISTS ai_usage (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			provider TEXT NOT NULL,
+			model TEXT,
+			purpose TEXT NOT NULL,
+			chat_jid TEXT,
+			prompt_tokens INTEGER NOT NULL DEFAULT 0,
+			completion_tokens INTEGER NOT NULL DEFAULT 0,
+			latency_ms INTEGER NOT NULL DEFAULT 0,
+			outcome TEXT NOT NULL,
+			error TEXT,
+			cost REAL NOT NULL DEFAULT 0,
+			created_at INTEGER NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_ai_usage_created_at ON ai_usage(created_at)`,
+		`CREATE TABLE IF NOT EXISTS reply_decisions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_jid TEXT NOT NULL,
+			message_id TEXT NOT NULL,
+			outcome TEXT NOT NULL,
+			data TEXT NOT NULL,
+			created_at INTEGER NOT NULL,
+			updated_at INTEGER NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_reply_decisions_chat_jid ON reply_decisions(chat_jid)`,
+		`CREATE TABLE IF NOT EXISTS chat_memory (
+			chat_jid TEXT PRIMARY KEY,
+			summary TEXT,
+			facts TEXT NOT NULL,
+			last_message_at INTEGER NOT NULL DEFAULT 0,
+			edited_at INTEGER NOT NULL DEFAULT 0,
+			updated_at INTEGER NOT NULL
+		)`,
+	}
+
+	for _, query := range queries {
+		if _, err := m.db.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %v", err)
+		}
+	}
+
+	return m.migrateTables()
+}
+
+// migrateTables adds columns introduced after the initial schema to existing databases
+func (m *MessageDB) migrateTables() error {
+	columns := []struct {
+		table      string
+		column     string
+		definition string
+	}{
+		{"chats", "archived", "BOOLEAN NOT NULL DEFAULT 0"},
+		{"chats", "pinned", "BOOLEAN NOT NULL DEFAULT 0"},
+		{"chats", "muted_until", "INTEGER NOT NULL DEFAULT 0"},
+		{"config", "context_turns", "INTEGER NOT NULL DEFAULT 10"},
+		{"config", "context_token_budget", "INTEGER NOT NULL DEFAULT 2000"},
+		{"config", "max_reply_parts", "INTEGER NOT NULL DEFAULT 3"},
+		{"messages", "transcript", "TEXT"},
+		{"messages", "intent", "TEXT"},
+		{"reply_rules", "intent", "TEXT"},
+	}
+
+	for _, c := range columns {
+		exists, err := m.columnExists(c.table, c.column)
+		if err != nil {
+			return fmt.Errorf("failed to inspect table %s: %v", c.table, err)
+		}
+		if exists {
+			continue
+		}
+
+		query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.column, c.definition)
+		if _, err := m.db.Exec(query); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %v", c.table, c.column, err)
+		}
+	}
+
+	return nil
+}
+
+// columnExists checks whether a column exists in a table
+func (m *MessageDB) columnExists(table, column string) (bool, error) {
+	rows, err := m.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
+}
+
+// StoredMessage represents a message stored in database
+// SaveConfig saves the auto-reply configuration to the database
+func (m *MessageDB) SaveConfig(config *AutoReplyConfig) error {
+	// First, clear existing whitelist numbers
+	_, err := m.db.Exec("DELETE FROM whitelist_numbers")
+	if err != nil {
+		return fmt.Errorf("failed to clear whitelist numbers: %v", err)
+	}
+
+	// Insert new whitelist numbers
+	for _, number := range config.WhitelistNumbers {
+		_, err := m.db.Exec("INSERT INTO whitelist_numbers (phone_number) VALUES (?)", number)
+		if err != nil {
+			return fmt.Errorf("failed to insert whitelist number: %v", err)
+		}
+	}
+
+	// Save main configuration
+	_, err = m.db.Exec(`
+		INSERT OR REPLACE INTO config (
+			id, enabled, ai_provider, openai_api_key, openai_model,
+			ollama_url, ollama_model, system_prompt,
+			response_delay, max_response_length,
+			context_turns, context_token_budget, max_reply_parts, updated_at
+		) VALUES (
+			'default', ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP
+		)`,
+		config.Enabled,
+		config.AIProvider,
+		config.OpenAIAPIKey,
+		config.OpenAIModel,
+		config.OllamaURL,
+		config.OllamaModel,
+		config.SystemPrompt,
+		config.ResponseDelay,
+		config.MaxResponseLength,
+		config.ContextTurns,
+		config.ContextTokenBudget,
+		config.MaxReplyParts,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to save config: %v", err)
+	}
+
+	// Save the config sections of the additional providers
+	for provider, settings := range config.providerSections() {
+		_, err := m.db.Exec(`
+			INSERT OR REPLACE INTO provider_settings (provider, base_url, api_key, model, updated_at)
+			VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+			provider, settings.BaseURL, settings.APIKey, settings.Model)
+		if err != nil {
+			return fmt.Errorf("failed to save %s settings: %v", provider, err)
+		}
+	}
+
+	// Save the structured config sections as JSON
+	for section, value := range config.configSections() {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s config: %v", section, err)
+		}
+		_, err = m.db.Exec(`
+			INSERT OR REPLACE INTO config_sections (section, data, updated_at)
+			VALUES (?, ?, CURRENT_TIMESTAMP)`,
+			section, string(data))
+		if err != nil {
+			return fmt.Errorf("failed to save %s config: %v", section, err)
+		}
+	}
+
+	return nil
+}
+
+// LoadConfig loads the auto-reply configuration from the database
+func (m *MessageDB) LoadConfig() (*AutoReplyConfig, error) {
+	var config AutoReplyConfig
+
+	// Load main configuration
+	err := m.db.QueryRow(`
+		SELECT 
+			enabled, ai_provider, openai_api_key, openai_model,
+			ollama_url, ollama_model, system_prompt,
+			response_delay, max_response_length,
+			context_turns, context_token_budget, max_reply_parts
+		FROM config 
+		WHERE id = 'default'
+	`).Scan(
+		&config.Enabled,
+		&config.AIProvider,
+		&config.OpenAIAPIKey,
+		&config.OpenAIModel,
+		&config.OllamaURL,
+		&config.OllamaModel,
+		&config.SystemPrompt,
+		&config.ResponseDelay,
+		&config.MaxResponseLength,
+		&config.ContextTurns,
+		&config.ContextTokenBudget,
+		&config.MaxReplyParts,
+	)
+
+	if err == sql.ErrNoRows {
+		// Return default config if no configuration exists
+		return GetDefaultAutoReplyConfig(), nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to load config: %v", err)
+	}
+
+	// Load whitelist numbers
+	rows, err := m.db.Query("SELECT phone_number FROM whitelist_numbers")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load whitelist numbers: %v", err)
+	}
+	defer rows.Close()
+
+	var numbers []string
+	for rows.Next() {
+		var number string
+		if err := rows.Scan(&number); err != nil {
+			return nil, fmt.Errorf("failed to scan whitelist number: %v", err)
+		}
+		numbers = append(numbers, number)
+	}
+
+	config.WhitelistNumbers = numbers
+
+	// Load provider sections, keeping defaults for providers never saved
+	defaults := GetDefaultAutoReplyConfig()
+	config.OpenAICompatible = defaults.OpenAICompatible
+	config.Anthropic = defaults.Anthropic
+	config.Gemini = defaults.Gemini
+
+	settingsRows, err := m.db.Query("SELECT provider, base_url, api_key, model FROM provider_settings")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load provider settings: %v", err)
+	}
+	defer settingsRows.Close()
+
+	sections := config.providerSections()
+	for settingsRows.Next() {
+		var provider string
+		var baseURL, apiKey, model sql.NullString
+		if err := settingsRows.Scan(&provider, &baseURL, &apiKey, &model); err != nil {
+			return nil, fmt.Errorf("failed to scan provider settings: %v", err)
+		}
+		if section, ok := sections[provider]; ok {
+			*section = ProviderSettings{BaseURL: baseURL.String, APIKey: apiKey.String, Model: model.String}
+		}
+	}
+
+	// Load the structured config sections, keeping defaults for sections never saved
+	config.BusinessHours = defaults.BusinessHours
+	config.GroupReply = defaults.GroupReply
+	config.Approval = defaults.Approval
+	config.Takeover = defaults.Takeover
+	config.Flood = defaults.Flood
+	config.Knowledge = defaults.Knowledge
+	config.Tools = defaults.Tools
+	config.Transcription = defaults.Transcription
+	config.Vision = defaults.Vision
+	config.Usage = defaults.Usage
+	config.Streaming = defaults.Streaming
+	config.Failover = defaults.Failover
+	config.Memory = defaults.Memory
+	config.Intents = defaults.Intents
+
+	sectionRows, err := m.db.Query("SELECT section, data FROM config_sections")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config sections: %v", err)
+	}
+	defer sectionRows.Close()
+
+	configSections := config.configSections()
+	for sectionRows.Next() {
+		var section, data string
+		if err := sectionRows.Scan(&section, &data); err != nil {
+			return nil, fmt.Errorf("failed to scan config section: %v", err)
+		}
+		if value, ok := configSections[section]; ok {
+			if err := json.Unmarshal([]byte(data), value); err != nil {
+				return nil, fmt.Errorf("failed to parse %s config: %v", section, err)
+			}
+		}
+	}
+
+	return &config, nil
+}
+
+// SaveHistorySyncConfig saves the history sync limits to the database
+func (m *MessageDB) SaveHistorySyncConfig(config *HistorySyncConfig) error {
+	_, err := m.db.Exec(`
+		INSERT OR REPLACE INTO history_sync_config (
+			id, enabled, max_days, max_messages_per_chat, include_groups, include_archived, updated_at
+		) VALUES (
+			'default', ?, ?, ?, ?, ?, CURRENT_TIMESTAMP
+		)`,
+		config.Enabled,
+		config.MaxDays,
+		config.MaxMessagesPerChat,
+		config.IncludeGroups,
+		config.IncludeArchived,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save history sync config: %v", err)
+	}
+
+	return nil
+}
+
+// LoadHistorySyncConfig loads the history sync limits from the database
+func (m *MessageDB) LoadHistorySyncConfig() (*HistorySyncConfig, error) {
+	var config HistorySyncConfig
+
+	err := m.db.QueryRow(`
+		SELECT enabled, max_days, max_messages_per_chat, include_groups, include_archived
+		FROM history_sync_config 
+		WHERE id = 'default'
+	`).Scan(
+		&config.Enabled,
+		&config.MaxDays,
+		&config.MaxMessagesPerChat,
+		&config.IncludeGroups,
+		&config.IncludeArchived,
+	)
+
+	if err == sql.ErrNoRows {
+		return GetDefaultHistorySyncConfig(), nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to load history sync config: %v", err)
+	}
+
+	return &config, nil
+}
+
+// SaveScheduledTasks replaces the stored scheduler state with the given tasks
+func (m *MessageDB) SaveScheduledTasks(tasks []*ScheduledTask) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM scheduled_tasks"); err != nil {
+		return fmt.Errorf("failed to clear scheduled tasks: %v", err)
+	}
+
+	for _, task := range tasks {
+		data, err := json.Marshal(task)
+		if err != nil {
+			return fmt.Errorf("failed to marshal task %s: %v", task.ID, err)
+		}
+		if _, err := tx.Exec("INSERT INTO scheduled_tasks (id, data) VALUES (?, ?)", task.ID, string(data)); err != nil {
+			return fmt.Errorf("failed to save task %s: %v", task.ID, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// LoadScheduledTasks loads the stored scheduler state
+func (m *MessageDB) LoadScheduledTasks() ([]*ScheduledTask, error) {
+	rows, err := m.db.Query("SELECT data FROM scheduled_tasks")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load scheduled tasks: %v", err)
+	}
+	defer rows.Close()
+
+	var tasks []*ScheduledTask
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to scan scheduled task: %v", err)
+		}
+
+		var task ScheduledTask
+		if err := json.Unmarshal([]byte(data), &task); err != nil {
+			return nil, fmt.Errorf("failed to parse scheduled task: %v", err)
+		}
+		tasks = append(tasks, &task)
+	}
+
+	return tasks, rows.Err()
+}
+
+// GetAwayReplyTime returns when the last away message was sent to a chat, 0 if never
+func (m *MessageDB) GetAwayReplyTime(chatJID string) (int64, error) {
+	var sentAt int64
+	err := m.db.QueryRow("SELECT sent_at FROM away_replies WHERE chat_jid = ?", chatJID).Scan(&sentAt)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return sentAt, err
+}
+
+// SetAwayReplyTime records that an away message was sent to a chat
+func (m *MessageDB) SetAwayReplyTime(chatJID string, at time.Time) error {
+	_, err := m.db.Exec("INSERT OR REPLACE INTO away_replies (chat_jid, sent_at) VALUES (?, ?)", chatJID, at.Unix())
+	return err
+}
+
+// SaveReplyDraft stores a new reply draft
+func (m *MessageDB) SaveReplyDraft(draft *ReplyDraft) error {
+	_, err := m.db.Exec(`INSERT INTO reply_drafts
+		(id, chat_jid, chat_name, message_id, question, text, status, created_at, expires_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		draft.ID, draft.ChatJID, draft.ChatName, draft.MessageID, draft.Question, draft.Text,
+		draft.Status, draft.CreatedAt, draft.ExpiresAt, draft.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save reply draft: %v", err)
+	}
+	return nil
+}
+
+// GetReplyDraft returns a single reply draft
+func (m *MessageDB) GetReplyDraft(id string) (*ReplyDraft, error) {
+	row := m.db.QueryRow(`SELECT id, chat_jid, COALESCE(chat_name, ''), COALESCE(message_id, ''),
+		COALESCE(question, ''), text, status, created_at, expires_at, updated_at
+		FROM reply_drafts WHERE id = ?`, id)
+
+	var draft ReplyDraft
+	err := row.Scan(&draft.ID, &draft.ChatJID, &draft.ChatName, &draft.MessageID,
+		&draft.Question, &draft.Text, &draft.Status, &draft.CreatedAt, &draft.ExpiresAt, &draft.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("reply draft %s not found", id)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to load reply draft: %v", err)
+	}
+	return &draft, nil
+}
+
+// GetReplyDrafts returns the drafts with the given status, newest first. An empty status returns all drafts.
+func (m *MessageDB) GetReplyDrafts(status string) ([]ReplyDraft, error) {
+	query := `SELECT id, chat_jid, COALESCE(chat_name, ''), COALESCE(message_id, ''),
+		COALESCE(question, ''), text, status, created_at, expires_at, updated_at
+		FROM reply_drafts`
+	var args []interface{}
+	if status != "" {
+		query += " WHERE status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY created_at DESC"
+
+	rows, err := m.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load reply drafts: %v", err)
+	}
+	defer rows.Close()
+
+	drafts := []ReplyDraft{}
+	for rows.Next() {
+		var draft ReplyDraft
+		err := rows.Scan(&draft.ID, &draft.ChatJID, &draft.ChatName, &draft.MessageID,
+			&draft.Question, &draft.Text, &draft.Status, &draft.CreatedAt, &draft.ExpiresAt, &draft.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan reply draft: %v", err)
+		}
+		drafts = append(drafts, draft)
+	}
+
+	return drafts, rows.Err()
+}
+
+// UpdateReplyDraftStatus moves a draft from one status to another and reports whether it was in the expected status
+func (m *MessageDB) UpdateReplyDraftStatus(id, from, to, text string) (bool, error) {
+	result, err := m.db.Exec("UPDATE reply_drafts SET status = ?, text = ?, updated_at = ? WHERE id = ? AND status = ?",
+		to, text, time.Now().Unix(), id, from)
+	if err != nil {
+		return false, fmt.Errorf("failed to update reply draft: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// GetReplyProfiles returns all reply profiles
+func (m *MessageDB) GetReplyProfiles() ([]ReplyProfile, error) {
+	rows, err := m.db.Query("SELECT id, data FROM reply_profiles ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load reply profiles: %v", err)
+	}
+	defer rows.Close()
+
+	profiles := []ReplyProfile{}
+	for rows.Next() {
+		var id int64
+		var data string
+		if err := rows.Scan(&id, &data); err != nil {
+			return nil, fmt.Errorf("failed to scan reply profile: %v", err)
+		}
+
+		var profile ReplyProfile
+		if err := json.Unmarshal([]byte(data), &profile); err != nil {
+			return nil, fmt.Errorf("failed to parse reply profile %d: %v", id, err)
+		}
+		profile.ID = id
+		profiles = append(profiles, profile)
+	}
+
+	return profiles, rows.Err()
+}
+
+// SaveReplyProfile inserts a new profile or updates an existing one, setting profile.ID on insert
+func (m *MessageDB) SaveReplyProfile(profile *ReplyProfile) error {
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return fmt.Errorf("failed to marshal reply profile: %v", err)
+	}
+
+	if profile.ID == 0 {
+		result, err := m.db.Exec("INSERT INTO reply_profiles (data) VALUES (?)", string(data))
+		if err != nil {
+			return fmt.Errorf("failed to save reply profile: %v", err)
+		}
+		profile.ID, err = result.LastInsertId()
+		return err
+	}
+
+	result, err := m.db.Exec("UPDATE reply_profiles SET data = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", string(data), profile.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update reply profile: %v", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("reply profile %d not found", profile.ID)
+	}
+	return nil
+}
+
+// DeleteReplyProfile removes a reply profile
+func (m *MessageDB) DeleteReplyProfile(id int64) error {
+	if _, err := m.db.Exec("DELETE FROM reply_profiles WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete reply profile: %v", err)
+	}
+	return nil
+}
+
+// GetContactTags returns the tags of a contact
+func (m *MessageDB) GetContactTags(jid string) ([]string, error) {
+	rows, err := m.db.Query("SELECT tag FROM contact_tags WHERE jid = ? ORDER BY tag", jid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load contact tags: %v", err)
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, fmt.Errorf("failed to scan contact tag: %v", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// SetContactTags replaces the tags of a contact
+func (m *MessageDB) SetContactTags(jid string, tags []string) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM contact_tags WHERE jid = ?", jid); err != nil {
+		return fmt.Errorf("failed to clear contact tags: %v", err)
+	}
+	for _, tag := range tags {
+		if _, err := tx.Exec("INSERT INTO contact_tags (jid, tag) VALUES (?, ?)", jid, tag); err != nil {
+			return fmt.Errorf("failed to save contact tag: %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetChatPause returns the auto-reply pause of a chat, nil if it was never paused
+func (m *MessageDB) GetChatPause(chatJID string) (*ChatPause, error) {
+	pause := ChatPause{ChatJID: chatJID, Paused: true}
+	err := m.db.QueryRow("SELECT paused_until, COALESCE(reason, '') FROM chat_pauses WHERE chat_jid = ?", chatJID).
+		Scan(&pause.PausedUntil, &pause.Reason)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to load chat pause: %v", err)
+	}
+	return &pause, nil
+}
+
+// SaveChatPause stores the auto-reply pause of a chat
+func (m *MessageDB) SaveChatPause(pause *ChatPause) error {
+	_, err := m.db.Exec(`INSERT OR REPLACE INTO chat_pauses (chat_jid, paused_until, reason, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)`, pause.ChatJID, pause.PausedUntil, pause.Reason)
+	if err != nil {
+		return fmt.Errorf("failed to save chat pause: %v", err)
+	}
+	return nil
+}
+
+// DeleteChatPause lifts the auto-reply pause of a chat
+func (m *MessageDB) DeleteChatPause(chatJID string) error {
+	if _, err := m.db.Exec("DELETE FROM chat_pauses WHERE chat_jid = ?", chatJID); err != nil {
+		return fmt.Errorf("failed to delete chat pause: %v", err)
+	}
+	return nil
+}
+
+// GetActiveChatPauses returns the pauses that have not expired at the given time
+func (m *MessageDB) GetActiveChatPauses(now time.Time) ([]ChatPause, error) {
+	rows, err := m.db.Query(`SELECT chat_jid, paused_until, COALESCE(reason, '') FROM chat_pauses
+		WHERE paused_until = 0 OR paused_until > ? ORDER BY updated_at DESC`, now.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chat pauses: %v", err)
+	}
+	defer rows.Close()
+
+	pauses := []ChatPause{}
+	for rows.Next() {
+		pause := ChatPause{Paused: true}
+		if err := rows.Scan(&pause.ChatJID, &pause.PausedUntil, &pause.Reason); err != nil {
+			return nil, fmt.Errorf("failed to scan chat pause: %v", err)
+		}
+		pauses = append(pauses, pause)
+	}
+
+	return pauses, rows.Err()
+}
+
+// GetReplyRules returns all reply rules ordered by priority
+func (m *MessageDB) GetReplyRules() ([]ReplyRule, error) {
+	rows, err := m.db.Query(`SELECT id, COALESCE(name, ''), match_type, pattern, case_sensitive, scope,
+		COALESCE(scope_value, ''), priority, COALESCE(response_text, ''), COALESCE(media_path, ''),
+		COALESCE(media_type, ''), action, COALESCE(intent, ''), enabled, hit_count, last_hit_at
+		FROM reply_rules ORDER BY priority DESC, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load reply rules: %v", err)
+	}
+	defer rows.Close()
+
+	rules := []ReplyRule{}
+	for rows.Next() {
+		var rule ReplyRule
+		err := rows.Scan(&rule.ID, &rule.Name, &rule.MatchType, &rule.Pattern, &rule.CaseSensitive, &rule.Scope,
+			&rule.ScopeValue, &rule.Priority, &rule.ResponseText, &rule.MediaPath,
+			&rule.MediaType, &rule.Action, &rule.Intent, &rule.Enabled, &rule.HitCount, &rule.LastHitAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan reply rule: %v", err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+// SaveReplyRule inserts a new rule or updates an existing one, setting rule.ID on insert
+func (m *MessageDB) SaveReplyRule(rule *ReplyRule) error {
+	if rule.ID == 0 {
+		result, err := m.db.Exec(`INSERT INTO reply_rules
+			(name, match_type, pattern, case_sensitive, scope, scope_value, priority,
+			 response_text, media_path, media_type, action, intent, enabled)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			rule.Name, rule.MatchType, rule.Pattern, rule.CaseSensitive, rule.Scope, rule.ScopeValue, rule.Priority,
+			rule.ResponseText, rule.MediaPath, rule.MediaType, rule.Action, rule.Intent, rule.Enabled)
+		if err != nil {
+			return fmt.Errorf("failed to save reply rule: %v", err)
+		}
+		rule.ID, err = result.LastInsertId()
+		return err
+	}
+
+	result, err := m.db.Exec(`UPDATE reply_rules SET
+		name = ?, match_type = ?, pattern = ?, case_sensitive = ?, scope = ?, scope_value = ?, priority = ?,
+		response_text = ?, media_path = ?, media_type = ?, action = ?, intent = ?, enabled = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		rule.Name, rule.MatchType, rule.Pattern, rule.CaseSensitive, rule.Scope, rule.ScopeValue, rule.Priority,
+		rule.ResponseText, rule.MediaPath, rule.MediaType, rule.Action, rule.Intent, rule.Enabled, rule.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update reply rule: %v", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("reply rule %d not found", rule.ID)
+	}
+
+	return nil
+}
+
+// DeleteReplyRule removes a reply rule
+func (m *MessageDB) DeleteReplyRule(id int64) error {
+	if _, err := m.db.Exec("DELETE FROM reply_rules WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete reply rule: %v", err)
+	}
+	return nil
+}
+
+// RecordReplyRuleHit increments the hit counter of a rule
+func (m *MessageDB) RecordReplyRuleHit(id int64, at time.Time) error {
+	_, err := m.db.Exec("UPDATE reply_rules SET hit_count = hit_count + 1, last_hit_at = ? WHERE id = ?", at.Unix(), id)
+	return err
+}
+
+// SaveKnowledgeDocument stores a document with its chunks, setting doc.ID
+func (m *MessageDB) SaveKnowledgeDocument(doc *KnowledgeDocument, chunks []KnowledgeChunk) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT INTO knowledge_documents
+		(name, file_type, size, chunk_count, embedding_model, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		doc.Name, doc.FileType, doc.Size, doc.ChunkCount, doc.EmbeddingModel, doc.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save knowledge document: %v", err)
+	}
+	if doc.ID, err = result.LastInsertId(); err != nil {
+		return err
+	}
+
+	for _, chunk := range chunks {
+		_, err := tx.Exec(`INSERT INTO knowledge_chunks (document_id, chunk_index, content, embedding)
+			VALUES (?, ?, ?, ?)`,
+			doc.ID, chunk.Index, chunk.Content, encodeVector(chunk.Embedding))
+		if err != nil {
+			return fmt.Errorf("failed to save knowledge chunk: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit knowledge document: %v", err)
+	}
+	return nil
+}
+
+// GetKnowledgeDocuments returns all knowledge base documents, newest first
+func (m *MessageDB) GetKnowledgeDocuments() ([]KnowledgeDocument, error) {
+	rows, err := m.db.Query(`SELECT id, name, file_type, size, chunk_count, embedding_model, created_at
+		FROM knowledge_documents ORDER BY created_at DESC, id DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load knowledge documents: %v", err)
+	}
+	defer rows.Close()
+
+	docs := []KnowledgeDocument{}
+	for rows.Next() {
+		var doc KnowledgeDocument
+		if err := rows.Scan(&doc.ID, &doc.Name, &doc.FileType, &doc.Size, &doc.ChunkCount, &doc.EmbeddingModel, &doc.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan knowledge document: %v", err)
+		}
+		docs = append(docs, doc)
+	}
+
+	return docs, rows.Err()
+}
+
+// DeleteKnowledgeDocument removes a document and its chunks
+func (m *MessageDB) DeleteKnowledgeDocument(id int64) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM knowledge_chunks WHERE document_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete knowledge chunks: %v", err)
+	}
+	if _, err := tx.Exec("DELETE FROM knowledge_documents WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete knowledge document: %v", err)
+	}
+	return tx.Commit()
+}
+
+// GetKnowledgeChunks returns the chunks embedded with the given model
+func (m *MessageDB) GetKnowledgeChunks(model string) ([]KnowledgeChunk, error) {
+	rows, err := m.db.Query(`SELECT c.id, c.document_id, d.name, c.chunk_index, c.content, c.embedding
+		FROM knowledge_chunks c JOIN knowledge_documents d ON d.id = c.document_id
+		WHERE d.embedding_model = ?`, model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load knowledge chunks: %v", err)
+	}
+	defer rows.Close()
+
+	chunks := []KnowledgeChunk{}
+	for rows.Next() {
+		var chunk KnowledgeChunk
+		var embedding []byte
+		if err := rows.Scan(&chunk.ID, &chunk.DocumentID, &chunk.DocumentName, &chunk.Index, &chunk.Content, &embedding); err != nil {
+			return nil, fmt.Errorf("failed to scan knowledge chunk: %v", err)
+		}
+		chunk.Embedding = decodeVector(embedding)
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks, rows.Err()
+}
+
+// AddReplyLogEntry appends an AI reply to the reply log, setting entry.ID
+func (m *MessageDB) AddReplyLogEntry(entry *ReplyLogEntry) error {
+	sources, err := json.Marshal(entry.Sources)
+	if err != nil {
+		return fmt.Errorf("failed to marshal reply sources: %v", err)
+	}
+
+	result, err := m.db.Exec(`INSERT INTO reply_log (chat_jid, message_id, question, response, sources, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		entry.ChatJID, entry.MessageID, entry.Question, entry.Response, string(sources), entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save reply log entry: %v", err)
+	}
+	entry.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetReplyLog returns the latest reply log entries, of one chat when chatJID is set
+func (m *MessageDB) GetReplyLog(chatJID string, limit int) ([]ReplyLogEntry, error) {
+	query := `SELECT id, chat_jid, COALESCE(message_id, ''), COALESCE(question, ''), response,
+		COALESCE(sources, ''), created_at FROM reply_log`
+	args := []interface{}{}
+	if chatJID != "" {
+		query += " WHERE chat_jid = ?"
+		args = append(args, chatJID)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := m.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load reply log: %v", err)
+	}
+	defer rows.Close()
+
+	entries := []ReplyLogEntry{}
+	for rows.Next() {
+		var entry ReplyLogEntry
+		var sources string
+		err := rows.Scan(&entry.ID, &entry.ChatJID, &entry.MessageID, &entry.Question, &entry.Response, &sources, &entry.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan reply log entry: %v", err)
+		}
+		if sources != "" {
+			if err := json.Unmarshal([]byte(sources), &entry.Sources); err != nil {
+				return nil, fmt.Errorf("failed to parse reply sources: %v", err)
+			}
+		}
+		entries = append(entries, entry)
 	}
 
-	for _, query := range queries {
-		if _, err := m.db.Exec(query); err != nil {
-			return fmt.Errorf("failed to execute query: %v", err)
+	return entries, rows.Err()
+}
+
+// AddToolAuditEntry appends a tool call to the audit log, setting entry.ID
+func (m *MessageDB) AddToolAuditEntry(entry *ToolAuditEntry) error {
+	result, err := m.db.Exec(`INSERT INTO tool_audit
+		(chat_jid, message_id, tool, arguments, result, error, duration_ms, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.ChatJID, entry.MessageID, entry.Tool, entry.Arguments, entry.Result, entry.Error,
+		entry.DurationMs, entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save tool audit entry: %v", err)
+	}
+	entry.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetToolAuditLog returns the latest tool calls, of one chat when chatJID is set
+func (m *MessageDB) GetToolAuditLog(chatJID string, limit int) ([]ToolAuditEntry, error) {
+	query := `SELECT id, chat_jid, COALESCE(message_id, ''), tool, COALESCE(arguments, ''),
+		COALESCE(result, ''), COALESCE(error, ''), duration_ms, created_at FROM tool_audit`
+	args := []interface{}{}
+	if chatJID != "" {
+		query += " WHERE chat_jid = ?"
+		args = append(args, chatJID)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := m.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tool audit log: %v", err)
+	}
+	defer rows.Close()
+
+	entries := []ToolAuditEntry{}
+	for rows.Next() {
+		var entry ToolAuditEntry
+		err := rows.Scan(&entry.ID, &entry.ChatJID, &entry.MessageID, &entry.Tool, &entry.Arguments,
+			&entry.Result, &entry.Error, &entry.DurationMs, &entry.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tool audit entry: %v", err)
 		}
+		entries = append(entries, entry)
 	}
 
-	return nil
+	return entries, rows.Err()
 }
 
-// StoredMessage represents a message stored in database
-// SaveConfig saves the auto-reply configuration to the database
-func (m *MessageDB) SaveConfig(config *AutoReplyConfig) error {
-	// First, clear existing whitelist numbers
-	_, err := m.db.Exec("DELETE FROM whitelist_numbers")
+// AddUsageEntry appends an AI call to the usage log, setting entry.ID
+func (m *MessageDB) AddUsageEntry(entry *UsageEntry) error {
+	result, err := m.db.Exec(`INSERT INTO ai_usage
+		(provider, model, purpose, chat_jid, prompt_tokens, completion_tokens, latency_ms, outcome, error, cost, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.Provider, entry.Model, entry.Purpose, entry.ChatJID, entry.PromptTokens, entry.CompletionTokens,
+		entry.LatencyMs, entry.Outcome, entry.Error, entry.Cost, entry.CreatedAt)
 	if err != nil {
-		return fmt.Errorf("failed to clear whitelist numbers: %v", err)
+		return fmt.Errorf("failed to save usage entry: %v", err)
+	}
+	entry.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetUsageLog returns the latest AI calls, of one chat when chatJID is set
+func (m *MessageDB) GetUsageLog(chatJID string, limit int) ([]UsageEntry, error) {
+	query := `SELECT id, provider, COALESCE(model, ''), purpose, COALESCE(chat_jid, ''), prompt_tokens,
+		completion_tokens, latency_ms, outcome, COALESCE(error, ''), cost, created_at FROM ai_usage`
+	args := []interface{}{}
+	if chatJID != "" {
+		query += " WHERE chat_jid = ?"
+		args = append(args, chatJID)
 	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
 
-	// Insert new whitelist numbers
-	for _, number := range config.WhitelistNumbers {
-		_, err := m.db.Exec("INSERT INTO whitelist_numbers (phone_number) VALUES (?)", number)
+	rows, err := m.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load usage log: %v", err)
+	}
+	defer rows.Close()
+
+	entries := []UsageEntry{}
+	for rows.Next() {
+		var entry UsageEntry
+		err := rows.Scan(&entry.ID, &entry.Provider, &entry.Model, &entry.Purpose, &entry.ChatJID,
+			&entry.PromptTokens, &entry.CompletionTokens, &entry.LatencyMs, &entry.Outcome, &entry.Error,
+			&entry.Cost, &entry.CreatedAt)
 		if err != nil {
-			return fmt.Errorf("failed to insert whitelist number: %v", err)
+			return nil, fmt.Errorf("failed to scan usage entry: %v", err)
 		}
+		entries = append(entries, entry)
 	}
 
-	// Save main configuration
-	_, err = m.db.Exec(`
-		INSERT OR REPLACE INTO config (
-			id, enabled, ai_provider, openai_api_key, openai_model,
-			ollama_url, ollama_model, system_prompt,
-			response_delay, max_response_length, updated_at
-		) VALUES (
-			'default', ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP
-		)`,
-		config.Enabled,
-		config.AIProvider,
-		config.OpenAIAPIKey,
-		config.OpenAIModel,
-		config.OllamaURL,
-		config.OllamaModel,
-		config.SystemPrompt,
-		config.ResponseDelay,
-		config.MaxResponseLength,
-	)
+	return entries, rows.Err()
+}
 
+// SaveReplyDecision stores a new auto-reply decision, setting decision.ID, or updates it
+func (m *MessageDB) SaveReplyDecision(decision *ReplyDecision) error {
+	data, err := json.Marshal(decision)
 	if err != nil {
-		return fmt.Errorf("failed to save config: %v", err)
+		return fmt.Errorf("failed to encode reply decision: %v", err)
+	}
+
+	if decision.ID != 0 {
+		_, err := m.db.Exec(`UPDATE reply_decisions SET outcome = ?, data = ?, updated_at = ? WHERE id = ?`,
+			decision.Outcome, string(data), decision.UpdatedAt, decision.ID)
+		if err != nil {
+			return fmt.Errorf("failed to update reply decision: %v", err)
+		}
+		return nil
+	}
+
+	result, err := m.db.Exec(`INSERT INTO reply_decisions (chat_jid, message_id, outcome, data, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		decision.ChatJID, decision.MessageID, decision.Outcome, string(data), decision.CreatedAt, decision.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save reply decision: %v", err)
+	}
+	decision.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetReplyDecisions returns the latest auto-reply decisions, of one chat when chatJID is set
+func (m *MessageDB) GetReplyDecisions(chatJID string, limit int) ([]ReplyDecision, error) {
+	query := `SELECT id, data FROM reply_decisions`
+	args := []interface{}{}
+	if chatJID != "" {
+		query += " WHERE chat_jid = ?"
+		args = append(args, chatJID)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := m.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load reply decisions: %v", err)
+	}
+	defer rows.Close()
+
+	decisions := []ReplyDecision{}
+	for rows.Next() {
+		var id int64
+		var data string
+		if err := rows.Scan(&id, &data); err != nil {
+			return nil, fmt.Errorf("failed to scan reply decision: %v", err)
+		}
+
+		var decision ReplyDecision
+		if err := json.Unmarshal([]byte(data), &decision); err != nil {
+			continue
+		}
+		decision.ID = id
+		decisions = append(decisions, decision)
+	}
+
+	return decisions, rows.Err()
+}
+
+// GetChatMemory returns the memory of a chat, empty if nothing is remembered yet
+func (m *MessageDB) GetChatMemory(chatJID string) (*ChatMemory, error) {
+	memory := &ChatMemory{ChatJID: chatJID, Facts: []string{}}
+	var summary sql.NullString
+	var facts string
+
+	err := m.db.QueryRow(`SELECT summary, facts, last_message_at, edited_at, updated_at FROM chat_memory WHERE chat_jid = ?`,
+		chatJID).Scan(&summary, &facts, &memory.LastMessageAt, &memory.EditedAt, &memory.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return memory, nil
+		}
+		return nil, fmt.Errorf("failed to load chat memory: %v", err)
+	}
+
+	memory.Summary = summary.String
+	if err := json.Unmarshal([]byte(facts), &memory.Facts); err != nil {
+		memory.Facts = []string{}
+	}
+	return memory, nil
+}
+
+// SaveChatMemory stores the memory of a chat
+func (m *MessageDB) SaveChatMemory(memory *ChatMemory) error {
+	facts, err := json.Marshal(memory.Facts)
+	if err != nil {
+		return fmt.Errorf("failed to encode memory facts: %v", err)
 	}
 
+	_, err = m.db.Exec(`INSERT OR REPLACE INTO chat_memory (chat_jid, summary, facts, last_message_at, edited_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		memory.ChatJID, memory.Summary, string(facts), memory.LastMessageAt, memory.EditedAt, memory.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save chat memory: %v", err)
+	}
 	return nil
 }
 
-// LoadConfig loads the auto-reply configuration from the database
-func (m *MessageDB) LoadConfig() (*AutoReplyConfig, error) {
-	var config AutoReplyConfig
+// DeleteChatMemory removes the memory of a chat
+func (m *MessageDB) DeleteChatMemory(chatJID string) error {
+	if _, err := m.db.Exec(`DELETE FROM chat_memory WHERE chat_jid = ?`, chatJID); err != nil {
+		return fmt.Errorf("failed to delete chat memory: %v", err)
+	}
+	return nil
+}
 
-	// Load main configuration
-	err := m.db.QueryRow(`
-		SELECT 
-			enabled, ai_provider, openai_api_key, openai_model,
-			ollama_url, ollama_model, system_prompt,
-			response_delay, max_response_length
-		FROM config 
-		WHERE id = 'default'
-	`).Scan(
-		&config.Enabled,
-		&config.AIProvider,
-		&config.OpenAIAPIKey,
-		&config.OpenAIModel,
-		&config.OllamaURL,
-		&config.OllamaModel,
-		&config.SystemPrompt,
-		&config.ResponseDelay,
-		&config.MaxResponseLength,
-	)
+// GetMemoryCandidates returns the direct chats with at least minNew messages since their
+// memory was last updated, counting only messages newer than since, most active first
+func (m *MessageDB) GetMemoryCandidates(since int64, minNew int) ([]string, error) {
+	rows, err := m.db.Query(`SELECT msg.chat_jid FROM messages msg
+		LEFT JOIN chat_memory mem ON mem.chat_jid = msg.chat_jid
+		WHERE msg.is_group = 0 AND msg.chat_jid != 'status@broadcast'
+			AND msg.timestamp > COALESCE(mem.last_message_at, 0) AND msg.timestamp >= ?
+		GROUP BY msg.chat_jid
+		HAVING COUNT(*) >= ?
+		ORDER BY MAX(msg.timestamp) DESC`, since, minNew)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find chats with new messages: %v", err)
+	}
+	defer rows.Close()
 
-	if err == sql.ErrNoRows {
-		// Return default config if no configuration exists
-		return GetDefaultAutoReplyConfig(), nil
-	} else if err != nil {
-		return nil, fmt.Errorf("failed to load config: %v", err)
+	chats := []string{}
+	for rows.Next() {
+		var chatJID string
+		if err := rows.Scan(&chatJID); err != nil {
+			return nil, fmt.Errorf("failed to scan chat: %v", err)
+		}
+		chats = append(chats, chatJID)
 	}
+	return chats, rows.Err()
+}
 
-	// Load whitelist numbers
-	rows, err := m.db.Query("SELECT phone_number FROM whitelist_numbers")
+// GetUsageCost returns the cost of the AI calls made since the given time (unix seconds)
+func (m *MessageDB) GetUsageCost(since int64) (float64, error) {
+	var cost float64
+	err := m.db.QueryRow(`SELECT COALESCE(SUM(cost), 0) FROM ai_usage WHERE created_at >= ?`, since).Scan(&cost)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load whitelist numbers: %v", err)
+		return 0, fmt.Errorf("failed to sum usage cost: %v", err)
+	}
+	return cost, nil
+}
+
+// usageGroupKeys maps report groupings to the SQL expression of their key
+var usageGroupKeys = map[string]string{
+	"day":      "date(created_at, 'unixepoch', 'localtime')",
+	"model":    "provider || '/' || COALESCE(model, '')",
+	"provider": "provider",
+	"chat":     "COALESCE(NULLIF(chat_jid, ''), purpose)",
+}
+
+// GetUsageReport sums the AI calls made between from and to (unix seconds, inclusive)
+func (m *MessageDB) GetUsageReport(from, to int64, groupBy string) (*UsageReport, error) {
+	if groupBy == "" {
+		groupBy = "day"
+	}
+	key, ok := usageGroupKeys[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("unsupported grouping: %s", groupBy)
+	}
+
+	rows, err := m.db.Query(`SELECT `+key+` AS group_key, COUNT(*),
+		SUM(CASE WHEN outcome = 'error' THEN 1 ELSE 0 END), SUM(prompt_tokens), SUM(completion_tokens),
+		SUM(cost), SUM(latency_ms)
+		FROM ai_usage WHERE created_at >= ? AND created_at <= ?
+		GROUP BY group_key ORDER BY group_key`, from, to)
+	if err != nil {
+		return nil, err
 	}
 	defer rows.Close()
 
-	var numbers []string
+	report := &UsageReport{From: from, To: to, GroupBy: groupBy, Total: UsageGroup{Key: "total"}, Groups: []UsageGroup{}}
+	var totalLatency int64
 	for rows.Next() {
-		var number string
-		if err := rows.Scan(&number); err != nil {
-			return nil, fmt.Errorf("failed to scan whitelist number: %v", err)
+		var group UsageGroup
+		var latency int64
+		if err := rows.Scan(&group.Key, &group.Calls, &group.Errors, &group.PromptTokens,
+			&group.CompletionTokens, &group.Cost, &latency); err != nil {
+			return nil, err
 		}
-		numbers = append(numbers, number)
+		group.AvgLatencyMs = latency / int64(group.Calls)
+		report.Groups = append(report.Groups, group)
+
+		report.Total.Calls += group.Calls
+		report.Total.Errors += group.Errors
+		report.Total.PromptTokens += group.PromptTokens
+		report.Total.CompletionTokens += group.CompletionTokens
+		report.Total.Cost += group.Cost
+		totalLatency += latency
+	}
+	if report.Total.Calls > 0 {
+		report.Total.AvgLatencyMs = totalLatency / int64(report.Total.Calls)
 	}
 
-	config.WhitelistNumbers = numbers
-	return &config, nil
+	return report, rows.Err()
 }
 
 type StoredMessage struct {
@@ -206,6 +1363,8 @@ type StoredMessage struct {
 	IsFromMe        bool      `json:"isFromMe"`
 	IsGroup         bool      `json:"isGroup"`
 	QuotedMessageID string    `json:"quotedMessageId,omitempty"`
+	Transcript      string    `json:"transcript,omitempty"`
+	Intent          string    `json:"intent,omitempty"`
 	CreatedAt       time.Time `json:"createdAt"`
 }
 
@@ -241,9 +1400,12 @@ func (m *MessageDB) StoreDirectMessage(msg *StoredMessage) error {
 
 // UpsertChat creates or updates a chat in the database
 func (m *MessageDB) UpsertChat(chat *StoredChat) error {
-	query := `INSERT OR REPLACE INTO chats 
+	query := `INSERT INTO chats 
 		(jid, name, is_group, last_message_id, last_message_time, unread_count, updated_at) 
-		VALUES (?, ?, ?, ?, ?, 0, ?)`
+		VALUES (?, ?, ?, ?, ?, 0, ?)
+		ON CONFLICT(jid) DO UPDATE SET 
+			name = excluded.name, is_group = excluded.is_group, last_message_id = excluded.last_message_id,
+			last_message_time = excluded.last_message_time, unread_count = 0, updated_at = excluded.updated_at`
 
 	_, err := m.db.Exec(query,
 		chat.JID,
@@ -269,6 +1431,9 @@ type StoredChat struct {
 	LastMessageID   string    `json:"lastMessageId,omitempty"`
 	LastMessageTime int64     `json:"lastMessageTime"`
 	UnreadCount     int       `json:"unreadCount"`
+	Archived        bool      `json:"archived"`
+	Pinned          bool      `json:"pinned"`
+	MutedUntil      int64     `json:"mutedUntil,omitempty"`
 	CreatedAt       time.Time `json:"createdAt"`
 	UpdatedAt       time.Time `json:"updatedAt"`
 }
@@ -338,7 +1503,7 @@ func (m *MessageDB) StoreMessage(msg *waProto.WebMessageInfo) error {
 	chatJID := msg.Key.GetRemoteJid()
 	timestamp := int64(msg.GetMessageTimestamp())
 	isFromMe := msg.Key.GetFromMe()
-	isGroup := msg.Key.GetRemoteJid() != "" && msg.Key.GetRemoteJid()[len(msg.Key.GetRemoteJid())-5:] == "@g.us"
+	isGroup := strings.HasSuffix(chatJID, "@g.us")
 
 	// Determine sender JID
 	var actualSenderJID string
@@ -430,29 +1595,50 @@ func (m *MessageDB) extractMessageContent(msg *waProto.Message) (content, messag
 func (m *MessageDB) updateChatLastMessage(chatJID, messageID string, timestamp int64, incrementUnread bool) error {
 	// First, ensure chat exists
 	_, err := m.db.Exec(`INSERT OR IGNORE INTO chats (jid, name, is_group, last_message_time, unread_count) 
-		VALUES (?, ?, ?, 0, 0)`, chatJID, chatJID, chatJID[len(chatJID)-5:] == "@g.us")
+		VALUES (?, ?, ?, 0, 0)`, chatJID, chatJID, strings.HasSuffix(chatJID, "@g.us"))
+	if err != nil {
+		return err
+	}
+
+	// Only move the last message forward, older messages (e.g. from history sync) keep the newest one
+	_, err = m.db.Exec(`UPDATE chats 
+		SET last_message_id = ?, last_message_time = ?, updated_at = CURRENT_TIMESTAMP 
+		WHERE jid = ? AND (last_message_time IS NULL OR last_message_time <= ?)`, messageID, timestamp, chatJID, timestamp)
 	if err != nil {
 		return err
 	}
 
-	// Update last message and optionally increment unread count
+	// Optionally increment unread count
 	if incrementUnread {
-		_, err = m.db.Exec(`UPDATE chats 
-			SET last_message_id = ?, last_message_time = ?, unread_count = unread_count + 1, updated_at = CURRENT_TIMESTAMP 
-			WHERE jid = ?`, messageID, timestamp, chatJID)
-	} else {
-		_, err = m.db.Exec(`UPDATE chats 
-			SET last_message_id = ?, last_message_time = ?, updated_at = CURRENT_TIMESTAMP 
-			WHERE jid = ?`, messageID, timestamp, chatJID)
+		_, err = m.db.Exec(`UPDATE chats SET unread_count = unread_count + 1, updated_at = CURRENT_TIMESTAMP 
+			WHERE jid = ?`, chatJID)
 	}
 
 	return err
 }
 
+// ApplyChatState sets the name, unread counter and archived/pinned/muted flags of a chat
+func (m *MessageDB) ApplyChatState(chat *StoredChat) error {
+	_, err := m.db.Exec(`INSERT INTO chats (jid, name, is_group, last_message_time, unread_count, archived, pinned, muted_until) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(jid) DO UPDATE SET 
+			name = excluded.name, unread_count = excluded.unread_count, archived = excluded.archived,
+			pinned = excluded.pinned, muted_until = excluded.muted_until,
+			last_message_time = MAX(IFNULL(chats.last_message_time, 0), excluded.last_message_time),
+			updated_at = CURRENT_TIMESTAMP`,
+		chat.JID, chat.Name, chat.IsGroup, chat.LastMessageTime, chat.UnreadCount,
+		chat.Archived, chat.Pinned, chat.MutedUntil)
+	if err != nil {
+		return fmt.Errorf("failed to apply chat state: %v", err)
+	}
+
+	return nil
+}
+
 // GetChatMessages retrieves messages for a specific chat
 func (m *MessageDB) GetChatMessages(chatJID string, limit int, offset int) ([]StoredMessage, error) {
 	query := `SELECT id, chat_jid, sender_jid, message_type, content, media_path, media_type, 
-		caption, timestamp, is_from_me, is_group, quoted_message_id, created_at 
+		caption, timestamp, is_from_me, is_group, quoted_message_id, transcript, intent, created_at 
 		FROM messages WHERE chat_jid = ? ORDER BY timestamp DESC LIMIT ? OFFSET ?`
 
 	rows, err := m.db.Query(query, chatJID, limit, offset)
@@ -461,14 +1647,35 @@ func (m *MessageDB) GetChatMessages(chatJID string, limit int, offset int) ([]St
 	}
 	defer rows.Close()
 
+	return scanMessages(rows)
+}
+
+// GetChatMessagesBefore returns the latest messages of a chat sent at or before the given
+// time (unix seconds), newest first
+func (m *MessageDB) GetChatMessagesBefore(chatJID string, before int64, limit int) ([]StoredMessage, error) {
+	query := `SELECT id, chat_jid, sender_jid, message_type, content, media_path, media_type, 
+		caption, timestamp, is_from_me, is_group, quoted_message_id, transcript, intent, created_at 
+		FROM messages WHERE chat_jid = ? AND timestamp <= ? ORDER BY timestamp DESC LIMIT ?`
+
+	rows, err := m.db.Query(query, chatJID, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanMessages(rows)
+}
+
+// scanMessages reads the rows of a message query
+func scanMessages(rows *sql.Rows) ([]StoredMessage, error) {
 	var messages []StoredMessage
 	for rows.Next() {
 		var msg StoredMessage
-		var mediaPath, mediaType, caption, quotedID sql.NullString
+		var mediaPath, mediaType, caption, quotedID, transcript, intent sql.NullString
 
 		err := rows.Scan(&msg.ID, &msg.ChatJID, &msg.SenderJID, &msg.MessageType, &msg.Content,
 			&mediaPath, &mediaType, &caption, &msg.Timestamp, &msg.IsFromMe, &msg.IsGroup,
-			&quotedID, &msg.CreatedAt)
+			&quotedID, &transcript, &intent, &msg.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -477,6 +1684,8 @@ func (m *MessageDB) GetChatMessages(chatJID string, limit int, offset int) ([]St
 		msg.MediaType = mediaType.String
 		msg.Caption = caption.String
 		msg.QuotedMessageID = quotedID.String
+		msg.Transcript = transcript.String
+		msg.Intent = intent.String
 
 		messages = append(messages, msg)
 	}
@@ -484,10 +1693,72 @@ func (m *MessageDB) GetChatMessages(chatJID string, limit int, offset int) ([]St
 	return messages, nil
 }
 
+// SearchChatMessages returns the latest messages of a chat containing the query
+func (m *MessageDB) SearchChatMessages(chatJID, query string, limit int) ([]StoredMessage, error) {
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(query) + "%"
+	rows, err := m.db.Query(`SELECT id, sender_jid, COALESCE(NULLIF(transcript, ''), content, ''), timestamp, is_from_me
+		FROM messages WHERE chat_jid = ? AND (content LIKE ? ESCAPE '\' OR transcript LIKE ? ESCAPE '\')
+		ORDER BY timestamp DESC LIMIT ?`, chatJID, pattern, pattern, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search messages: %v", err)
+	}
+	defer rows.Close()
+
+	var messages []StoredMessage
+	for rows.Next() {
+		msg := StoredMessage{ChatJID: chatJID}
+		if err := rows.Scan(&msg.ID, &msg.SenderJID, &msg.Content, &msg.Timestamp, &msg.IsFromMe); err != nil {
+			return nil, fmt.Errorf("failed to scan message: %v", err)
+		}
+		messages = append(messages, msg)
+	}
+
+	return messages, rows.Err()
+}
+
+// SetMessageTranscript stores the transcript of a voice note
+func (m *MessageDB) SetMessageTranscript(messageID, transcript string) error {
+	if _, err := m.db.Exec(`UPDATE messages SET transcript = ? WHERE id = ?`, transcript, messageID); err != nil {
+		return fmt.Errorf("failed to store transcript: %v", err)
+	}
+	return nil
+}
+
+// SetMessageIntent stores the intent an incoming message was classified with
+func (m *MessageDB) SetMessageIntent(messageID, intent string) error {
+	if _, err := m.db.Exec(`UPDATE messages SET intent = ? WHERE id = ?`, intent, messageID); err != nil {
+		return fmt.Errorf("failed to store intent: %v", err)
+	}
+	return nil
+}
+
+// GetIntentCounts returns the number of classified messages per intent since the given time (unix seconds)
+func (m *MessageDB) GetIntentCounts(since int64) (map[string]int, error) {
+	rows, err := m.db.Query(`SELECT intent, COUNT(*) FROM messages
+		WHERE intent IS NOT NULL AND intent != '' AND timestamp >= ?
+		GROUP BY intent`, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count intents: %v", err)
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var intent string
+		var count int
+		if err := rows.Scan(&intent, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan intent count: %v", err)
+		}
+		counts[intent] = count
+	}
+	return counts, rows.Err()
+}
+
 // GetAllChats retrieves all chats from database
 func (m *MessageDB) GetAllChats() ([]StoredChat, error) {
-	query := `SELECT jid, name, is_group, last_message_id, last_message_time, unread_count, created_at, updated_at 
-		FROM chats ORDER BY last_message_time DESC`
+	query := `SELECT jid, name, is_group, last_message_id, last_message_time, unread_count, 
+		archived, pinned, muted_until, created_at, updated_at 
+		FROM chats ORDER BY pinned DESC, last_message_time DESC`
 
 	rows, err := m.db.Query(query)
 	if err != nil {
@@ -501,7 +1772,8 @@ func (m *MessageDB) GetAllChats() ([]StoredChat, error) {
 		var lastMessageID sql.NullString
 
 		err := rows.Scan(&chat.JID, &chat.Name, &chat.IsGroup, &lastMessageID,
-			&chat.LastMessageTime, &chat.UnreadCount, &chat.CreatedAt, &chat.UpdatedAt)
+			&chat.LastMessageTime, &chat.UnreadCount, &chat.Archived, &chat.Pinned, &chat.MutedUntil,
+			&chat.CreatedAt, &chat.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -513,20 +1785,93 @@ func (m *MessageDB) GetAllChats() ([]StoredChat, error) {
 	return chats, nil
 }
 
+// GetChat retrieves a single chat by JID, returning nil if it does not exist
+func (m *MessageDB) GetChat(chatJID string) (*StoredChat, error) {
+	query := `SELECT jid, name, is_group, last_message_id, last_message_time, unread_count, 
+		archived, pinned, muted_until, created_at, updated_at 
+		FROM chats WHERE jid = ?`
+
+	var chat StoredChat
+	var lastMessageID sql.NullString
+
+	err := m.db.QueryRow(query, chatJID).Scan(&chat.JID, &chat.Name, &chat.IsGroup, &lastMessageID,
+		&chat.LastMessageTime, &chat.UnreadCount, &chat.Archived, &chat.Pinned, &chat.MutedUntil,
+		&chat.CreatedAt, &chat.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	chat.LastMessageID = lastMessageID.String
+	return &chat, nil
+}
+
+// UpdateChatName updates the display name of a chat
+func (m *MessageDB) UpdateChatName(chatJID, name string) error {
+	_, err := m.db.Exec(`UPDATE chats SET name = ?, updated_at = CURRENT_TIMESTAMP WHERE jid = ?`, name, chatJID)
+	return err
+}
+
+// UpdateChatNameIfUnset sets the chat name only if it is still the raw JID
+func (m *MessageDB) UpdateChatNameIfUnset(chatJID, name string) error {
+	_, err := m.db.Exec(`UPDATE chats SET name = ?, updated_at = CURRENT_TIMESTAMP WHERE jid = ? AND name = jid`, name, chatJID)
+	return err
+}
+
+// CountChatMessages returns the number of stored messages in a chat
+func (m *MessageDB) CountChatMessages(chatJID string) (int, error) {
+	var count int
+	err := m.db.QueryRow(`SELECT COUNT(*) FROM messages WHERE chat_jid = ?`, chatJID).Scan(&count)
+	return count, err
+}
+
 // GetLastMessage retrieves the last message for a chat
 func (m *MessageDB) GetLastMessage(chatJID string) (*StoredMessage, error) {
 	query := `SELECT id, chat_jid, sender_jid, message_type, content, media_path, media_type, 
-		caption, timestamp, is_from_me, is_group, quoted_message_id, created_at 
+		caption, timestamp, is_from_me, is_group, quoted_message_id, transcript, intent, created_at 
 		FROM messages WHERE chat_jid = ? ORDER BY timestamp DESC LIMIT 1`
 
 	row := m.db.QueryRow(query, chatJID)
 
 	var msg StoredMessage
-	var mediaPath, mediaType, caption, quotedID sql.NullString
+	var mediaPath, mediaType, caption, quotedID, transcript, intent sql.NullString
+
+	err := row.Scan(&msg.ID, &msg.ChatJID, &msg.SenderJID, &msg.MessageType, &msg.Content,
+		&mediaPath, &mediaType, &caption, &msg.Timestamp, &msg.IsFromMe, &msg.IsGroup,
+		&quotedID, &transcript, &intent, &msg.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	msg.MediaPath = mediaPath.String
+	msg.MediaType = mediaType.String
+	msg.Caption = caption.String
+	msg.QuotedMessageID = quotedID.String
+	msg.Transcript = transcript.String
+	msg.Intent = intent.String
+
+	return &msg, nil
+}
+
+// GetMessage returns a stored message by ID, or nil if it does not exist
+func (m *MessageDB) GetMessage(id string) (*StoredMessage, error) {
+	query := `SELECT id, chat_jid, sender_jid, message_type, content, media_path, media_type, 
+		caption, timestamp, is_from_me, is_group, quoted_message_id, transcript, intent, created_at 
+		FROM messages WHERE id = ?`
+
+	row := m.db.QueryRow(query, id)
+
+	var msg StoredMessage
+	var mediaPath, mediaType, caption, quotedID, transcript, intent sql.NullString
 
 	err := row.Scan(&msg.ID, &msg.ChatJID, &msg.SenderJID, &msg.MessageType, &msg.Content,
 		&mediaPath, &mediaType, &caption, &msg.Timestamp, &msg.IsFromMe, &msg.IsGroup,
-		&quotedID, &msg.CreatedAt)
+		&quotedID, &transcript, &intent, &msg.CreatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -538,6 +1883,8 @@ func (m *MessageDB) GetLastMessage(chatJID string) (*StoredMessage, error) {
 	msg.MediaType = mediaType.String
 	msg.Caption = caption.String
 	msg.QuotedMessageID = quotedID.String
+	msg.Transcript = transcript.String
+	msg.Intent = intent.String
 
 	return &msg, nil
 }
@@ -548,6 +1895,28 @@ func (m *MessageDB) MarkChatAsRead(chatJID string) error {
 	return err
 }
 
+// PurgeMessages deletes all stored messages, chats and contacts along with the AI replies,
+// drafts, decisions, tool calls and memories quoting them. The configuration and the usage
+// log are kept.
+func (m *MessageDB) PurgeMessages() error {
+	tables := []string{
+		"messages", "chats", "contacts",
+		"reply_log", "reply_decisions", "reply_drafts", "tool_audit", "chat_memory", "away_replies",
+	}
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, table := range tables {
+		if _, err := tx.Exec("DELETE FROM " + table); err != nil {
+			return fmt.Errorf("failed to purge %s: %v", table, err)
+		}
+	}
+	return tx.Commit()
+}
+
 // Close closes the database connection
 func (m *MessageDB) Close() error {
 	return m.db.Close()
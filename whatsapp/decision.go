@@ -0,0 +1,173 @@
+package whatsapp
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// Outcomes of a reply decision
+const (
+	DecisionSkipped = "skipped" // no reply, see Reason
+	DecisionPending = "pending" // AI reply being generated
+	DecisionRule    = "rule"    // answered by a reply rule only
+	DecisionAway    = "away"    // answered with the away message
+	DecisionReplied = "replied" // AI reply sent
+	DecisionDrafted = "drafted" // AI reply queued for approval
+	DecisionFailed  = "failed"  // AI reply could not be generated or sent, see Reason
+)
+
+// ReplyDecision records how auto-reply handled an incoming message: why it was skipped,
+// or the profile, prompt and model output behind the reply
+type ReplyDecision struct {
+	ID             int64         `json:"id"`
+	ChatJID        string        `json:"chat_jid"`
+	MessageID      string        `json:"message_id"`
+	Sender         string        `json:"sender"`
+	Text           string        `json:"text"` // message text as seen by auto-reply
+	Outcome        string        `json:"outcome"`
+	Reason         string        `json:"reason,omitempty"`
+	Intent         string        `json:"intent,omitempty"`
+	Profile        string        `json:"profile,omitempty"`
+	Rule           string        `json:"rule,omitempty"`
+	Provider       string        `json:"provider,omitempty"`
+	Model          string        `json:"model,omitempty"`
+	Prompt         []ChatMessage `json:"prompt,omitempty"` // conversation sent to the model
+	RawOutput      string        `json:"raw_output,omitempty"`
+	Response       string        `json:"response,omitempty"` // text sent, or that would be sent
+	SentMessageIDs []string      `json:"sent_message_ids,omitempty"`
+	DryRun         bool          `json:"dry_run"`
+	CreatedAt      int64         `json:"created_at"`
+	UpdatedAt      int64         `json:"updated_at"`
+}
+
+// newDecision starts the decision record of an incoming message
+func newDecision(evt *events.Message, text string, dryRun bool) *ReplyDecision {
+	now := time.Now().Unix()
+	return &ReplyDecision{
+		ChatJID:   evt.Info.Chat.String(),
+		MessageID: evt.Info.ID,
+		Sender:    evt.Info.Sender.User,
+		Text:      text,
+		Outcome:   DecisionPending,
+		DryRun:    dryRun,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// recordDecision sets the outcome of the decision and stores it. Dry runs are not stored.
+func (m *Manager) recordDecision(d *ReplyDecision, outcome, reason string) *ReplyDecision {
+	d.Outcome = outcome
+	d.Reason = reason
+	d.UpdatedAt = time.Now().Unix()
+	if d.DryRun || m.messageDB == nil {
+		return d
+	}
+
+	if err := m.messageDB.SaveReplyDecision(d); err != nil {
+		m.log.Errorf("Failed to store reply decision: %v", err)
+	}
+	return d
+}
+
+// GetReplyDecisions returns the latest auto-reply decisions, of one chat when chatJID is set
+func (m *Manager) GetReplyDecisions(chatJID string, limit int) ([]ReplyDecision, error) {
+	if m.messageDB == nil {
+		return nil, fmt.Errorf("message database not initialized")
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+
+	decisions, err := m.messageDB.GetReplyDecisions(chatJID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load reply decisions: %v", err)
+	}
+	return decisions, nil
+}
+
+// ReplayMessage runs a stored incoming message through the current auto-reply config
+// without sending anything. Rate limits and loop detection are not applied, and tools
+// run in read-only mode. The AI is called, so the replay shows up in the usage log.
+func (m *Manager) ReplayMessage(messageID string) (*ReplyDecision, error) {
+	if m.messageDB == nil {
+		return nil, fmt.Errorf("message database not initialized")
+	}
+	if m.autoReply == nil {
+		return nil, fmt.Errorf("auto-reply not configured")
+	}
+
+	stored, err := m.messageDB.GetMessage(messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load message: %v", err)
+	}
+	if stored == nil {
+		return nil, fmt.Errorf("message not found")
+	}
+	if stored.IsFromMe {
+		return nil, fmt.Errorf("cannot replay our own message")
+	}
+
+	evt, text, err := replayEvent(stored)
+	if err != nil {
+		return nil, err
+	}
+	return m.autoReply.processMessage(evt, m, text, true), nil
+}
+
+// mentionRe matches "@<number>" mentions in message text
+var mentionRe = regexp.MustCompile(`@(\d{5,})`)
+
+// replayEvent rebuilds a message event from a stored message. Mentions are recovered from
+// the text so group triggers still apply; media is not available to the replay.
+func replayEvent(stored *StoredMessage) (*events.Message, string, error) {
+	chat, err := types.ParseJID(stored.ChatJID)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid chat JID: %v", err)
+	}
+	sender, err := types.ParseJID(stored.SenderJID)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid sender JID: %v", err)
+	}
+
+	text := stored.Content
+	switch {
+	case stored.Transcript != "":
+		text = stored.Transcript
+	case stored.Caption != "":
+		text = stored.Caption
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, "", fmt.Errorf("message has no text to replay")
+	}
+
+	var mentions []string
+	for _, match := range mentionRe.FindAllStringSubmatch(text, -1) {
+		mentions = append(mentions, types.NewJID(match[1], types.DefaultUserServer).String())
+	}
+
+	evt := &events.Message{
+		Info: types.MessageInfo{
+			MessageSource: types.MessageSource{
+				Chat:    chat,
+				Sender:  sender,
+				IsGroup: stored.IsGroup,
+			},
+			ID:        stored.ID,
+			Timestamp: time.Unix(stored.Timestamp, 0),
+		},
+		Message: &waProto.Message{
+			ExtendedTextMessage: &waProto.ExtendedTextMessage{
+				Text:        &text,
+				ContextInfo: &waProto.ContextInfo{MentionedJID: mentions},
+			},
+		},
+	}
+	return evt, text, nil
+}
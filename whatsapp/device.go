@@ -0,0 +1,180 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// DeviceInfo describes a device stored in the WhatsApp session store
+type DeviceInfo struct {
+	JID          string `json:"jid"`
+	PushName     string `json:"pushName"`
+	BusinessName string `json:"businessName,omitempty"`
+	Platform     string `json:"platform,omitempty"`
+	IsActive     bool   `json:"isActive"`
+}
+
+// ListDevices returns all devices stored in the session store
+func (m *Manager) ListDevices() ([]DeviceInfo, error) {
+	devices, err := m.container.GetAllDevices(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get devices: %v", err)
+	}
+
+	activeJID := ""
+	if m.client != nil && m.client.Store.ID != nil {
+		activeJID = m.client.Store.ID.String()
+	}
+
+	var result []DeviceInfo
+	for _, device := range devices {
+		if device.ID == nil {
+			continue
+		}
+		result = append(result, DeviceInfo{
+			JID:          device.ID.String(),
+			PushName:     device.PushName,
+			BusinessName: device.BusinessName,
+			Platform:     device.Platform,
+			IsActive:     device.ID.String() == activeJID,
+		})
+	}
+
+	return result, nil
+}
+
+// SwitchDevice disconnects the current device and connects the stored device with the given JID
+func (m *Manager) SwitchDevice(deviceJID string) error {
+	device, err := m.getStoredDevice(deviceJID)
+	if err != nil {
+		return err
+	}
+
+	if m.client != nil {
+		m.client.Disconnect()
+	}
+
+	m.client = whatsmeow.NewClient(device, m.log)
+	m.addEventHandlers()
+
+	if err := m.client.Connect(); err != nil {
+		return fmt.Errorf("failed to connect: %v", err)
+	}
+
+	if !m.client.WaitForConnection(30 * time.Second) {
+		return fmt.Errorf("connection timeout")
+	}
+
+	return nil
+}
+
+// Logout unlinks the active device from the phone and deletes its local credentials
+func (m *Manager) Logout(purgeMessages bool) error {
+	if m.client == nil || m.client.Store.ID == nil {
+		return fmt.Errorf("no device logged in")
+	}
+
+	return m.LogoutDevice(m.client.Store.ID.String(), purgeMessages)
+}
+
+// LogoutDevice unlinks a stored device from the phone and deletes its local credentials.
+// If the device cannot reach WhatsApp, only the local credentials are removed. Messages
+// can only be purged with the active device, as the message database is not kept per device.
+func (m *Manager) LogoutDevice(deviceJID string, purgeMessages bool) error {
+	device, err := m.getStoredDevice(deviceJID)
+	if err != nil {
+		return err
+	}
+
+	isActive := m.client != nil && m.client.Store.ID != nil && m.client.Store.ID.String() == deviceJID
+	if purgeMessages && !isActive {
+		return fmt.Errorf("messages can only be purged when logging out the active device")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	client := m.client
+	if !isActive {
+		// Connect a temporary client so the phone is told about the unlink
+		client = whatsmeow.NewClient(device, m.log)
+		if err := client.Connect(); err != nil {
+			m.log.Warnf("Failed to connect device %s for logout: %v", deviceJID, err)
+		}
+	}
+
+	if client.IsConnected() && client.WaitForConnection(15*time.Second) {
+		if err := client.Logout(ctx); err != nil {
+			m.log.Warnf("Failed to unlink device %s, removing local credentials only: %v", deviceJID, err)
+		}
+	}
+
+	// Logout already deletes the store on success; make sure nothing is left behind otherwise
+	client.Disconnect()
+	if err := m.deleteStoredDevice(ctx, deviceJID); err != nil {
+		return err
+	}
+
+	if purgeMessages && m.messageDB != nil {
+		// Pending drafts are purged too, so their auto-sends must not fire
+		if m.approvals != nil {
+			m.approvals.Clear()
+		}
+		if err := m.messageDB.PurgeMessages(); err != nil {
+			return fmt.Errorf("failed to purge message database: %v", err)
+		}
+	}
+
+	m.emitEvent(ConnectionEvent{
+		Type:    "logged_out",
+		Message: "Device logged out",
+		Data:    deviceJID,
+	})
+
+	return nil
+}
+
+// getStoredDevice loads a device from the session store by JID
+func (m *Manager) getStoredDevice(deviceJID string) (*store.Device, error) {
+	jid, err := types.ParseJID(deviceJID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid device JID: %v", err)
+	}
+
+	device, err := m.container.GetDevice(context.Background(), jid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device: %v", err)
+	}
+	if device == nil {
+		return nil, fmt.Errorf("device not found: %s", deviceJID)
+	}
+
+	return device, nil
+}
+
+// deleteStoredDevice removes a device from the session store if it still exists
+func (m *Manager) deleteStoredDevice(ctx context.Context, deviceJID string) error {
+	jid, err := types.ParseJID(deviceJID)
+	if err != nil {
+		return fmt.Errorf("invalid device JID: %v", err)
+	}
+
+	device, err := m.container.GetDevice(ctx, jid)
+	if err != nil {
+		return fmt.Errorf("failed to get device: %v", err)
+	}
+	if device == nil {
+		return nil
+	}
+
+	if err := m.container.DeleteDevice(ctx, device); err != nil {
+		return fmt.Errorf("failed to delete device: %v", err)
+	}
+
+	return nil
+}
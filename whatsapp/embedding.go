@@ -0,0 +1,175 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Embedder turns texts into vectors for the knowledge base
+type Embedder interface {
+	// Model returns the name of the embedding model, stored with each vector
+	Model() string
+	// Embed returns one vector per text, in order. The tokens reported by the
+	// provider are added to usage, which may be nil.
+	Embed(ctx context.Context, texts []string, usage *TokenUsage) ([][]float32, error)
+}
+
+// NewEmbedder creates the embedder configured for the knowledge base. It reuses the
+// endpoint and key of the matching chat provider.
+func NewEmbedder(config *AutoReplyConfig, client *http.Client) (Embedder, error) {
+	settings := config.Knowledge
+	if settings.EmbeddingModel == "" {
+		return nil, fmt.Errorf("embedding model not configured")
+	}
+
+	switch settings.EmbeddingProvider {
+	case "ollama":
+		if config.OllamaURL == "" {
+			return nil, fmt.Errorf("ollama URL not configured")
+		}
+		return &ollamaEmbedder{baseURL: config.OllamaURL, model: settings.EmbeddingModel, client: client}, nil
+	case "openai":
+		if config.OpenAIAPIKey == "" {
+			return nil, fmt.Errorf("OpenAI API key not configured")
+		}
+		return &openAIEmbedder{baseURL: openAIBaseURL, apiKey: config.OpenAIAPIKey, model: settings.EmbeddingModel, client: client}, nil
+	case "openai_compatible":
+		if config.OpenAICompatible.BaseURL == "" {
+			return nil, fmt.Errorf("OpenAI-compatible base URL not configured")
+		}
+		return &openAIEmbedder{
+			baseURL: config.OpenAICompatible.BaseURL,
+			apiKey:  config.OpenAICompatible.APIKey,
+			model:   settings.EmbeddingModel,
+			client:  client,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported embedding provider: %s", settings.EmbeddingProvider)
+	}
+}
+
+// ollamaEmbedder uses the embeddings API of a local Ollama server
+type ollamaEmbedder struct {
+	baseURL string
+	model   string
+	client  *http.Client
+}
+
+func (e *ollamaEmbedder) Model() string {
+	return e.model
+}
+
+// Embed requests the vectors one text at a time, as the Ollama API takes a single prompt
+func (e *ollamaEmbedder) Embed(ctx context.Context, texts []string, usage *TokenUsage) ([][]float32, error) {
+	url := strings.TrimSuffix(e.baseURL, "/") + "/api/embeddings"
+	vectors := make([][]float32, 0, len(texts))
+
+	for _, text := range texts {
+		reqCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+		status, _, body, err := postJSON(reqCtx, e.client, url, nil, map[string]string{
+			"model":  e.model,
+			"prompt": text,
+		})
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("%v (check if Ollama is running)", err)
+		}
+		if status != http.StatusOK {
+			return nil, fmt.Errorf("ollama embeddings error (status %d): %s", status, string(body))
+		}
+
+		var resp struct {
+			Embedding []float32 `json:"embedding"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return nil, fmt.Errorf("failed to parse embeddings: %v", err)
+		}
+		if len(resp.Embedding) == 0 {
+			return nil, fmt.Errorf("empty embedding from Ollama (is %s an embedding model?)", e.model)
+		}
+		vectors = append(vectors, resp.Embedding)
+	}
+
+	return vectors, nil
+}
+
+// openAIEmbedder uses the /embeddings endpoint of OpenAI or a compatible server
+type openAIEmbedder struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+}
+
+func (e *openAIEmbedder) Model() string {
+	return e.model
+}
+
+// embeddingBatchSize is the number of texts sent per /embeddings request
+const embeddingBatchSize = 64
+
+func (e *openAIEmbedder) Embed(ctx context.Context, texts []string, usage *TokenUsage) ([][]float32, error) {
+	url := strings.TrimSuffix(e.baseURL, "/") + "/embeddings"
+	headers := map[string]string{}
+	if e.apiKey != "" {
+		headers["Authorization"] = "Bearer " + e.apiKey
+	}
+
+	vectors := make([][]float32, len(texts))
+	for start := 0; start < len(texts); start += embeddingBatchSize {
+		end := start + embeddingBatchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+
+		reqCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+		status, _, body, err := postJSON(reqCtx, e.client, url, headers, map[string]interface{}{
+			"model": e.model,
+			"input": texts[start:end],
+		})
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		switch status {
+		case http.StatusOK:
+		case http.StatusUnauthorized:
+			return nil, fmt.Errorf("invalid API key")
+		case http.StatusTooManyRequests:
+			return nil, fmt.Errorf("rate limit exceeded, please try again later")
+		default:
+			return nil, fmt.Errorf("embeddings API error (status %d): %s", status, string(body))
+		}
+
+		var resp struct {
+			Data []struct {
+				Index     int       `json:"index"`
+				Embedding []float32 `json:"embedding"`
+			} `json:"data"`
+			Usage struct {
+				PromptTokens int `json:"prompt_tokens"`
+			} `json:"usage"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return nil, fmt.Errorf("failed to parse embeddings: %v", err)
+		}
+		usage.add(resp.Usage.PromptTokens, 0)
+		for _, item := range resp.Data {
+			if item.Index < 0 || start+item.Index >= end {
+				return nil, fmt.Errorf("embedding index %d out of range", item.Index)
+			}
+			vectors[start+item.Index] = item.Embedding
+		}
+	}
+
+	for i, vector := range vectors {
+		if len(vector) == 0 {
+			return nil, fmt.Errorf("no embedding returned for text %d", i)
+		}
+	}
+	return vectors, nil
+}
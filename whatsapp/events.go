@@ -0,0 +1,220 @@
+package whatsapp
+
+import (
+	"fmt"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// PresenceUpdate represents an online/last seen change of a contact
+type PresenceUpdate struct {
+	JID      string `json:"jid"`
+	IsOnline bool   `json:"isOnline"`
+	LastSeen string `json:"lastSeen,omitempty"`
+}
+
+// TypingUpdate represents a typing (composing) state change in a chat
+type TypingUpdate struct {
+	ChatID   string `json:"chatId"`
+	SenderID string `json:"senderId"`
+	IsTyping bool   `json:"isTyping"`
+	Media    string `json:"media,omitempty"` // "" for text, "audio" for voice recording
+}
+
+// UnreadUpdate represents a change in the unread counter of a chat
+type UnreadUpdate struct {
+	ChatID string `json:"chatId"`
+	Unread int    `json:"unread"`
+}
+
+// GroupUpdate represents a change in group metadata or membership
+type GroupUpdate struct {
+	ChatID  string   `json:"chatId"`
+	Name    string   `json:"name,omitempty"`
+	Topic   string   `json:"topic,omitempty"`
+	Joined  []string `json:"joined,omitempty"`
+	Left    []string `json:"left,omitempty"`
+	Promote []string `json:"promote,omitempty"`
+	Demote  []string `json:"demote,omitempty"`
+	Deleted bool     `json:"deleted,omitempty"`
+}
+
+// emitEvent sends an event to the frontend without blocking the WhatsApp event loop
+func (m *Manager) emitEvent(event ConnectionEvent) {
+	select {
+	case m.eventChan <- event:
+	default:
+		m.log.Warnf("Event channel full, dropping %s event", event.Type)
+	}
+}
+
+// handleRealtimeEvent forwards chat activity (messages, presence, typing, groups) to the frontend
+func (m *Manager) handleRealtimeEvent(evt interface{}) {
+	switch v := evt.(type) {
+	case *events.Message:
+		m.emitEvent(ConnectionEvent{
+			Type:    "message",
+			Message: "New message",
+			Payload: m.messageFromEvent(v),
+		})
+		m.emitChatUpdate(v.Info.Chat.String())
+
+	case *events.Connected:
+		// Auto-sends wait for the connection, drafts due while offline go out now
+		if m.approvals != nil {
+			go m.approvals.Resume()
+		}
+
+	case *events.Receipt:
+		// Messages read on another device clear the unread counter here too
+		if v.Type != types.ReceiptTypeReadSelf && !(v.Type == types.ReceiptTypeRead && v.IsFromMe) {
+			return
+		}
+		if m.messageDB == nil {
+			return
+		}
+		if err := m.messageDB.MarkChatAsRead(v.Chat.String()); err != nil {
+			m.log.Errorf("Failed to mark chat as read: %v", err)
+			return
+		}
+		m.emitChatUpdate(v.Chat.String())
+
+	case *events.LoggedOut:
+		// whatsmeow already deleted the device store when the phone unlinked us
+		m.emitEvent(ConnectionEvent{
+			Type:    "logged_out",
+			Message: fmt.Sprintf("Logged out: %s", v.Reason.String()),
+		})
+
+	case *events.Presence:
+		update := PresenceUpdate{
+			JID:      v.From.String(),
+			IsOnline: !v.Unavailable,
+		}
+		if !v.LastSeen.IsZero() {
+			update.LastSeen = v.LastSeen.Format(time.RFC3339)
+		}
+		m.emitEvent(ConnectionEvent{
+			Type:    "presence",
+			Message: "Presence updated",
+			Payload: update,
+		})
+
+	case *events.ChatPresence:
+		m.emitEvent(ConnectionEvent{
+			Type:    "typing",
+			Message: "Chat presence updated",
+			Payload: TypingUpdate{
+				ChatID:   v.Chat.String(),
+				SenderID: v.Sender.String(),
+				IsTyping: v.State == types.ChatPresenceComposing,
+				Media:    string(v.Media),
+			},
+		})
+
+	case *events.GroupInfo:
+		update := GroupUpdate{
+			ChatID:  v.JID.String(),
+			Joined:  jidsToStrings(v.Join),
+			Left:    jidsToStrings(v.Leave),
+			Promote: jidsToStrings(v.Promote),
+			Demote:  jidsToStrings(v.Demote),
+			Deleted: v.Delete != nil,
+		}
+		if v.Name != nil {
+			update.Name = v.Name.Name
+			m.renameChat(update.ChatID, update.Name)
+		}
+		if v.Topic != nil {
+			update.Topic = v.Topic.Topic
+		}
+		m.emitEvent(ConnectionEvent{
+			Type:    "group",
+			Message: "Group updated",
+			Payload: update,
+		})
+
+	case *events.JoinedGroup:
+		chatID := v.JID.String()
+		m.renameChat(chatID, v.Name)
+		m.emitEvent(ConnectionEvent{
+			Type:    "group",
+			Message: "Joined group",
+			Payload: GroupUpdate{
+				ChatID: chatID,
+				Name:   v.Name,
+				Topic:  v.Topic,
+			},
+		})
+		m.emitChatUpdate(chatID)
+	}
+}
+
+// emitChatUpdate sends the current state of a chat and its unread counter to the frontend
+func (m *Manager) emitChatUpdate(chatID string) {
+	chat, err := m.GetChat(chatID)
+	if err != nil || chat == nil {
+		return
+	}
+
+	m.emitEvent(ConnectionEvent{
+		Type:    "chat_update",
+		Message: "Chat updated",
+		Payload: chat,
+	})
+	m.emitEvent(ConnectionEvent{
+		Type:    "unread",
+		Message: "Unread count updated",
+		Payload: UnreadUpdate{ChatID: chat.ID, Unread: chat.Unread},
+	})
+}
+
+// renameChat stores a new display name for a chat
+func (m *Manager) renameChat(chatID, name string) {
+	if m.messageDB == nil || name == "" {
+		return
+	}
+	if err := m.messageDB.UpdateChatName(chatID, name); err != nil {
+		m.log.Errorf("Failed to update chat name: %v", err)
+	}
+}
+
+// messageFromEvent converts a WhatsApp message event into the frontend Message format
+func (m *Manager) messageFromEvent(evt *events.Message) Message {
+	content, messageType, _, _, caption := m.messageDB.extractMessageContent(evt.Message)
+	if caption != "" {
+		content = caption
+	}
+
+	author := "Me"
+	if !evt.Info.IsFromMe {
+		author = evt.Info.PushName
+		if author == "" {
+			author = m.getContactName(evt.Info.Sender.ToNonAD().String())
+		}
+	}
+
+	return Message{
+		ID:     evt.Info.ID,
+		ChatID: evt.Info.Chat.String(),
+		Author: author,
+		Text:   content,
+		Time:   m.formatMessageTime(evt.Info.Timestamp.Unix()),
+		IsMine: evt.Info.IsFromMe,
+		Type:   messageType,
+	}
+}
+
+// jidsToStrings converts a list of JIDs to their string form
+func jidsToStrings(jids []types.JID) []string {
+	if len(jids) == 0 {
+		return nil
+	}
+	result := make([]string, 0, len(jids))
+	for _, jid := range jids {
+		result = append(result, jid.String())
+	}
+	return result
+}
@@ -0,0 +1,266 @@
+package whatsapp
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// FailoverConfig controls retries of failed AI calls and the providers tried next
+type FailoverConfig struct {
+	Providers        []string `json:"providers"`         // tried in order when the configured provider fails
+	MaxAttempts      int      `json:"max_attempts"`      // calls per provider before moving on
+	MaxRetryWait     int      `json:"max_retry_wait"`    // seconds; longer Retry-After waits move on right away
+	BreakerThreshold int      `json:"breaker_threshold"` // consecutive failures that open a provider's circuit, 0 disables it
+	BreakerCooldown  int      `json:"breaker_cooldown"`  // seconds an open circuit skips the provider
+	ApologyMessage   string   `json:"apology_message"`   // sent when all providers fail, empty sends nothing
+}
+
+// GetDefaultFailoverConfig returns the default retry and fallback settings
+func GetDefaultFailoverConfig() FailoverConfig {
+	return FailoverConfig{
+		Providers:        []string{},
+		MaxAttempts:      3,
+		MaxRetryWait:     60,
+		BreakerThreshold: 3,
+		BreakerCooldown:  120,
+		ApologyMessage:   "Sorry, I can't answer right now. I'll get back to you as soon as possible.",
+	}
+}
+
+// Validate checks the retry and fallback settings
+func (c *FailoverConfig) Validate() error {
+	if c.MaxAttempts < 1 {
+		return fmt.Errorf("attempts per provider must be at least 1")
+	}
+	if c.MaxRetryWait < 0 || c.BreakerThreshold < 0 || c.BreakerCooldown < 0 {
+		return fmt.Errorf("retry wait and circuit breaker settings cannot be negative")
+	}
+	known := ProviderNames()
+	for _, name := range c.Providers {
+		if !containsString(known, name) {
+			return fmt.Errorf("unknown fallback provider: %s", name)
+		}
+	}
+	return nil
+}
+
+// providerChain returns the configured provider followed by the fallback providers
+func (c *AutoReplyConfig) providerChain() []string {
+	chain := []string{c.AIProvider}
+	for _, name := range c.Failover.Providers {
+		if !containsString(chain, name) {
+			chain = append(chain, name)
+		}
+	}
+	return chain
+}
+
+// retryDelay returns how long to wait before calling the provider again after err, or
+// false if the next provider should be tried instead. Auth and permanent errors are not
+// retried; rate limits wait as long as the provider asks, up to MaxRetryWait.
+func (c *FailoverConfig) retryDelay(err error, attempt int) (time.Duration, bool) {
+	var wait time.Duration
+	switch errorKind(err) {
+	case ErrorAuth, ErrorPermanent, ErrorNoTools:
+		return 0, false
+	case ErrorRateLimit:
+		wait = time.Duration(attempt*10) * time.Second
+		var providerErr *ProviderError
+		if errors.As(err, &providerErr) && providerErr.RetryAfter > 0 {
+			wait = providerErr.RetryAfter
+		}
+	default:
+		wait = time.Duration(attempt*5) * time.Second
+	}
+
+	if wait > time.Duration(c.MaxRetryWait)*time.Second {
+		return 0, false
+	}
+	return wait, true
+}
+
+// ProviderHealth is the circuit breaker state of a provider
+type ProviderHealth struct {
+	Provider  string `json:"provider"`
+	Failures  int    `json:"failures"` // consecutive failed calls
+	Open      bool   `json:"open"`     // the provider is skipped until OpenUntil
+	OpenUntil int64  `json:"open_until,omitempty"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// CircuitBreaker skips providers that keep failing until a cooldown has passed
+type CircuitBreaker struct {
+	mu     sync.Mutex
+	states map[string]*breakerState
+}
+
+type breakerState struct {
+	failures  int
+	openUntil time.Time
+	lastError string
+}
+
+// NewCircuitBreaker creates a circuit breaker with all circuits closed
+func NewCircuitBreaker() *CircuitBreaker {
+	return &CircuitBreaker{states: make(map[string]*breakerState)}
+}
+
+// allow reports whether the provider may be called. Once the cooldown of an open circuit
+// has passed, a single trial call is let through; its outcome closes the circuit or
+// keeps it open for another cooldown.
+func (b *CircuitBreaker) allow(name string, config FailoverConfig, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := b.states[name]
+	if state == nil || config.BreakerThreshold == 0 || state.failures < config.BreakerThreshold {
+		return true
+	}
+	if now.Before(state.openUntil) {
+		return false
+	}
+	state.openUntil = now.Add(time.Duration(config.BreakerCooldown) * time.Second)
+	return true
+}
+
+// success closes the circuit of the provider
+func (b *CircuitBreaker) success(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.states, name)
+}
+
+// failure counts a failed call, opening the circuit at the threshold
+func (b *CircuitBreaker) failure(name string, config FailoverConfig, err error, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := b.states[name]
+	if state == nil {
+		state = &breakerState{}
+		b.states[name] = state
+	}
+	state.failures++
+	state.lastError = err.Error()
+	if config.BreakerThreshold > 0 && state.failures == config.BreakerThreshold {
+		fmt.Printf("AI provider %s failed %d times in a row, skipping it for %ds\n", name, state.failures, config.BreakerCooldown)
+		state.openUntil = now.Add(time.Duration(config.BreakerCooldown) * time.Second)
+	}
+}
+
+// status returns the state of all providers that failed recently
+func (b *CircuitBreaker) status(config FailoverConfig, now time.Time) []ProviderHealth {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	health := make([]ProviderHealth, 0, len(b.states))
+	for name, state := range b.states {
+		entry := ProviderHealth{Provider: name, Failures: state.failures, LastError: state.lastError}
+		if config.BreakerThreshold > 0 && state.failures >= config.BreakerThreshold && now.Before(state.openUntil) {
+			entry.Open = true
+			entry.OpenUntil = state.openUntil.Unix()
+		}
+		health = append(health, entry)
+	}
+	sort.Slice(health, func(i, j int) bool { return health[i].Provider < health[j].Provider })
+	return health
+}
+
+// generateWithFailover generates the reply of a job with the first provider of the chain
+// that succeeds, retrying each provider depending on the kind of error. It returns the
+// reply, the provider that gave it and the stream of the last call. Once part of a reply
+// was sent early, neither retries nor other providers are tried.
+func (arm *AutoReplyManager) generateWithFailover(manager *Manager, job *replyJob, conversation []ChatMessage, tools *toolSession) (string, string, *replyStream, error) {
+	failover := job.config.Failover
+	attempts := failover.MaxAttempts
+	dryRun := job.decision.DryRun
+	if dryRun {
+		// A dry run reports the first error of each provider and leaves the circuits
+		// of live traffic alone
+		attempts = 1
+	}
+
+	stream := arm.newReplyStream(manager, job)
+	var lastErr error
+	for _, name := range job.config.providerChain() {
+		if !arm.breaker.allow(name, failover, time.Now()) {
+			fmt.Printf("Skipping AI provider %s, its circuit is open\n", name)
+			if lastErr == nil {
+				lastErr = fmt.Errorf("%s is unavailable after repeated failures", name)
+			}
+			continue
+		}
+
+		var err error
+		for attempt := 1; attempt <= attempts; attempt++ {
+			// Keep typing status active
+			arm.setPresence(manager, job, types.ChatPresenceComposing)
+
+			stream = arm.newReplyStream(manager, job)
+			var response string
+			response, err = arm.generateAIResponse(manager, job, name, conversation, tools, stream)
+			if err == nil {
+				if !dryRun {
+					arm.breaker.success(name)
+				}
+				return response, name, stream, nil
+			}
+			if arm.ctx.Err() != nil {
+				return "", name, stream, err
+			}
+			if stream.sent() {
+				if !dryRun {
+					arm.breaker.failure(name, failover, err, time.Now())
+				}
+				return "", name, stream, err
+			}
+
+			wait, retry := failover.retryDelay(err, attempt)
+			if !retry || attempt == attempts {
+				break
+			}
+			fmt.Printf("AI provider %s failed (%s), retry %d/%d in %v: %v\n", name, errorKind(err), attempt+1, attempts, wait, err)
+			if !arm.sleep(wait) {
+				return "", name, stream, err
+			}
+		}
+
+		if !dryRun {
+			arm.breaker.failure(name, failover, err, time.Now())
+		}
+		fmt.Printf("AI provider %s failed: %v\n", name, err)
+		lastErr = fmt.Errorf("%s: %v", name, err)
+	}
+
+	return "", "", stream, fmt.Errorf("all AI providers failed, %v", lastErr)
+}
+
+// sendApology tells the contact that no reply could be generated, returning the sent
+// message ID or "" if none was sent
+func (arm *AutoReplyManager) sendApology(manager *Manager, job *replyJob) string {
+	message := job.config.Failover.ApologyMessage
+	if message == "" || job.decision.DryRun || arm.ctx.Err() != nil || job.config.Approval.supervised(job.sender) || manager.isAutoReplyPaused(job.chatJID) {
+		return ""
+	}
+
+	id, err := manager.SendText(job.chatJID, message)
+	if err != nil {
+		fmt.Printf("Failed to send apology message: %v\n", err)
+		return ""
+	}
+	arm.flood.recordSent(job.chatJID, time.Now())
+	return id
+}
+
+// GetProviderHealth returns the circuit breaker state of the AI providers
+func (m *Manager) GetProviderHealth() ([]ProviderHealth, error) {
+	if m.autoReply == nil {
+		return nil, fmt.Errorf("auto-reply not configured")
+	}
+	return m.autoReply.breaker.status(m.autoReply.GetConfig().Failover, time.Now()), nil
+}
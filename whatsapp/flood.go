@@ -0,0 +1,215 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FloodConfig limits how much auto-reply traffic a single chat can cause
+type FloodConfig struct {
+	MaxRepliesPerContact int `json:"max_replies_per_contact"` // per rate window, 0 disables the limit
+	RateWindowMinutes    int `json:"rate_window_minutes"`
+	DebounceSeconds      int `json:"debounce_seconds"` // bursts within this window get one reply, 0 disables
+	MaxConcurrent        int `json:"max_concurrent"`   // AI requests in flight across all chats, 0 is unlimited
+
+	// Loop detection pauses a chat when the other side keeps answering our replies instantly
+	LoopDetection    bool `json:"loop_detection"`
+	LoopReplySeconds int  `json:"loop_reply_seconds"` // an answer this fast counts as automated
+	LoopThreshold    int  `json:"loop_threshold"`     // automated exchanges in a row before pausing
+	LoopPauseMinutes int  `json:"loop_pause_minutes"`
+}
+
+// GetDefaultFloodConfig returns the default flood protection settings
+func GetDefaultFloodConfig() FloodConfig {
+	return FloodConfig{
+		MaxRepliesPerContact: 20,
+		RateWindowMinutes:    60,
+		DebounceSeconds:      3,
+		MaxConcurrent:        4,
+		LoopDetection:        true,
+		LoopReplySeconds:     5,
+		LoopThreshold:        5,
+		LoopPauseMinutes:     60,
+	}
+}
+
+// Validate checks the flood protection limits
+func (c *FloodConfig) Validate() error {
+	if c.MaxRepliesPerContact < 0 || c.RateWindowMinutes < 0 || c.DebounceSeconds < 0 || c.MaxConcurrent < 0 {
+		return fmt.Errorf("flood limits cannot be negative")
+	}
+	if c.MaxRepliesPerContact > 0 && c.RateWindowMinutes == 0 {
+		return fmt.Errorf("rate window is required when replies per contact are limited")
+	}
+	if c.LoopDetection && (c.LoopReplySeconds <= 0 || c.LoopThreshold <= 0 || c.LoopPauseMinutes < 0) {
+		return fmt.Errorf("loop detection needs a reply time, threshold and pause duration")
+	}
+	return nil
+}
+
+// chatFloodState is the flood bookkeeping of a single chat
+type chatFloodState struct {
+	replies     []time.Time // replies scheduled within the rate window
+	lastReplyAt time.Time   // last reply actually sent
+	answered    bool        // the last reply already got its first answer
+	fastAnswers int         // consecutive replies answered right after they were sent
+
+	debounce *time.Timer
+	pending  *replyJob
+}
+
+// FloodGuard enforces rate limits, loop detection, debouncing and the concurrency cap
+type FloodGuard struct {
+	mu    sync.Mutex
+	chats map[string]*chatFloodState
+	sem   chan struct{}
+}
+
+// NewFloodGuard creates a flood guard without history
+func NewFloodGuard() *FloodGuard {
+	return &FloodGuard{
+		chats: make(map[string]*chatFloodState),
+	}
+}
+
+// state returns the bookkeeping of a chat. Callers must hold g.mu.
+func (g *FloodGuard) state(chatJID string) *chatFloodState {
+	state, ok := g.chats[chatJID]
+	if !ok {
+		state = &chatFloodState{}
+		g.chats[chatJID] = state
+	}
+	return state
+}
+
+// detectLoop records an incoming message and reports whether the chat looks like
+// two bots answering each other
+func (g *FloodGuard) detectLoop(config FloodConfig, chatJID string, now time.Time) bool {
+	if !config.LoopDetection {
+		return false
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	// Only the first answer to each reply counts, so a person typing several quick
+	// lines after one reply is not mistaken for a bot
+	state := g.state(chatJID)
+	if state.lastReplyAt.IsZero() || state.answered {
+		return false
+	}
+	state.answered = true
+
+	if now.Sub(state.lastReplyAt) <= time.Duration(config.LoopReplySeconds)*time.Second {
+		state.fastAnswers++
+	} else {
+		state.fastAnswers = 0
+	}
+
+	if state.fastAnswers >= config.LoopThreshold {
+		state.fastAnswers = 0
+		return true
+	}
+	return false
+}
+
+// reserveReply reports whether the chat is still within its rate limit and counts the reply
+func (g *FloodGuard) reserveReply(config FloodConfig, chatJID string, now time.Time) bool {
+	if config.MaxRepliesPerContact <= 0 {
+		return true
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	state := g.state(chatJID)
+	window := time.Duration(config.RateWindowMinutes) * time.Minute
+
+	// Drop replies that left the window
+	kept := state.replies[:0]
+	for _, at := range state.replies {
+		if now.Sub(at) < window {
+			kept = append(kept, at)
+		}
+	}
+	state.replies = kept
+
+	if len(state.replies) >= config.MaxRepliesPerContact {
+		return false
+	}
+	state.replies = append(state.replies, now)
+	return true
+}
+
+// recordSent notes that an automated reply was sent to the chat
+func (g *FloodGuard) recordSent(chatJID string, at time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	state := g.state(chatJID)
+	state.lastReplyAt = at
+	state.answered = false
+}
+
+// debounce holds the job until the chat has been quiet for the given delay, replacing any
+// job already waiting. fire runs once with the latest job. It returns the replaced job, and
+// reports whether the job joined a waiting reply, in which case no new reply was scheduled.
+func (g *FloodGuard) debounce(chatJID string, delay time.Duration, job *replyJob, fire func(*replyJob)) (*replyJob, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	state := g.state(chatJID)
+	replaced := state.pending
+	if replaced != nil {
+		// Keep the photos sent earlier in the burst, e.g. a picture followed by a question
+		job.images = append(state.pending.images, job.images...)
+		if len(job.images) > maxVisionImages {
+			job.images = job.images[len(job.images)-maxVisionImages:]
+		}
+	}
+	state.pending = job
+
+	if state.debounce != nil {
+		// The waiting timer now fires for the newer job. If it already fired, its
+		// callback is blocked on g.mu and picks up the newer job.
+		if state.debounce.Stop() {
+			state.debounce.Reset(delay)
+		}
+		return replaced, true
+	}
+
+	state.debounce = time.AfterFunc(delay, func() {
+		g.mu.Lock()
+		pending := state.pending
+		state.pending = nil
+		state.debounce = nil
+		g.mu.Unlock()
+
+		// Only this callback clears pending, so it always holds a job here
+		fire(pending)
+	})
+	return replaced, false
+}
+
+// acquire waits for a free AI request slot, returning false if ctx is cancelled first
+func (g *FloodGuard) acquire(ctx context.Context, limit int) (func(), bool) {
+	if limit <= 0 {
+		return func() {}, true
+	}
+
+	g.mu.Lock()
+	if g.sem == nil || cap(g.sem) != limit {
+		// Requests holding a slot of the previous semaphore release it there
+		g.sem = make(chan struct{}, limit)
+	}
+	sem := g.sem
+	g.mu.Unlock()
+
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, true
+	case <-ctx.Done():
+		return nil, false
+	}
+}
@@ -0,0 +1,224 @@
+package whatsapp
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+var (
+	codeFenceRe  = regexp.MustCompile("(?s)```[a-zA-Z0-9_+-]*\\n?(.*?)```")
+	inlineCodeRe = regexp.MustCompile("`([^`\\n]+)`")
+	boldRe       = regexp.MustCompile(`\*\*([^*\n]+)\*\*|__([^_\n]+)__`)
+	italicRe     = regexp.MustCompile(`\*([^*\n]+)\*`)
+	strikeRe     = regexp.MustCompile(`~~([^~\n]+)~~`)
+	headingRe    = regexp.MustCompile(`(?m)^#{1,6}\s+(.+?)\s*#*\s*$`)
+	bulletRe     = regexp.MustCompile(`(?m)^(\s*)[*+]\s+`)
+	linkRe       = regexp.MustCompile(`\[([^\]]+)\]\((https?://[^)\s]+)\)`)
+	sentenceRe   = regexp.MustCompile(`[.!?…]+["')\]]*\s+`)
+)
+
+// Placeholders keep converted markup from being converted again
+const (
+	boldMark = "\x01"
+	codeMark = "\x02"
+)
+
+// markdownToWhatsApp converts the Markdown models like to produce into WhatsApp formatting:
+// **bold** becomes *bold*, *italic* becomes _italic_, ~~strike~~ becomes ~strike~,
+// headings become bold lines and code is wrapped in ``` blocks
+func markdownToWhatsApp(text string) string {
+	// Take code out first so its content is left alone
+	var code []string
+	protect := func(block string) string {
+		code = append(code, block)
+		return fmt.Sprintf("%s%d%s", codeMark, len(code)-1, codeMark)
+	}
+	text = codeFenceRe.ReplaceAllStringFunc(text, func(match string) string {
+		body := codeFenceRe.FindStringSubmatch(match)[1]
+		return protect("```" + strings.TrimRight(body, "\n") + "```")
+	})
+	text = inlineCodeRe.ReplaceAllStringFunc(text, func(match string) string {
+		return protect("```" + inlineCodeRe.FindStringSubmatch(match)[1] + "```")
+	})
+
+	text = linkRe.ReplaceAllString(text, "$1 ($2)")
+	text = bulletRe.ReplaceAllString(text, "$1- ")
+	text = headingRe.ReplaceAllString(text, boldMark+"$1"+boldMark)
+	text = boldRe.ReplaceAllStringFunc(text, func(match string) string {
+		groups := boldRe.FindStringSubmatch(match)
+		inner := groups[1]
+		if inner == "" {
+			inner = groups[2]
+		}
+		return boldMark + inner + boldMark
+	})
+	text = italicRe.ReplaceAllString(text, "_${1}_")
+	text = strikeRe.ReplaceAllString(text, "~$1~")
+	text = strings.ReplaceAll(text, boldMark, "*")
+
+	for i, block := range code {
+		text = strings.Replace(text, fmt.Sprintf("%s%d%s", codeMark, i, codeMark), block, 1)
+	}
+	return text
+}
+
+// splitMessage splits a reply into parts of at most maxRunes characters, breaking
+// between paragraphs, then lines, sentences and words. maxRunes <= 0 returns the text as is.
+func splitMessage(text string, maxRunes int) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	if maxRunes <= 0 || utf8.RuneCountInString(text) <= maxRunes {
+		return []string{text}
+	}
+
+	var parts []string
+	var current strings.Builder
+	flush := func() {
+		if part := strings.TrimSpace(current.String()); part != "" {
+			parts = append(parts, part)
+		}
+		current.Reset()
+	}
+	add := func(piece, separator string) {
+		if current.Len() > 0 && utf8.RuneCountInString(current.String())+utf8.RuneCountInString(separator+piece) > maxRunes {
+			flush()
+		}
+		if current.Len() > 0 {
+			current.WriteString(separator)
+		}
+		current.WriteString(piece)
+	}
+
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+		if utf8.RuneCountInString(paragraph) <= maxRunes {
+			add(paragraph, "\n\n")
+			continue
+		}
+
+		// Paragraph too long for one message, fill parts line by line, then sentence by sentence
+		flush()
+		for _, line := range strings.Split(paragraph, "\n") {
+			if utf8.RuneCountInString(line) <= maxRunes {
+				add(line, "\n")
+				continue
+			}
+			for _, sentence := range splitSentences(line) {
+				if utf8.RuneCountInString(sentence) <= maxRunes {
+					add(sentence, " ")
+					continue
+				}
+				for _, piece := range splitWords(sentence, maxRunes) {
+					add(piece, " ")
+				}
+			}
+		}
+		flush()
+	}
+	flush()
+
+	return parts
+}
+
+// splitSentences splits text after sentence-ending punctuation
+func splitSentences(text string) []string {
+	var sentences []string
+	last := 0
+	for _, loc := range sentenceRe.FindAllStringIndex(text, -1) {
+		sentences = append(sentences, strings.TrimSpace(text[last:loc[1]]))
+		last = loc[1]
+	}
+	if rest := strings.TrimSpace(text[last:]); rest != "" {
+		sentences = append(sentences, rest)
+	}
+	return sentences
+}
+
+// splitWords splits text into pieces of at most maxRunes characters between words,
+// cutting words that are longer than a whole piece
+func splitWords(text string, maxRunes int) []string {
+	var pieces []string
+	var current []rune
+	for _, word := range strings.FieldsFunc(text, unicode.IsSpace) {
+		runes := []rune(word)
+		for len(runes) > maxRunes {
+			if len(current) > 0 {
+				pieces = append(pieces, string(current))
+				current = nil
+			}
+			pieces = append(pieces, string(runes[:maxRunes]))
+			runes = runes[maxRunes:]
+		}
+		if len(current) > 0 && len(current)+1+len(runes) > maxRunes {
+			pieces = append(pieces, string(current))
+			current = nil
+		}
+		if len(current) > 0 {
+			current = append(current, ' ')
+		}
+		current = append(current, runes...)
+	}
+	if len(current) > 0 {
+		pieces = append(pieces, string(current))
+	}
+	return pieces
+}
+
+// limitParts keeps at most maxParts parts, marking the last kept one as cut off
+func limitParts(parts []string, maxParts int) []string {
+	if maxParts <= 0 || len(parts) <= maxParts {
+		return parts
+	}
+	parts = parts[:maxParts]
+	parts[maxParts-1] += " …"
+	return parts
+}
+
+// replyPartDelay is the pause between the parts of a split reply
+const replyPartDelay = time.Second
+
+// replyParts splits a formatted reply into the messages it is sent as
+func (c *AutoReplyConfig) replyParts(text string) []string {
+	return limitParts(splitMessage(text, c.MaxResponseLength), c.MaxReplyParts)
+}
+
+// maxReplyTokens estimates the tokens a reply may use across all of its parts
+func (c *AutoReplyConfig) maxReplyTokens() int {
+	parts := c.MaxReplyParts
+	if parts <= 0 {
+		parts = 1
+	}
+	return c.MaxResponseLength * parts / 4 // Rough token estimation
+}
+
+// sendReply sends a reply as one or more messages, showing the typing indicator in
+// between. It returns the IDs of the parts sent, which are less than all on error.
+func (m *Manager) sendReply(config *AutoReplyConfig, chatJID, text string) ([]string, error) {
+	parts := config.replyParts(text)
+	var sent []string
+	for i, part := range parts {
+		if i > 0 {
+			if err := m.SendChatPresence(chatJID, types.ChatPresenceComposing); err != nil {
+				m.log.Errorf("Failed to send typing status: %v", err)
+			}
+			time.Sleep(replyPartDelay)
+		}
+		id, err := m.SendText(chatJID, part)
+		if err != nil {
+			return sent, fmt.Errorf("failed to send part %d of %d: %v", i+1, len(parts), err)
+		}
+		sent = append(sent, id)
+	}
+	return sent, nil
+}
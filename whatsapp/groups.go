@@ -0,0 +1,133 @@
+package whatsapp
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// GroupReplyConfig controls when the assistant answers in group chats
+type GroupReplyConfig struct {
+	Enabled        bool     `json:"enabled"`
+	ReplyOnMention bool     `json:"reply_on_mention"` // our account is @mentioned
+	ReplyOnQuote   bool     `json:"reply_on_quote"`   // the message replies to one of our messages
+	TriggerPrefix  string   `json:"trigger_prefix"`   // e.g. "/ask", empty to disable
+	AllowedGroups  []string `json:"allowed_groups"`   // group JIDs, empty allows every group
+}
+
+// GetDefaultGroupReplyConfig returns the default group mode, disabled
+func GetDefaultGroupReplyConfig() GroupReplyConfig {
+	return GroupReplyConfig{
+		Enabled:        false,
+		ReplyOnMention: true,
+		ReplyOnQuote:   true,
+		TriggerPrefix:  "/ask",
+	}
+}
+
+// groupSystemPrompt is appended to the system prompt in group chats
+const groupSystemPrompt = "You are taking part in a WhatsApp group chat. Each user message starts with the name of its sender. Reply to the last message only, without prefixing your reply with a name."
+
+// allowsGroup reports whether the assistant may answer in the group
+func (c *GroupReplyConfig) allowsGroup(groupJID string) bool {
+	if len(c.AllowedGroups) == 0 {
+		return true
+	}
+	for _, allowed := range c.AllowedGroups {
+		if allowed == groupJID {
+			return true
+		}
+	}
+	return false
+}
+
+// groupTrigger checks whether a group message addresses the assistant. It returns the
+// question with the trigger prefix and our mention removed.
+func (arm *AutoReplyManager) groupTrigger(evt *events.Message, manager *Manager, text string) (string, bool) {
+	config := arm.config.GroupReply
+	if !config.Enabled || !config.allowsGroup(evt.Info.Chat.String()) {
+		return "", false
+	}
+
+	own := manager.ownUsers()
+
+	// The prefix must be a word of its own, so "/askme" doesn't trigger "/ask"
+	if prefix := strings.TrimSpace(config.TriggerPrefix); prefix != "" {
+		trimmed := strings.TrimSpace(text)
+		if len(trimmed) >= len(prefix) && strings.EqualFold(trimmed[:len(prefix)], prefix) {
+			rest := trimmed[len(prefix):]
+			if first, _ := utf8.DecodeRuneInString(rest); rest == "" || unicode.IsSpace(first) {
+				return strings.TrimSpace(rest), true
+			}
+		}
+	}
+
+	contextInfo := messageContextInfo(evt.Message)
+	if contextInfo == nil {
+		return "", false
+	}
+
+	if config.ReplyOnMention {
+		for _, mentioned := range contextInfo.GetMentionedJID() {
+			if jid, err := types.ParseJID(mentioned); err == nil && own[jid.User] {
+				return stripMentions(text, own), true
+			}
+		}
+	}
+
+	if config.ReplyOnQuote && contextInfo.GetStanzaID() != "" {
+		if jid, err := types.ParseJID(contextInfo.GetParticipant()); err == nil && own[jid.User] {
+			return stripMentions(text, own), true
+		}
+	}
+
+	return "", false
+}
+
+// messageContextInfo returns the context info (mentions, quoted message) of a message
+func messageContextInfo(msg *waProto.Message) *waProto.ContextInfo {
+	if msg == nil {
+		return nil
+	}
+
+	switch {
+	case msg.GetExtendedTextMessage() != nil:
+		return msg.GetExtendedTextMessage().GetContextInfo()
+	case msg.GetImageMessage() != nil:
+		return msg.GetImageMessage().GetContextInfo()
+	case msg.GetVideoMessage() != nil:
+		return msg.GetVideoMessage().GetContextInfo()
+	case msg.GetAudioMessage() != nil:
+		return msg.GetAudioMessage().GetContextInfo()
+	case msg.GetDocumentMessage() != nil:
+		return msg.GetDocumentMessage().GetContextInfo()
+	}
+	return nil
+}
+
+// stripMentions removes "@<number>" mentions of our account from the text
+func stripMentions(text string, own map[string]bool) string {
+	for user := range own {
+		text = strings.ReplaceAll(text, "@"+user, "")
+	}
+	return strings.TrimSpace(text)
+}
+
+// ownUsers returns the user parts of our phone number and LID identities
+func (m *Manager) ownUsers() map[string]bool {
+	users := make(map[string]bool)
+	if m.client == nil || m.client.Store == nil {
+		return users
+	}
+	if m.client.Store.ID != nil {
+		users[m.client.Store.ID.User] = true
+	}
+	if lid := m.client.Store.GetLID(); !lid.IsEmpty() {
+		users[lid.User] = true
+	}
+	return users
+}
@@ -0,0 +1,240 @@
+package whatsapp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waHistorySync"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// HistorySyncConfig holds the limits applied when importing history sync data
+type HistorySyncConfig struct {
+	Enabled            bool `json:"enabled"`
+	MaxDays            int  `json:"max_days"`              // 0 means no age limit
+	MaxMessagesPerChat int  `json:"max_messages_per_chat"` // 0 means unlimited
+	IncludeGroups      bool `json:"include_groups"`
+	IncludeArchived    bool `json:"include_archived"`
+}
+
+// HistorySyncProgress is reported to the frontend after each history sync chunk
+type HistorySyncProgress struct {
+	SyncType      string `json:"syncType"`
+	ChunkOrder    uint32 `json:"chunkOrder"`
+	Progress      uint32 `json:"progress"` // percentage reported by the phone, 0-100
+	Conversations int    `json:"conversations"`
+	Messages      int    `json:"messages"`
+	Skipped       int    `json:"skipped"`
+}
+
+// GetDefaultHistorySyncConfig returns default history sync configuration
+func GetDefaultHistorySyncConfig() *HistorySyncConfig {
+	return &HistorySyncConfig{
+		Enabled:            true,
+		MaxDays:            90,
+		MaxMessagesPerChat: 200,
+		IncludeGroups:      true,
+		IncludeArchived:    true,
+	}
+}
+
+// handleHistorySync stores the conversations and messages of a history sync blob
+func (m *Manager) handleHistorySync(evt *events.HistorySync) {
+	if m.messageDB == nil || evt.Data == nil {
+		return
+	}
+
+	config := m.GetHistorySyncConfig()
+	if !config.Enabled {
+		return
+	}
+
+	data := evt.Data
+	progress := HistorySyncProgress{
+		SyncType:   data.GetSyncType().String(),
+		ChunkOrder: data.GetChunkOrder(),
+		Progress:   data.GetProgress(),
+	}
+
+	// Push names are used as chat names when the conversation has none
+	pushNames := make(map[string]string)
+	for _, pn := range data.GetPushnames() {
+		if pn.GetID() == "" || pn.GetPushname() == "" {
+			continue
+		}
+		pushNames[pn.GetID()] = pn.GetPushname()
+		if err := m.messageDB.UpdateChatNameIfUnset(pn.GetID(), pn.GetPushname()); err != nil {
+			m.log.Errorf("Failed to update chat name from push name: %v", err)
+		}
+	}
+
+	var cutoff int64
+	if config.MaxDays > 0 {
+		cutoff = time.Now().AddDate(0, 0, -config.MaxDays).Unix()
+	}
+
+	for _, conv := range data.GetConversations() {
+		stored, skipped, err := m.storeHistoryConversation(conv, config, cutoff, pushNames)
+		if err != nil {
+			m.log.Errorf("Failed to store history conversation %s: %v", conv.GetID(), err)
+			continue
+		}
+		if stored < 0 {
+			continue
+		}
+		progress.Conversations++
+		progress.Messages += stored
+		progress.Skipped += skipped
+	}
+
+	m.emitEvent(ConnectionEvent{
+		Type:    "history_sync",
+		Message: fmt.Sprintf("History sync %d%%", progress.Progress),
+		Payload: progress,
+	})
+}
+
+// storeHistoryConversation stores a single conversation, returning -1 if the whole conversation was skipped
+func (m *Manager) storeHistoryConversation(conv *waHistorySync.Conversation, config *HistorySyncConfig, cutoff int64, pushNames map[string]string) (int, int, error) {
+	chatJID := conv.GetID()
+	if chatJID == "" || strings.HasSuffix(chatJID, "@broadcast") || strings.HasSuffix(chatJID, "@newsletter") {
+		return -1, 0, nil
+	}
+
+	isGroup := strings.HasSuffix(chatJID, "@g.us")
+	if isGroup && !config.IncludeGroups {
+		return -1, 0, nil
+	}
+	if conv.GetArchived() && !config.IncludeArchived {
+		return -1, 0, nil
+	}
+
+	// Keep the newest messages first so limits drop the oldest ones
+	msgs := conv.GetMessages()
+	sort.SliceStable(msgs, func(i, j int) bool {
+		return msgs[i].GetMessage().GetMessageTimestamp() > msgs[j].GetMessage().GetMessageTimestamp()
+	})
+
+	limited := config.MaxMessagesPerChat > 0
+	remaining := 0
+	if limited {
+		existing, err := m.messageDB.CountChatMessages(chatJID)
+		if err != nil {
+			return 0, 0, err
+		}
+		remaining = config.MaxMessagesPerChat - existing
+		if remaining < 0 {
+			remaining = 0
+		}
+	}
+
+	stored, skipped := 0, 0
+	for _, histMsg := range msgs {
+		webMsg := histMsg.GetMessage()
+		if webMsg == nil || webMsg.GetKey() == nil || webMsg.GetMessage() == nil {
+			skipped++
+			continue
+		}
+		if (limited && remaining == 0) || int64(webMsg.GetMessageTimestamp()) < cutoff {
+			skipped++
+			continue
+		}
+
+		// History sync messages may omit the chat and carry the group sender outside the key
+		if webMsg.Key.GetRemoteJID() == "" {
+			webMsg.Key.RemoteJID = &chatJID
+		}
+		if webMsg.Key.GetParticipant() == "" && webMsg.GetParticipant() != "" {
+			participant := webMsg.GetParticipant()
+			webMsg.Key.Participant = &participant
+		}
+
+		if err := m.messageDB.StoreMessage(webMsg); err != nil {
+			m.log.Errorf("Failed to store history message: %v", err)
+			skipped++
+			continue
+		}
+		stored++
+		if limited {
+			remaining--
+		}
+	}
+
+	name := conv.GetName()
+	if name == "" {
+		name = conv.GetDisplayName()
+	}
+	if name == "" {
+		name = pushNames[chatJID]
+	}
+	if name == "" {
+		name = m.getContactName(chatJID)
+	}
+
+	unread := int(conv.GetUnreadCount())
+	if unread == 0 && conv.GetMarkedAsUnread() {
+		unread = 1
+	}
+
+	chat := &StoredChat{
+		JID:             chatJID,
+		Name:            name,
+		IsGroup:         isGroup,
+		LastMessageTime: int64(conv.GetConversationTimestamp()),
+		UnreadCount:     unread,
+		Archived:        conv.GetArchived(),
+		Pinned:          conv.GetPinned() > 0,
+		MutedUntil:      normalizeUnixTime(int64(conv.GetMuteEndTime())),
+	}
+	if err := m.messageDB.ApplyChatState(chat); err != nil {
+		return stored, skipped, err
+	}
+
+	return stored, skipped, nil
+}
+
+// normalizeUnixTime converts millisecond timestamps to seconds
+func normalizeUnixTime(ts int64) int64 {
+	if ts > 1e12 {
+		return ts / 1000
+	}
+	return ts
+}
+
+// GetHistorySyncConfig returns the current history sync configuration
+func (m *Manager) GetHistorySyncConfig() *HistorySyncConfig {
+	if m.historyConfig != nil {
+		return m.historyConfig
+	}
+	if m.messageDB == nil {
+		return GetDefaultHistorySyncConfig()
+	}
+
+	config, err := m.messageDB.LoadHistorySyncConfig()
+	if err != nil {
+		m.log.Errorf("Failed to load history sync config: %v", err)
+		return GetDefaultHistorySyncConfig()
+	}
+
+	m.historyConfig = config
+	return config
+}
+
+// UpdateHistorySyncConfig updates the history sync configuration and saves to database
+func (m *Manager) UpdateHistorySyncConfig(config *HistorySyncConfig) error {
+	if m.messageDB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	if config.MaxDays < 0 || config.MaxMessagesPerChat < 0 {
+		return fmt.Errorf("history limits must not be negative")
+	}
+
+	if err := m.messageDB.SaveHistorySyncConfig(config); err != nil {
+		return err
+	}
+
+	m.historyConfig = config
+	return nil
+}
@@ -0,0 +1,268 @@
+package whatsapp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// Actions an intent routes its messages to
+const (
+	IntentReply  = "reply"  // AI reply, with the intent's profile if set
+	IntentRules  = "rules"  // reply rules only, never the AI
+	IntentHuman  = "human"  // AI draft queued for approval by a human
+	IntentIgnore = "ignore" // no reply at all
+)
+
+// IntentRoute is an intent incoming messages are classified with and where they go
+type IntentRoute struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Examples    []string `json:"examples"` // labelled example messages shown to the AI classifier
+	Keywords    []string `json:"keywords"` // matched when the AI is not used or fails
+	Action      string   `json:"action"`
+	Profile     string   `json:"profile"` // name of the reply profile to answer with, empty keeps the resolved one
+}
+
+// IntentConfig controls the classification of incoming messages and their routing
+type IntentConfig struct {
+	Enabled bool          `json:"enabled"`
+	UseAI   bool          `json:"use_ai"`  // classify private messages with the AI, keywords otherwise
+	Default string        `json:"default"` // intent of messages that match nothing
+	Intents []IntentRoute `json:"intents"`
+}
+
+// GetDefaultIntentConfig returns the default intents, with classification off
+func GetDefaultIntentConfig() IntentConfig {
+	return IntentConfig{
+		Enabled: false,
+		UseAI:   true,
+		Default: "other",
+		Intents: []IntentRoute{
+			{
+				Name:        "sales",
+				Description: "Questions about products, prices, availability or placing an order",
+				Examples:    []string{"How much is the large size?", "Do you deliver to Bandung?"},
+				Keywords:    []string{"price", "buy", "order", "cost", "available", "stock"},
+				Action:      IntentReply,
+			},
+			{
+				Name:        "support",
+				Description: "Help with an existing order, account or product",
+				Examples:    []string{"Where is my package?", "How do I reset my password?"},
+				Keywords:    []string{"help", "how do i", "tracking", "password", "not working"},
+				Action:      IntentReply,
+			},
+			{
+				Name:        "complaint",
+				Description: "Dissatisfaction, refund requests or problems caused by us",
+				Examples:    []string{"My order arrived broken, I want a refund", "This is the third time you got it wrong"},
+				Keywords:    []string{"refund", "complaint", "broken", "damaged", "disappointed", "terrible"},
+				Action:      IntentHuman,
+			},
+			{
+				Name:        "spam",
+				Description: "Unsolicited promotions, chain messages or scams",
+				Examples:    []string{"Earn $500 a day from home, click here", "Forward this to 10 friends"},
+				Keywords:    []string{"click here", "earn money", "free gift", "forward this"},
+				Action:      IntentIgnore,
+			},
+		},
+	}
+}
+
+// Validate checks the intents and normalizes their names
+func (c *IntentConfig) Validate() error {
+	c.Default = strings.ToLower(strings.TrimSpace(c.Default))
+	seen := map[string]bool{}
+	for i := range c.Intents {
+		route := &c.Intents[i]
+		route.Name = strings.ToLower(strings.TrimSpace(route.Name))
+		if route.Name == "" {
+			return fmt.Errorf("intent name is required")
+		}
+		if seen[route.Name] {
+			return fmt.Errorf("duplicate intent: %s", route.Name)
+		}
+		seen[route.Name] = true
+
+		if route.Action == "" {
+			route.Action = IntentReply
+		}
+		switch route.Action {
+		case IntentReply, IntentRules, IntentHuman, IntentIgnore:
+		default:
+			return fmt.Errorf("unsupported action for intent %s: %s", route.Name, route.Action)
+		}
+	}
+	return nil
+}
+
+// route returns the route of the named intent, or nil if it has none
+func (c *IntentConfig) route(name string) *IntentRoute {
+	for i := range c.Intents {
+		if c.Intents[i].Name == name {
+			route := c.Intents[i]
+			return &route
+		}
+	}
+	return nil
+}
+
+// classifiesWithAI reports whether messages of the chat are classified with an AI request.
+// Group messages only use keywords, since most of them are not addressed to us.
+func (c *IntentConfig) classifiesWithAI(isGroup bool) bool {
+	return c.Enabled && c.UseAI && !isGroup && len(c.Intents) > 0
+}
+
+// matchKeywords returns the first intent with a keyword in the text, or ""
+func (c *IntentConfig) matchKeywords(text string) string {
+	text = strings.ToLower(text)
+	for _, route := range c.Intents {
+		for _, keyword := range route.Keywords {
+			if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" && strings.Contains(text, keyword) {
+				return route.Name
+			}
+		}
+	}
+	return ""
+}
+
+// classifyPrompt returns the instructions for classifying a message with the AI
+func (c *IntentConfig) classifyPrompt() string {
+	var b strings.Builder
+	b.WriteString("Classify the intent of a WhatsApp message sent to a business. The intents are:\n")
+	for _, route := range c.Intents {
+		b.WriteString("\n" + route.Name)
+		if route.Description != "" {
+			b.WriteString(": " + route.Description)
+		}
+		for _, example := range route.Examples {
+			b.WriteString(fmt.Sprintf("\n  Example: %q", example))
+		}
+	}
+	if c.Default != "" {
+		b.WriteString("\n\n" + c.Default + ": anything else")
+	}
+	b.WriteString("\n\nReply with the intent name only.")
+	return b.String()
+}
+
+// parseIntent returns the intent named in a model response, or "" if it names none
+func (c *IntentConfig) parseIntent(response string) string {
+	answer := strings.Trim(strings.ToLower(strings.TrimSpace(response)), ".\"'`*")
+	names := make([]string, 0, len(c.Intents)+1)
+	for _, route := range c.Intents {
+		names = append(names, route.Name)
+	}
+	if c.Default != "" {
+		names = append(names, c.Default)
+	}
+
+	for _, name := range names {
+		if answer == name {
+			return name
+		}
+	}
+	for _, name := range names {
+		if strings.Contains(answer, name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// classifyIntent tags an incoming message with its intent. The AI classifier falls back to
+// keywords when it fails or the budget is spent; messages matching nothing get the default
+// intent. Except for dry runs, the intent is stored on the message.
+func (arm *AutoReplyManager) classifyIntent(manager *Manager, evt *events.Message, text string, dryRun bool) string {
+	config := arm.config
+	intents := config.Intents
+
+	intent := ""
+	if intents.classifiesWithAI(evt.Info.IsGroup) && !manager.budgetExceeded(config) {
+		var err error
+		intent, err = arm.classifyWithAI(manager, config, evt.Info.Chat.String(), text)
+		if err != nil {
+			fmt.Printf("Failed to classify message intent, using keywords: %v\n", err)
+		}
+	}
+	if intent == "" {
+		intent = intents.matchKeywords(text)
+	}
+	if intent == "" {
+		intent = intents.Default
+	}
+
+	if !dryRun && intent != "" && manager.messageDB != nil {
+		if err := manager.messageDB.SetMessageIntent(evt.Info.ID, intent); err != nil {
+			fmt.Printf("Failed to store message intent: %v\n", err)
+		}
+	}
+	return intent
+}
+
+// classifyWithAI asks the configured provider for the intent of the text
+func (arm *AutoReplyManager) classifyWithAI(manager *Manager, config *AutoReplyConfig, chatJID, text string) (string, error) {
+	provider, err := NewProvider(config.AIProvider, config, arm.client)
+	if err != nil {
+		return "", err
+	}
+	provider = manager.meterProvider(provider, config, chatJID, "intent")
+
+	temperature := 0.0
+	response, err := provider.Generate(arm.ctx, GenerateRequest{
+		Messages: []ChatMessage{
+			{Role: "system", Content: config.Intents.classifyPrompt()},
+			{Role: "user", Content: text},
+		},
+		MaxTokens:   10,
+		Temperature: &temperature,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	intent := config.Intents.parseIntent(response)
+	if intent == "" {
+		return "", fmt.Errorf("unknown intent in AI response: %q", response)
+	}
+	return intent, nil
+}
+
+// IntentCount is the number of messages classified with an intent
+type IntentCount struct {
+	Intent string `json:"intent"`
+	Count  int    `json:"count"`
+}
+
+// GetIntentStats returns how many incoming messages of the last days were classified with
+// each intent, most frequent first
+func (m *Manager) GetIntentStats(days int) ([]IntentCount, error) {
+	if m.messageDB == nil {
+		return nil, fmt.Errorf("message database not initialized")
+	}
+	if days <= 0 {
+		days = 30
+	}
+
+	counts, err := m.messageDB.GetIntentCounts(time.Now().AddDate(0, 0, -days).Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]IntentCount, 0, len(counts))
+	for intent, count := range counts {
+		stats = append(stats, IntentCount{Intent: intent, Count: count})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Count != stats[j].Count {
+			return stats[i].Count > stats[j].Count
+		}
+		return stats[i].Intent < stats[j].Intent
+	})
+	return stats, nil
+}
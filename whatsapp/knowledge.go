@@ -0,0 +1,501 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// KnowledgeConfig controls the knowledge base used to ground AI replies
+type KnowledgeConfig struct {
+	Enabled           bool    `json:"enabled"`
+	EmbeddingProvider string  `json:"embedding_provider"` // "ollama", "openai" or "openai_compatible"
+	EmbeddingModel    string  `json:"embedding_model"`
+	ChunkSize         int     `json:"chunk_size"`    // characters per chunk
+	ChunkOverlap      int     `json:"chunk_overlap"` // characters repeated from the previous chunk
+	TopK              int     `json:"top_k"`         // chunks added to each prompt
+	MinScore          float64 `json:"min_score"`     // similarity below which chunks are ignored
+}
+
+// GetDefaultKnowledgeConfig returns the default knowledge base settings
+func GetDefaultKnowledgeConfig() KnowledgeConfig {
+	return KnowledgeConfig{
+		Enabled:           false,
+		EmbeddingProvider: "ollama",
+		EmbeddingModel:    "nomic-embed-text",
+		ChunkSize:         800,
+		ChunkOverlap:      100,
+		TopK:              4,
+		MinScore:          0.3,
+	}
+}
+
+// Validate checks the knowledge base settings
+func (c *KnowledgeConfig) Validate() error {
+	if c.ChunkSize < 100 {
+		return fmt.Errorf("chunk size must be at least 100 characters")
+	}
+	if c.ChunkOverlap < 0 || c.ChunkOverlap >= c.ChunkSize {
+		return fmt.Errorf("chunk overlap must be between 0 and the chunk size")
+	}
+	if c.TopK < 1 {
+		return fmt.Errorf("at least one chunk must be retrieved")
+	}
+	if c.MinScore < -1 || c.MinScore > 1 {
+		return fmt.Errorf("minimum score must be between -1 and 1")
+	}
+	if c.Enabled && c.EmbeddingModel == "" {
+		return fmt.Errorf("embedding model is required")
+	}
+	return nil
+}
+
+// KnowledgeDocument is an imported file of the knowledge base
+type KnowledgeDocument struct {
+	ID             int64  `json:"id"`
+	Name           string `json:"name"`
+	FileType       string `json:"file_type"`
+	Size           int64  `json:"size"`
+	ChunkCount     int    `json:"chunk_count"`
+	EmbeddingModel string `json:"embedding_model"`
+	CreatedAt      int64  `json:"created_at"`
+}
+
+// KnowledgeChunk is a piece of a document with its embedding
+type KnowledgeChunk struct {
+	ID           int64
+	DocumentID   int64
+	DocumentName string
+	Index        int
+	Content      string
+	Embedding    []float32
+}
+
+// KnowledgeSource identifies a chunk used to answer a message
+type KnowledgeSource struct {
+	DocumentID int64   `json:"document_id"`
+	Document   string  `json:"document"`
+	Chunk      int     `json:"chunk"`
+	Score      float64 `json:"score"`
+}
+
+// KnowledgeMatch is a retrieved chunk
+type KnowledgeMatch struct {
+	KnowledgeSource
+	Content string `json:"content"`
+}
+
+// knowledgePrompt introduces the retrieved chunks to the model
+const knowledgePrompt = "Answer using the knowledge base excerpts below. Prices, policies and other facts must come from them; " +
+	"if they don't contain the answer, say you don't know instead of guessing."
+
+// KnowledgeBase imports documents and retrieves the chunks relevant to a message
+type KnowledgeBase struct {
+	db     *MessageDB
+	client *http.Client
+	meter  func(embedder Embedder, config *AutoReplyConfig, chatJID string) Embedder // logs and budgets embedding calls, may be nil
+
+	mu     sync.Mutex
+	chunks map[string][]KnowledgeChunk // embedding model -> chunks, loaded on first search
+}
+
+// NewKnowledgeBase creates a knowledge base on the message database
+func NewKnowledgeBase(db *MessageDB, client *http.Client) *KnowledgeBase {
+	return &KnowledgeBase{
+		db:     db,
+		client: client,
+		chunks: make(map[string][]KnowledgeChunk),
+	}
+}
+
+// Import reads, chunks and embeds a file and stores it in the knowledge base
+func (kb *KnowledgeBase) Import(ctx context.Context, config *AutoReplyConfig, path string) (*KnowledgeDocument, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %v", err)
+	}
+
+	fileType := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	text, err := readKnowledgeFile(path, fileType)
+	if err != nil {
+		return nil, err
+	}
+
+	contents := chunkText(text, config.Knowledge.ChunkSize, config.Knowledge.ChunkOverlap)
+	if len(contents) == 0 {
+		return nil, fmt.Errorf("%s contains no text", filepath.Base(path))
+	}
+
+	embedder, err := kb.embedder(config, "")
+	if err != nil {
+		return nil, err
+	}
+	vectors, err := embedder.Embed(ctx, contents, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed %s: %v", filepath.Base(path), err)
+	}
+
+	doc := &KnowledgeDocument{
+		Name:           filepath.Base(path),
+		FileType:       fileType,
+		Size:           info.Size(),
+		ChunkCount:     len(contents),
+		EmbeddingModel: embedder.Model(),
+		CreatedAt:      time.Now().Unix(),
+	}
+	chunks := make([]KnowledgeChunk, len(contents))
+	for i, content := range contents {
+		chunks[i] = KnowledgeChunk{Index: i, Content: content, Embedding: vectors[i]}
+	}
+
+	if err := kb.db.SaveKnowledgeDocument(doc, chunks); err != nil {
+		return nil, err
+	}
+	kb.invalidate()
+	return doc, nil
+}
+
+// embedder creates the configured embedder, metered when the knowledge base has a meter
+func (kb *KnowledgeBase) embedder(config *AutoReplyConfig, chatJID string) (Embedder, error) {
+	embedder, err := NewEmbedder(config, kb.client)
+	if err != nil {
+		return nil, err
+	}
+	if kb.meter != nil {
+		embedder = kb.meter(embedder, config, chatJID)
+	}
+	return embedder, nil
+}
+
+// Delete removes a document and its chunks
+func (kb *KnowledgeBase) Delete(id int64) error {
+	if err := kb.db.DeleteKnowledgeDocument(id); err != nil {
+		return err
+	}
+	kb.invalidate()
+	return nil
+}
+
+// Search returns the chunks most similar to the query of the chat, best first
+func (kb *KnowledgeBase) Search(ctx context.Context, config *AutoReplyConfig, chatJID, query string) ([]KnowledgeMatch, error) {
+	embedder, err := kb.embedder(config, chatJID)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks, err := kb.load(embedder.Model())
+	if err != nil || len(chunks) == 0 {
+		return nil, err
+	}
+
+	vectors, err := embedder.Embed(ctx, []string{query}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed message: %v", err)
+	}
+
+	matches := make([]KnowledgeMatch, 0, len(chunks))
+	for _, chunk := range chunks {
+		score := cosineSimilarity(vectors[0], chunk.Embedding)
+		if score < config.Knowledge.MinScore {
+			continue
+		}
+		matches = append(matches, KnowledgeMatch{
+			KnowledgeSource: KnowledgeSource{
+				DocumentID: chunk.DocumentID,
+				Document:   chunk.DocumentName,
+				Chunk:      chunk.Index,
+				Score:      score,
+			},
+			Content: chunk.Content,
+		})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if len(matches) > config.Knowledge.TopK {
+		matches = matches[:config.Knowledge.TopK]
+	}
+	return matches, nil
+}
+
+// load returns the chunks embedded with the model, reading them from the database once
+func (kb *KnowledgeBase) load(model string) ([]KnowledgeChunk, error) {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+
+	if chunks, ok := kb.chunks[model]; ok {
+		return chunks, nil
+	}
+	chunks, err := kb.db.GetKnowledgeChunks(model)
+	if err != nil {
+		return nil, err
+	}
+	kb.chunks[model] = chunks
+	return chunks, nil
+}
+
+// invalidate drops the cached chunks after the documents changed
+func (kb *KnowledgeBase) invalidate() {
+	kb.mu.Lock()
+	kb.chunks = make(map[string][]KnowledgeChunk)
+	kb.mu.Unlock()
+}
+
+// readKnowledgeFile returns the plain text of a supported file
+func readKnowledgeFile(path, fileType string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %v", err)
+	}
+
+	switch fileType {
+	case "md", "markdown", "txt":
+		if !utf8.Valid(data) {
+			return "", fmt.Errorf("%s is not UTF-8 text", filepath.Base(path))
+		}
+		return string(data), nil
+	case "csv":
+		return csvToText(data)
+	case "pdf":
+		return extractPDFText(data)
+	default:
+		return "", fmt.Errorf("unsupported file type: %s (use .md, .txt, .pdf or .csv)", fileType)
+	}
+}
+
+// csvToText turns each row into a "column: value" line so rows stay readable in a chunk
+func csvToText(data []byte) (string, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return "", fmt.Errorf("failed to read CSV header: %v", err)
+	}
+
+	var lines []string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read CSV: %v", err)
+		}
+
+		fields := make([]string, 0, len(record))
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			if value == "" {
+				continue
+			}
+			if i < len(header) && strings.TrimSpace(header[i]) != "" {
+				value = strings.TrimSpace(header[i]) + ": " + value
+			}
+			fields = append(fields, value)
+		}
+		if len(fields) > 0 {
+			lines = append(lines, strings.Join(fields, "; "))
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// chunkText splits text into chunks of about size characters along paragraph and
+// sentence boundaries, starting each chunk with the tail of the previous one
+func chunkText(text string, size, overlap int) []string {
+	parts := splitMessage(text, size)
+	if overlap <= 0 || len(parts) < 2 {
+		return parts
+	}
+
+	chunks := make([]string, len(parts))
+	chunks[0] = parts[0]
+	for i := 1; i < len(parts); i++ {
+		previous := []rune(parts[i-1])
+		if len(previous) <= overlap {
+			chunks[i] = parts[i-1] + "\n" + parts[i]
+			continue
+		}
+		tail := string(previous[len(previous)-overlap:])
+		// Start the overlap at a word
+		if space := strings.IndexAny(tail, " \n"); space >= 0 {
+			tail = tail[space+1:]
+		}
+		chunks[i] = strings.TrimSpace(tail + " " + parts[i])
+	}
+	return chunks
+}
+
+// cosineSimilarity compares two embeddings, returning 0 for vectors of different size
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// encodeVector stores an embedding as little-endian float32 values
+func encodeVector(vector []float32) []byte {
+	buf := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(v))
+	}
+	return buf
+}
+
+// decodeVector reads an embedding written by encodeVector
+func decodeVector(buf []byte) []float32 {
+	vector := make([]float32, len(buf)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return vector
+}
+
+// knowledgeMessage builds the system message carrying the retrieved chunks
+func knowledgeMessage(matches []KnowledgeMatch) ChatMessage {
+	var b strings.Builder
+	b.WriteString(knowledgePrompt)
+	for i, match := range matches {
+		fmt.Fprintf(&b, "\n\n[%d] From %s:\n%s", i+1, match.Document, match.Content)
+	}
+	return ChatMessage{Role: "system", Content: b.String()}
+}
+
+// withKnowledge adds the knowledge base chunks relevant to the question to the
+// conversation, right after the system prompt. It returns the sources used.
+func (arm *AutoReplyManager) withKnowledge(manager *Manager, job *replyJob) ([]ChatMessage, []KnowledgeSource) {
+	if !job.config.Knowledge.Enabled || manager.knowledge == nil {
+		return job.conversation, nil
+	}
+
+	matches, err := manager.knowledge.Search(arm.ctx, job.config, job.chatJID, job.question)
+	if err != nil {
+		fmt.Printf("Knowledge base search failed: %v\n", err)
+		return job.conversation, nil
+	}
+	if len(matches) == 0 {
+		return job.conversation, nil
+	}
+
+	insertAt := 0
+	if len(job.conversation) > 0 && job.conversation[0].Role == "system" {
+		insertAt = 1
+	}
+	conversation := make([]ChatMessage, 0, len(job.conversation)+1)
+	conversation = append(conversation, job.conversation[:insertAt]...)
+	conversation = append(conversation, knowledgeMessage(matches))
+	conversation = append(conversation, job.conversation[insertAt:]...)
+
+	sources := make([]KnowledgeSource, len(matches))
+	for i, match := range matches {
+		sources[i] = match.KnowledgeSource
+	}
+	return conversation, sources
+}
+
+// ReplyLogEntry records an AI reply and the knowledge base chunks it was based on
+type ReplyLogEntry struct {
+	ID        int64             `json:"id"`
+	ChatJID   string            `json:"chat_jid"`
+	MessageID string            `json:"message_id"` // incoming message that was answered
+	Question  string            `json:"question"`
+	Response  string            `json:"response"`
+	Sources   []KnowledgeSource `json:"sources"`
+	CreatedAt int64             `json:"created_at"`
+}
+
+// logReply stores the reply in the reply log
+func (m *Manager) logReply(job *replyJob, response string, sources []KnowledgeSource) {
+	if m.messageDB == nil {
+		return
+	}
+
+	if len(sources) > 0 {
+		names := make([]string, 0, len(sources))
+		for _, source := range sources {
+			if !containsString(names, source.Document) {
+				names = append(names, source.Document)
+			}
+		}
+		fmt.Printf("Reply to %s used knowledge from: %s\n", job.chatJID, strings.Join(names, ", "))
+	}
+
+	entry := &ReplyLogEntry{
+		ChatJID:   job.chatJID,
+		MessageID: job.messageID,
+		Question:  job.question,
+		Response:  response,
+		Sources:   sources,
+		CreatedAt: time.Now().Unix(),
+	}
+	if err := m.messageDB.AddReplyLogEntry(entry); err != nil {
+		m.log.Errorf("Failed to write reply log: %v", err)
+	}
+}
+
+// Knowledge base methods
+
+// ImportKnowledgeFile adds a Markdown, text, PDF or CSV file to the knowledge base
+func (m *Manager) ImportKnowledgeFile(path string) (*KnowledgeDocument, error) {
+	if m.knowledge == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	return m.knowledge.Import(context.Background(), m.autoReply.GetConfig(), path)
+}
+
+// GetKnowledgeDocuments returns the documents of the knowledge base
+func (m *Manager) GetKnowledgeDocuments() ([]KnowledgeDocument, error) {
+	if m.messageDB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	return m.messageDB.GetKnowledgeDocuments()
+}
+
+// DeleteKnowledgeDocument removes a document from the knowledge base
+func (m *Manager) DeleteKnowledgeDocument(id int64) error {
+	if m.knowledge == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	return m.knowledge.Delete(id)
+}
+
+// SearchKnowledge returns the chunks that would be added to a reply to the query
+func (m *Manager) SearchKnowledge(query string) ([]KnowledgeMatch, error) {
+	if m.knowledge == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	return m.knowledge.Search(context.Background(), m.autoReply.GetConfig(), "", query)
+}
+
+// GetReplyLog returns the latest AI replies, of one chat or of all chats when chatJID is empty
+func (m *Manager) GetReplyLog(chatJID string, limit int) ([]ReplyLogEntry, error) {
+	if m.messageDB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+	return m.messageDB.GetReplyLog(chatJID, limit)
+}
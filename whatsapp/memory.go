@@ -0,0 +1,374 @@
+package whatsapp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// MemoryConfig controls the long-term memory kept per chat: a rolling summary and key
+// facts about the contact, added to the prompt of AI replies
+type MemoryConfig struct {
+	Enabled        bool `json:"enabled"`
+	RefreshMinutes int  `json:"refresh_minutes"`  // how often chats are checked for new messages
+	MinNewMessages int  `json:"min_new_messages"` // new messages needed before the memory is updated
+	MaxMessages    int  `json:"max_messages"`     // new messages read per update
+	MaxFacts       int  `json:"max_facts"`
+}
+
+// GetDefaultMemoryConfig returns the default chat memory settings
+func GetDefaultMemoryConfig() MemoryConfig {
+	return MemoryConfig{
+		Enabled:        false,
+		RefreshMinutes: 30,
+		MinNewMessages: 10,
+		MaxMessages:    50,
+		MaxFacts:       15,
+	}
+}
+
+// Validate checks the chat memory settings
+func (c *MemoryConfig) Validate() error {
+	if c.RefreshMinutes < 1 {
+		return fmt.Errorf("refresh interval must be at least 1 minute")
+	}
+	if c.MinNewMessages < 1 || c.MaxMessages < 1 || c.MaxFacts < 1 {
+		return fmt.Errorf("message and fact limits must be at least 1")
+	}
+	return nil
+}
+
+const (
+	// memoryActiveDays limits the background job to chats with recent messages, so a
+	// history sync doesn't summarize every old conversation
+	memoryActiveDays = 7
+	// memoryChatsPerRun bounds the AI calls of one background run
+	memoryChatsPerRun = 20
+)
+
+// ChatMemory is what the assistant remembers about a chat
+type ChatMemory struct {
+	ChatJID       string   `json:"chat_jid"`
+	Summary       string   `json:"summary"`
+	Facts         []string `json:"facts"`
+	LastMessageAt int64    `json:"last_message_at"` // newest message covered by the summary
+	EditedAt      int64    `json:"edited_at,omitempty"`
+	UpdatedAt     int64    `json:"updated_at"`
+}
+
+// empty reports whether there is nothing to remember
+func (c *ChatMemory) empty() bool {
+	return c == nil || (strings.TrimSpace(c.Summary) == "" && len(c.Facts) == 0)
+}
+
+// prompt returns the memory as instructions for the system prompt
+func (c *ChatMemory) prompt() string {
+	var b strings.Builder
+	b.WriteString("What you remember about this contact from earlier conversations:")
+	if summary := strings.TrimSpace(c.Summary); summary != "" {
+		b.WriteString("\n" + summary)
+	}
+	if len(c.Facts) > 0 {
+		b.WriteString("\nKey facts:")
+		for _, fact := range c.Facts {
+			b.WriteString("\n- " + fact)
+		}
+	}
+	return b.String()
+}
+
+// withMemory adds the memory of the job's chat to the system prompt. Group chats have
+// no memory since it is kept per contact.
+func (m *Manager) withMemory(job *replyJob, conversation []ChatMessage) []ChatMessage {
+	if !job.config.Memory.Enabled || m.messageDB == nil || strings.HasSuffix(job.chatJID, "@g.us") {
+		return conversation
+	}
+	if len(conversation) == 0 || conversation[0].Role != "system" {
+		return conversation
+	}
+
+	memory, err := m.messageDB.GetChatMemory(job.chatJID)
+	if err != nil {
+		fmt.Printf("Failed to load chat memory: %v\n", err)
+		return conversation
+	}
+	if memory.empty() {
+		return conversation
+	}
+
+	messages := append([]ChatMessage(nil), conversation...)
+	messages[0].Content = strings.TrimSpace(messages[0].Content + "\n\n" + memory.prompt())
+	return messages
+}
+
+// startMemoryRefresh runs the background job updating chat memories until shutdown.
+// Each run is tracked so Stop waits for it like for pending replies.
+func (arm *AutoReplyManager) startMemoryRefresh(manager *Manager) {
+	go func() {
+		for {
+			interval := time.Duration(arm.GetConfig().Memory.RefreshMinutes) * time.Minute
+			if interval <= 0 {
+				interval = time.Duration(GetDefaultMemoryConfig().RefreshMinutes) * time.Minute
+			}
+			if !arm.sleep(interval) {
+				return
+			}
+			config := arm.GetConfig()
+			if !config.Enabled || !config.Memory.Enabled {
+				continue
+			}
+
+			if !arm.track() {
+				return
+			}
+			arm.refreshMemories(manager)
+			arm.wg.Done()
+		}
+	}()
+}
+
+// refreshMemories updates the memory of the chats with enough new messages
+func (arm *AutoReplyManager) refreshMemories(manager *Manager) {
+	config := arm.GetConfig()
+	if manager.messageDB == nil || manager.budgetExceeded(config) {
+		return
+	}
+
+	since := time.Now().AddDate(0, 0, -memoryActiveDays).Unix()
+	chats, err := manager.messageDB.GetMemoryCandidates(since, config.Memory.MinNewMessages)
+	if err != nil {
+		fmt.Printf("Failed to find chats to summarize: %v\n", err)
+		return
+	}
+
+	updated := 0
+	for _, chatJID := range chats {
+		if arm.stopping.Load() || updated == memoryChatsPerRun {
+			return
+		}
+		if !arm.answers(manager, chatJID) {
+			continue
+		}
+		if _, err := arm.updateMemory(manager, config, chatJID); err != nil {
+			fmt.Printf("Failed to update memory of %s: %v\n", chatJID, err)
+		}
+		updated++
+	}
+}
+
+// answers reports whether auto-reply answers the contact of a private chat, so chats of
+// contacts outside the whitelist or a profile's access lists are never summarized
+func (arm *AutoReplyManager) answers(manager *Manager, chatJID string) bool {
+	jid, err := types.ParseJID(chatJID)
+	if err != nil {
+		return false
+	}
+	profile := arm.profiles.Resolve(chatJID, jid.User, false, manager.contactTags(jid))
+	return arm.allowsSender(profile, jid.User, false)
+}
+
+// updateMemory has the AI fold the chat's new messages into its memory and stores the result
+func (arm *AutoReplyManager) updateMemory(manager *Manager, config *AutoReplyConfig, chatJID string) (*ChatMemory, error) {
+	memory, err := manager.messageDB.GetChatMemory(chatJID)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := manager.messageDB.GetChatMessages(chatJID, config.Memory.MaxMessages, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load messages: %v", err)
+	}
+
+	var transcript strings.Builder
+	newest := memory.LastMessageAt
+	for i := len(stored) - 1; i >= 0; i-- {
+		msg := stored[i]
+		if msg.Timestamp <= memory.LastMessageAt {
+			continue
+		}
+		content := strings.TrimSpace(msg.Content)
+		if msg.Transcript != "" {
+			content = msg.Transcript
+		}
+		if msg.Caption != "" {
+			content = strings.TrimSpace(content + " " + msg.Caption)
+		}
+		if content == "" {
+			continue
+		}
+
+		speaker := "Contact"
+		if msg.IsFromMe {
+			speaker = "Assistant"
+		}
+		transcript.WriteString(speaker + ": " + content + "\n")
+		if msg.Timestamp > newest {
+			newest = msg.Timestamp
+		}
+	}
+	if transcript.Len() == 0 {
+		return memory, nil
+	}
+
+	provider, err := NewProvider(config.AIProvider, config, arm.client)
+	if err != nil {
+		return nil, err
+	}
+	provider = manager.meterProvider(provider, config, chatJID, "memory")
+
+	response, err := provider.Generate(arm.ctx, GenerateRequest{
+		Messages: []ChatMessage{
+			{Role: "system", Content: memoryPrompt(config.Memory.MaxFacts)},
+			{Role: "user", Content: memoryInput(memory, transcript.String())},
+		},
+		MaxTokens: 800,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := parseMemory(response)
+	if err != nil {
+		return nil, err
+	}
+	if len(updated.Facts) > config.Memory.MaxFacts {
+		updated.Facts = updated.Facts[:config.Memory.MaxFacts]
+	}
+
+	// The operator may have edited the memory while the model was busy; their version wins
+	current, err := manager.messageDB.GetChatMemory(chatJID)
+	if err != nil {
+		return nil, err
+	}
+	if current.EditedAt != memory.EditedAt {
+		return current, nil
+	}
+
+	memory.Summary = updated.Summary
+	memory.Facts = updated.Facts
+	memory.LastMessageAt = newest
+	memory.UpdatedAt = time.Now().Unix()
+	if err := manager.messageDB.SaveChatMemory(memory); err != nil {
+		return nil, err
+	}
+	return memory, nil
+}
+
+// memoryPrompt returns the instructions for updating a chat memory
+func memoryPrompt(maxFacts int) string {
+	return fmt.Sprintf(`You maintain the long-term memory of a WhatsApp assistant about one contact.
+Update the summary and the key facts with the new messages. Keep what is still true, drop what
+is outdated, and keep facts useful for future replies: name, orders, preferences, open issues.
+The summary has at most 5 sentences, and there are at most %d facts.
+Reply with JSON only: {"summary": "...", "facts": ["...", "..."]}`, maxFacts)
+}
+
+// memoryInput returns the current memory and the new messages to fold into it
+func memoryInput(memory *ChatMemory, transcript string) string {
+	var b strings.Builder
+	b.WriteString("Current summary: ")
+	if memory.Summary == "" {
+		b.WriteString("(none)")
+	} else {
+		b.WriteString(memory.Summary)
+	}
+	b.WriteString("\nCurrent facts:")
+	if len(memory.Facts) == 0 {
+		b.WriteString(" (none)")
+	}
+	for _, fact := range memory.Facts {
+		b.WriteString("\n- " + fact)
+	}
+	b.WriteString("\n\nNew messages:\n" + transcript)
+	return b.String()
+}
+
+// parseMemory reads the JSON memory from a model response, which may wrap it in text or a code block
+func parseMemory(response string) (*ChatMemory, error) {
+	start := strings.Index(response, "{")
+	end := strings.LastIndex(response, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no memory JSON in the AI response")
+	}
+
+	var memory ChatMemory
+	if err := json.Unmarshal([]byte(response[start:end+1]), &memory); err != nil {
+		return nil, fmt.Errorf("failed to parse memory: %v", err)
+	}
+	memory.Summary = strings.TrimSpace(memory.Summary)
+	memory.Facts = cleanFacts(memory.Facts)
+	return &memory, nil
+}
+
+// cleanFacts trims the facts and drops empty ones and duplicates
+func cleanFacts(facts []string) []string {
+	cleaned := []string{}
+	for _, fact := range facts {
+		fact = strings.TrimSpace(fact)
+		if fact != "" && !containsString(cleaned, fact) {
+			cleaned = append(cleaned, fact)
+		}
+	}
+	return cleaned
+}
+
+// GetChatMemory returns what the assistant remembers about a chat
+func (m *Manager) GetChatMemory(chatJID string) (*ChatMemory, error) {
+	if m.messageDB == nil {
+		return nil, fmt.Errorf("message database not initialized")
+	}
+	return m.messageDB.GetChatMemory(chatJID)
+}
+
+// UpdateChatMemory replaces the summary and facts of a chat with the operator's version.
+// Later updates start from it.
+func (m *Manager) UpdateChatMemory(chatJID, summary string, facts []string) (*ChatMemory, error) {
+	if m.messageDB == nil {
+		return nil, fmt.Errorf("message database not initialized")
+	}
+
+	memory, err := m.messageDB.GetChatMemory(chatJID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	memory.Summary = strings.TrimSpace(summary)
+	memory.Facts = cleanFacts(facts)
+	memory.EditedAt = now
+	memory.UpdatedAt = now
+	if err := m.messageDB.SaveChatMemory(memory); err != nil {
+		return nil, err
+	}
+	return memory, nil
+}
+
+// RefreshChatMemory updates the memory of a chat with its new messages right away
+func (m *Manager) RefreshChatMemory(chatJID string) (*ChatMemory, error) {
+	if m.messageDB == nil {
+		return nil, fmt.Errorf("message database not initialized")
+	}
+	if m.autoReply == nil {
+		return nil, fmt.Errorf("auto-reply not configured")
+	}
+
+	config := m.autoReply.GetConfig()
+	if m.budgetExceeded(config) {
+		return nil, fmt.Errorf("AI budget reached")
+	}
+	memory, err := m.autoReply.updateMemory(m, config, chatJID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update memory: %v", err)
+	}
+	return memory, nil
+}
+
+// DeleteChatMemory makes the assistant forget a chat
+func (m *Manager) DeleteChatMemory(chatJID string) error {
+	if m.messageDB == nil {
+		return fmt.Errorf("message database not initialized")
+	}
+	return m.messageDB.DeleteChatMemory(chatJID)
+}
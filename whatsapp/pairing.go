@@ -0,0 +1,192 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+const (
+	// pairingReadyTimeout is how long we wait for the login websocket before requesting a code
+	pairingReadyTimeout = 20 * time.Second
+	// pairingCodeTimeout matches the lifetime of the login websocket (QR codes run out after ~160s)
+	pairingCodeTimeout = 160 * time.Second
+)
+
+var nonDigits = regexp.MustCompile(`\D`)
+
+// pairingSession tracks a phone number pairing attempt on a fresh device
+type pairingSession struct {
+	client    *whatsmeow.Client
+	device    *store.Device
+	ready     chan struct{}
+	done      chan struct{}
+	readyOnce sync.Once
+	doneOnce  sync.Once
+}
+
+func (p *pairingSession) markReady() {
+	p.readyOnce.Do(func() { close(p.ready) })
+}
+
+// finish marks the session as finished, returning false if it already was
+func (p *pairingSession) finish() bool {
+	finished := false
+	p.doneOnce.Do(func() {
+		close(p.done)
+		finished = true
+	})
+	return finished
+}
+
+// RequestPairingCode links a new device using a phone number instead of a QR code.
+// A fresh device is created and connected, and the pairing code is returned once the
+// login websocket is ready. The result of the pairing is reported through the
+// "pair_success" and "pair_error" events.
+func (m *Manager) RequestPairingCode(phoneNumber string) (string, error) {
+	phone := nonDigits.ReplaceAllString(phoneNumber, "")
+	if len(phone) <= 6 {
+		return "", fmt.Errorf("phone number is too short")
+	}
+	if strings.HasPrefix(phone, "0") {
+		return "", fmt.Errorf("phone number must include the country code")
+	}
+
+	// Abort any previous attempt and drop the current (not logged in) connection
+	m.CancelPairing()
+	if m.client != nil {
+		if m.client.IsLoggedIn() {
+			return "", fmt.Errorf("a device is already logged in, log out first")
+		}
+		m.client.Disconnect()
+	}
+
+	device := m.container.NewDevice()
+	session := &pairingSession{
+		client: whatsmeow.NewClient(device, m.log),
+		device: device,
+		ready:  make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	m.pairingMux.Lock()
+	m.pairing = session
+	m.pairingMux.Unlock()
+
+	m.client = session.client
+	m.addEventHandlers()
+	session.client.AddEventHandler(func(evt interface{}) {
+		switch v := evt.(type) {
+		case *events.QR:
+			session.markReady()
+		case *events.PairSuccess:
+			if session.finish() {
+				m.clearPairing(session)
+				m.emitEvent(ConnectionEvent{
+					Type:    "pair_success",
+					Message: "Device paired successfully",
+					Data:    v.ID.String(),
+				})
+			}
+		case *events.PairError:
+			m.failPairing(session, fmt.Sprintf("pairing failed: %v", v.Error))
+		}
+	})
+
+	if err := session.client.Connect(); err != nil {
+		m.failPairing(session, fmt.Sprintf("failed to connect: %v", err))
+		return "", fmt.Errorf("failed to connect: %v", err)
+	}
+
+	// Wait for the login websocket to be fully established
+	select {
+	case <-session.ready:
+	case <-session.done:
+		return "", fmt.Errorf("pairing was cancelled")
+	case <-time.After(pairingReadyTimeout):
+		m.failPairing(session, "timed out waiting for WhatsApp connection")
+		return "", fmt.Errorf("timed out waiting for WhatsApp connection")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	code, err := session.client.PairPhone(ctx, phone, true, whatsmeow.PairClientChrome, "Chrome (Linux)")
+	if err != nil {
+		m.failPairing(session, fmt.Sprintf("failed to request pairing code: %v", err))
+		return "", fmt.Errorf("failed to request pairing code: %v", err)
+	}
+
+	m.emitEvent(ConnectionEvent{
+		Type:    "code",
+		Message: "Pairing code generated",
+		Data:    code,
+	})
+
+	// Expire the attempt if the code is not entered on the phone in time
+	go func() {
+		select {
+		case <-session.done:
+		case <-time.After(pairingCodeTimeout):
+			m.failPairing(session, "pairing code expired")
+		}
+	}()
+
+	return code, nil
+}
+
+// CancelPairing aborts a pending phone number pairing attempt
+func (m *Manager) CancelPairing() {
+	m.pairingMux.Lock()
+	session := m.pairing
+	m.pairingMux.Unlock()
+
+	if session != nil {
+		m.failPairing(session, "pairing cancelled")
+	}
+}
+
+// IsPairing reports whether a phone number pairing attempt is in progress
+func (m *Manager) IsPairing() bool {
+	m.pairingMux.Lock()
+	defer m.pairingMux.Unlock()
+	return m.pairing != nil
+}
+
+// failPairing disconnects a pairing attempt and removes the orphan device
+func (m *Manager) failPairing(session *pairingSession, reason string) {
+	if !session.finish() {
+		return
+	}
+	m.clearPairing(session)
+
+	session.client.Disconnect()
+
+	// The device is only persisted once the phone accepted the code
+	if session.device.ID != nil {
+		if err := session.device.Delete(context.Background()); err != nil {
+			m.log.Errorf("Failed to delete orphan device: %v", err)
+		}
+	}
+
+	m.emitEvent(ConnectionEvent{
+		Type:    "pair_error",
+		Message: reason,
+	})
+}
+
+// clearPairing forgets the session if it is still the active one
+func (m *Manager) clearPairing(session *pairingSession) {
+	m.pairingMux.Lock()
+	defer m.pairingMux.Unlock()
+	if m.pairing == session {
+		m.pairing = nil
+	}
+}
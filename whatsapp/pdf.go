@@ -0,0 +1,241 @@
+package whatsapp
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var pdfStreamRe = regexp.MustCompile(`(?s)<<(.*?)>>\s*stream\r?\n`)
+
+// extractPDFText returns the text of a PDF's content streams. It understands uncompressed
+// and FlateDecode streams with simple font encodings, which covers most exported documents;
+// scanned pages and CID-keyed fonts yield no text.
+func extractPDFText(data []byte) (string, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF")) {
+		return "", fmt.Errorf("not a PDF file")
+	}
+
+	var text strings.Builder
+	for _, loc := range pdfStreamRe.FindAllSubmatchIndex(data, -1) {
+		dict := string(data[loc[2]:loc[3]])
+		start := loc[1]
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		raw := data[start : start+end]
+
+		// Images, fonts and other binary streams carry no page text
+		if strings.Contains(dict, "/Subtype") || strings.Contains(dict, "/Length1") {
+			continue
+		}
+
+		content := raw
+		if strings.Contains(dict, "/FlateDecode") {
+			reader, err := zlib.NewReader(bytes.NewReader(raw))
+			if err != nil {
+				continue
+			}
+			// Streams are often padded after the compressed data, keep what inflated
+			content, _ = io.ReadAll(reader)
+			reader.Close()
+		} else if strings.Contains(dict, "/Filter") {
+			continue
+		}
+
+		if page := pdfContentText(content); page != "" {
+			text.WriteString(page)
+			text.WriteString("\n\n")
+		}
+	}
+
+	result := strings.TrimSpace(text.String())
+	if result == "" {
+		return "", fmt.Errorf("no extractable text in PDF (scanned pages and embedded font encodings are not supported)")
+	}
+	return result, nil
+}
+
+// pdfContentText collects the strings shown by the text operators of a content stream
+func pdfContentText(content []byte) string {
+	var out strings.Builder
+	var operands []string // strings since the last operator
+	var lastNumber float64
+	var prevNumber float64
+
+	newline := func() {
+		if out.Len() > 0 && !strings.HasSuffix(out.String(), "\n") {
+			out.WriteString("\n")
+		}
+	}
+
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case c == '%':
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+		case c == '(':
+			s, next := pdfLiteralString(content, i)
+			operands = append(operands, s)
+			i = next
+		case c == '<' && i+1 < len(content) && content[i+1] != '<':
+			end := bytes.IndexByte(content[i:], '>')
+			if end < 0 {
+				return out.String()
+			}
+			operands = append(operands, pdfHexString(content[i+1:i+end]))
+			i += end + 1
+		case c == '<':
+			// Start of a dictionary
+			i += 2
+		case c == '[' || c == ']' || c == '>' || c == '{' || c == '}' || c == '/':
+			i++
+			if c == '/' {
+				for i < len(content) && !pdfDelimiter(content[i]) {
+					i++
+				}
+			}
+		case pdfDelimiter(c):
+			i++
+		default:
+			start := i
+			for i < len(content) && !pdfDelimiter(content[i]) {
+				i++
+			}
+			token := string(content[start:i])
+			if n, err := strconv.ParseFloat(token, 64); err == nil {
+				// Large negative kerning inside TJ arrays stands for a word gap
+				if n < -200 && len(operands) > 0 {
+					operands[len(operands)-1] += " "
+				}
+				prevNumber, lastNumber = lastNumber, n
+				continue
+			}
+
+			switch token {
+			case "Tj", "TJ":
+				out.WriteString(strings.Join(operands, ""))
+			case "'", "\"":
+				newline()
+				out.WriteString(strings.Join(operands, ""))
+			case "T*", "ET":
+				newline()
+			case "Td", "TD":
+				if lastNumber != 0 {
+					newline()
+				} else if prevNumber != 0 && out.Len() > 0 {
+					out.WriteString(" ")
+				}
+			}
+			operands = operands[:0]
+		}
+	}
+
+	return strings.TrimSpace(out.String())
+}
+
+// pdfDelimiter reports whether c ends a PDF token
+func pdfDelimiter(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0 ||
+		c == '(' || c == ')' || c == '<' || c == '>' || c == '[' || c == ']' ||
+		c == '{' || c == '}' || c == '/' || c == '%'
+}
+
+// pdfLiteralString decodes the (...) string starting at i and returns the index after it
+func pdfLiteralString(content []byte, i int) (string, int) {
+	var out []byte
+	depth := 0
+	for i++; i < len(content); i++ {
+		c := content[i]
+		switch c {
+		case '\\':
+			i++
+			if i >= len(content) {
+				break
+			}
+			switch e := content[i]; e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b', 'f':
+			case '\r', '\n':
+				// Line continuation
+			default:
+				if e >= '0' && e <= '7' {
+					end := i
+					for end < len(content) && end < i+3 && content[end] >= '0' && content[end] <= '7' {
+						end++
+					}
+					n, _ := strconv.ParseUint(string(content[i:end]), 8, 8)
+					out = append(out, byte(n))
+					i = end - 1
+				} else {
+					out = append(out, e)
+				}
+			}
+		case '(':
+			depth++
+			out = append(out, c)
+		case ')':
+			if depth == 0 {
+				return pdfDecodeBytes(out), i + 1
+			}
+			depth--
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return pdfDecodeBytes(out), i
+}
+
+// pdfHexString decodes a <...> string
+func pdfHexString(hex []byte) string {
+	var digits []byte
+	for _, c := range hex {
+		if unicode.Is(unicode.ASCII_Hex_Digit, rune(c)) {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+
+	out := make([]byte, 0, len(digits)/2)
+	for i := 0; i < len(digits); i += 2 {
+		n, _ := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		out = append(out, byte(n))
+	}
+	return pdfDecodeBytes(out)
+}
+
+// pdfDecodeBytes maps string bytes to text, reading UTF-16 when the string starts with
+// a byte order mark and Latin-1 otherwise. Control bytes of unknown encodings are dropped.
+func pdfDecodeBytes(b []byte) string {
+	var out strings.Builder
+	if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+		for i := 2; i+1 < len(b); i += 2 {
+			out.WriteRune(rune(b[i])<<8 | rune(b[i+1]))
+		}
+		return out.String()
+	}
+
+	for _, c := range b {
+		r := rune(c)
+		if unicode.IsPrint(r) || r == '\n' || r == '\t' {
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,321 @@
+package whatsapp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// ReplyProfile overrides the global auto-reply settings for the contacts, groups
+// and contact tags it is assigned to. Empty fields inherit the global setting.
+type ReplyProfile struct {
+	ID                int64    `json:"id"`
+	Name              string   `json:"name"`
+	Enabled           bool     `json:"enabled"`
+	SystemPrompt      string   `json:"system_prompt"`
+	AIProvider        string   `json:"ai_provider"`
+	Model             string   `json:"model"`
+	Temperature       *float64 `json:"temperature,omitempty"`
+	ResponseDelay     *int     `json:"response_delay,omitempty"` // seconds
+	MaxResponseLength int      `json:"max_response_length"`
+	Language          string   `json:"language"`         // e.g. "Indonesian", empty to not enforce one
+	Vision            *bool    `json:"vision,omitempty"` // send images to the model, nil inherits
+
+	// Assignment
+	Contacts []string `json:"contacts"` // phone numbers
+	Groups   []string `json:"groups"`   // group JIDs
+	Tags     []string `json:"tags"`     // contact tags
+
+	// Access lists replace the global whitelist for messages resolved to this profile
+	AllowList []string `json:"allow_list"` // phone numbers, empty allows everyone
+	DenyList  []string `json:"deny_list"`  // phone numbers
+}
+
+// Validate checks the profile fields
+func (p *ReplyProfile) Validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("profile name is required")
+	}
+	if p.AIProvider != "" {
+		found := false
+		for _, name := range ProviderNames() {
+			if name == p.AIProvider {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unsupported AI provider: %s", p.AIProvider)
+		}
+	}
+	if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2) {
+		return fmt.Errorf("temperature must be between 0 and 2")
+	}
+	if p.ResponseDelay != nil && *p.ResponseDelay < 0 {
+		return fmt.Errorf("response delay cannot be negative")
+	}
+	if p.MaxResponseLength < 0 {
+		return fmt.Errorf("max response length cannot be negative")
+	}
+
+	// Contact tags are stored lowercase
+	for i, tag := range p.Tags {
+		p.Tags[i] = strings.ToLower(strings.TrimSpace(tag))
+	}
+	return nil
+}
+
+// allows checks the sender against the profile's allow and deny lists
+func (p *ReplyProfile) allows(phoneNumber string) bool {
+	if containsString(p.DenyList, phoneNumber) {
+		return false
+	}
+	return len(p.AllowList) == 0 || containsString(p.AllowList, phoneNumber)
+}
+
+// ProfileResolver picks the profile that applies to an incoming message
+type ProfileResolver struct {
+	mu       sync.RWMutex
+	profiles []ReplyProfile
+}
+
+// NewProfileResolver creates a resolver without profiles
+func NewProfileResolver() *ProfileResolver {
+	return &ProfileResolver{}
+}
+
+// SetProfiles replaces the active profiles. Disabled profiles are skipped.
+func (r *ProfileResolver) SetProfiles(profiles []ReplyProfile) {
+	active := make([]ReplyProfile, 0, len(profiles))
+	for _, profile := range profiles {
+		if profile.Enabled {
+			active = append(active, profile)
+		}
+	}
+	sort.SliceStable(active, func(i, j int) bool { return active[i].ID < active[j].ID })
+
+	r.mu.Lock()
+	r.profiles = active
+	r.mu.Unlock()
+}
+
+// Resolve returns the profile for the message, or nil for the global settings.
+// A profile assigned to the contact wins over one assigned to the group, which wins over a tag.
+func (r *ProfileResolver) Resolve(chatJID, phoneNumber string, isGroup bool, tags []string) *ReplyProfile {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for i := range r.profiles {
+		if containsString(r.profiles[i].Contacts, phoneNumber) {
+			profile := r.profiles[i]
+			return &profile
+		}
+	}
+	if isGroup {
+		for i := range r.profiles {
+			if containsString(r.profiles[i].Groups, chatJID) {
+				profile := r.profiles[i]
+				return &profile
+			}
+		}
+	}
+	for i := range r.profiles {
+		for _, tag := range tags {
+			if containsString(r.profiles[i].Tags, tag) {
+				profile := r.profiles[i]
+				return &profile
+			}
+		}
+	}
+	return nil
+}
+
+// Named returns the active profile with the given name, or nil
+func (r *ProfileResolver) Named(name string) *ReplyProfile {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for i := range r.profiles {
+		if strings.EqualFold(r.profiles[i].Name, name) {
+			profile := r.profiles[i]
+			return &profile
+		}
+	}
+	return nil
+}
+
+// withProfile returns a copy of the config with the profile's overrides applied
+func (c *AutoReplyConfig) withProfile(p *ReplyProfile) *AutoReplyConfig {
+	config := *c
+	if p == nil {
+		return &config
+	}
+
+	if p.SystemPrompt != "" {
+		config.SystemPrompt = p.SystemPrompt
+	}
+	if p.Language != "" {
+		config.SystemPrompt = strings.TrimSpace(config.SystemPrompt + "\n\nAlways reply in " + p.Language + ".")
+	}
+	if p.AIProvider != "" {
+		config.AIProvider = p.AIProvider
+	}
+	if p.Model != "" {
+		config.setModel(config.AIProvider, p.Model)
+	}
+	if p.ResponseDelay != nil {
+		config.ResponseDelay = *p.ResponseDelay
+	}
+	if p.MaxResponseLength > 0 {
+		config.MaxResponseLength = p.MaxResponseLength
+	}
+	if p.Vision != nil {
+		config.Vision.Enabled = *p.Vision
+	}
+
+	return &config
+}
+
+// modelOf returns the model configured for the named provider
+func (c *AutoReplyConfig) modelOf(provider string) string {
+	switch provider {
+	case "openai":
+		return c.OpenAIModel
+	case "ollama":
+		return c.OllamaModel
+	default:
+		if section, ok := c.providerSections()[provider]; ok {
+			return section.Model
+		}
+		return ""
+	}
+}
+
+// setModel sets the model of the named provider
+func (c *AutoReplyConfig) setModel(provider, model string) {
+	switch provider {
+	case "openai":
+		c.OpenAIModel = model
+	case "ollama":
+		c.OllamaModel = model
+	default:
+		if section, ok := c.providerSections()[provider]; ok {
+			section.Model = model
+		}
+	}
+}
+
+// containsString reports whether the list contains the value
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Profile methods
+
+// GetReplyProfiles returns all stored reply profiles
+func (m *Manager) GetReplyProfiles() ([]ReplyProfile, error) {
+	if m.messageDB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	return m.messageDB.GetReplyProfiles()
+}
+
+// SaveReplyProfile creates or updates a reply profile and activates it
+func (m *Manager) SaveReplyProfile(profile *ReplyProfile) (*ReplyProfile, error) {
+	if m.messageDB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := m.messageDB.SaveReplyProfile(profile); err != nil {
+		return nil, err
+	}
+
+	m.reloadReplyProfiles()
+	return profile, nil
+}
+
+// DeleteReplyProfile removes a reply profile
+func (m *Manager) DeleteReplyProfile(id int64) error {
+	if m.messageDB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	if err := m.messageDB.DeleteReplyProfile(id); err != nil {
+		return err
+	}
+
+	m.reloadReplyProfiles()
+	return nil
+}
+
+// GetContactTags returns the tags of a contact
+func (m *Manager) GetContactTags(jid string) ([]string, error) {
+	if m.messageDB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	contactJID, err := types.ParseJID(jid)
+	if err != nil {
+		return nil, fmt.Errorf("invalid contact JID: %v", err)
+	}
+	return m.messageDB.GetContactTags(contactJID.ToNonAD().String())
+}
+
+// SetContactTags replaces the tags of a contact
+func (m *Manager) SetContactTags(jid string, tags []string) error {
+	if m.messageDB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	contactJID, err := types.ParseJID(jid)
+	if err != nil {
+		return fmt.Errorf("invalid contact JID: %v", err)
+	}
+
+	cleaned := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !containsString(cleaned, tag) {
+			cleaned = append(cleaned, tag)
+		}
+	}
+	return m.messageDB.SetContactTags(contactJID.ToNonAD().String(), cleaned)
+}
+
+// contactTags returns the tags of the sender, ignoring lookup errors
+func (m *Manager) contactTags(jid types.JID) []string {
+	if m.messageDB == nil {
+		return nil
+	}
+	tags, err := m.messageDB.GetContactTags(jid.ToNonAD().String())
+	if err != nil {
+		m.log.Errorf("Failed to load contact tags: %v", err)
+		return nil
+	}
+	return tags
+}
+
+// reloadReplyProfiles loads the profiles from the database into the auto-reply manager
+func (m *Manager) reloadReplyProfiles() {
+	if m.messageDB == nil || m.autoReply == nil {
+		return
+	}
+
+	profiles, err := m.messageDB.GetReplyProfiles()
+	if err != nil {
+		m.log.Errorf("Failed to load reply profiles: %v", err)
+		return
+	}
+	m.autoReply.profiles.SetProfiles(profiles)
+}
@@ -0,0 +1,360 @@
+package whatsapp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider generates AI replies for a conversation
+type Provider interface {
+	// Name returns the registry name of the provider
+	Name() string
+	// Generate returns the model's reply to the conversation
+	Generate(ctx context.Context, req GenerateRequest) (string, error)
+}
+
+// ToolCaller is implemented by providers that support function calling
+type ToolCaller interface {
+	Provider
+	// GenerateWithTools returns the model's reply, which may ask for tool calls instead of text
+	GenerateWithTools(ctx context.Context, req GenerateRequest, tools []ToolDefinition) (*ToolResponse, error)
+}
+
+// Streamer is implemented by providers that can stream their reply
+type Streamer interface {
+	Provider
+	// GenerateStream returns the model's reply like Generate, passing each piece of text
+	// to onChunk as it arrives
+	GenerateStream(ctx context.Context, req GenerateRequest, onChunk func(string)) (string, error)
+}
+
+// Kinds of provider errors, deciding whether a call is retried or the next provider is tried
+const (
+	ErrorAuth      = "auth"       // invalid or missing credentials
+	ErrorRateLimit = "rate_limit" // too many requests, retried after RetryAfter when given
+	ErrorTransient = "transient"  // network failures and server errors, worth a retry
+	ErrorPermanent = "permanent"  // the request was rejected, retrying won't help
+	ErrorNoTools   = "no_tools"   // the model does not support function calling, retry without tools
+)
+
+// ProviderError is a classified failure of an AI provider call
+type ProviderError struct {
+	Kind       string
+	Message    string
+	RetryAfter time.Duration // wait requested by a rate limited response, 0 if not given
+}
+
+func (e *ProviderError) Error() string {
+	return e.Message
+}
+
+// providerError creates a classified provider error
+func providerError(kind, format string, args ...interface{}) *ProviderError {
+	return &ProviderError{Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
+
+// rateLimitError returns the error of a rate limited response, honoring its Retry-After header
+func rateLimitError(header http.Header) *ProviderError {
+	err := providerError(ErrorRateLimit, "rate limit exceeded, please try again later")
+	err.RetryAfter = retryAfter(header, time.Now())
+	return err
+}
+
+// statusKind classifies an unsuccessful HTTP status
+func statusKind(status int) string {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrorAuth
+	case status == http.StatusTooManyRequests:
+		return ErrorRateLimit
+	case status == http.StatusRequestTimeout || status >= 500:
+		return ErrorTransient
+	default:
+		return ErrorPermanent
+	}
+}
+
+// errorKind returns the kind of an AI call error. Unclassified errors, such as network
+// failures and malformed responses, are transient.
+func errorKind(err error) string {
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return providerErr.Kind
+	}
+	return ErrorTransient
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date
+func retryAfter(header http.Header, now time.Time) time.Duration {
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// ToolDefinition describes a function the model may call, with JSON schema parameters
+type ToolDefinition struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// ToolCall is a function call requested by the model
+type ToolCall struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// ToolResponse is a model reply that may contain tool calls
+type ToolResponse struct {
+	Content   string
+	ToolCalls []ToolCall
+}
+
+// OpenAI tools format, also accepted by Ollama
+type OpenAITool struct {
+	Type     string         `json:"type"`
+	Function OpenAIFunction `json:"function"`
+}
+
+type OpenAIFunction struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// openAITools converts tool definitions to the OpenAI tools format
+func openAITools(tools []ToolDefinition) []OpenAITool {
+	if len(tools) == 0 {
+		return nil
+	}
+	converted := make([]OpenAITool, 0, len(tools))
+	for _, tool := range tools {
+		converted = append(converted, OpenAITool{
+			Type:     "function",
+			Function: OpenAIFunction{Name: tool.Name, Description: tool.Description, Parameters: tool.Parameters},
+		})
+	}
+	return converted
+}
+
+// GenerateRequest holds the input of a single AI completion
+type GenerateRequest struct {
+	Messages    []ChatMessage
+	MaxTokens   int
+	Temperature *float64 // nil uses the provider default
+
+	// Usage receives the token counts reported by the provider, if set
+	Usage *TokenUsage
+}
+
+// ProviderSettings is the config section of providers that only need an endpoint, key and model
+type ProviderSettings struct {
+	BaseURL string `json:"base_url"`
+	APIKey  string `json:"api_key"`
+	Model   string `json:"model"`
+}
+
+// providerSections maps provider names to their config section
+func (c *AutoReplyConfig) providerSections() map[string]*ProviderSettings {
+	return map[string]*ProviderSettings{
+		"openai_compatible": &c.OpenAICompatible,
+		"anthropic":         &c.Anthropic,
+		"gemini":            &c.Gemini,
+	}
+}
+
+// ProviderFactory creates a provider from the auto-reply configuration
+type ProviderFactory func(config *AutoReplyConfig, client *http.Client) (Provider, error)
+
+var (
+	providerRegistry    = make(map[string]ProviderFactory)
+	providerRegistryMux sync.RWMutex
+)
+
+// RegisterProvider makes a provider available under the given AIProvider name
+func RegisterProvider(name string, factory ProviderFactory) {
+	providerRegistryMux.Lock()
+	defer providerRegistryMux.Unlock()
+	providerRegistry[name] = factory
+}
+
+// NewProvider creates the provider registered under the given name
+func NewProvider(name string, config *AutoReplyConfig, client *http.Client) (Provider, error) {
+	providerRegistryMux.RLock()
+	factory, ok := providerRegistry[name]
+	providerRegistryMux.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unsupported AI provider: %s", name)
+	}
+	return factory(config, client)
+}
+
+// ProviderNames returns the names of all registered providers
+func ProviderNames() []string {
+	providerRegistryMux.RLock()
+	defer providerRegistryMux.RUnlock()
+
+	names := make([]string, 0, len(providerRegistry))
+	for name := range providerRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// postJSON sends a JSON request and returns the response status, headers and body
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, payload interface{}) (int, http.Header, []byte, error) {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("network error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, resp.Header, nil, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	return resp.StatusCode, resp.Header, body, nil
+}
+
+// streamIdleTimeout aborts a streamed response when no data arrived for this long
+const streamIdleTimeout = 60 * time.Second
+
+// postStream sends a JSON POST request and passes each line of a successful response to
+// onLine. Error responses return their status, headers and body like postJSON. The timeout of the
+// client does not apply, as long replies stream for a while; instead the request is
+// aborted when the stream stalls for streamIdleTimeout.
+func postStream(ctx context.Context, client *http.Client, url string, headers map[string]string, payload interface{}, onLine func(line []byte) error) (int, http.Header, []byte, error) {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	idle := time.AfterFunc(streamIdleTimeout, cancel)
+	defer idle.Stop()
+
+	req, err := http.NewRequestWithContext(streamCtx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	streamClient := &http.Client{Transport: client.Transport, CheckRedirect: client.CheckRedirect, Jar: client.Jar}
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		if ctx.Err() == nil && streamCtx.Err() != nil {
+			return 0, nil, nil, fmt.Errorf("network error: no response within %v", streamIdleTimeout)
+		}
+		return 0, nil, nil, fmt.Errorf("network error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return resp.StatusCode, resp.Header, nil, fmt.Errorf("failed to read response: %v", err)
+		}
+		return resp.StatusCode, resp.Header, body, nil
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		idle.Reset(streamIdleTimeout)
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if err := onLine(line); err != nil {
+			return resp.StatusCode, resp.Header, nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if ctx.Err() == nil && streamCtx.Err() != nil {
+			return resp.StatusCode, resp.Header, nil, fmt.Errorf("response stalled for %v", streamIdleTimeout)
+		}
+		return resp.StatusCode, resp.Header, nil, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	return resp.StatusCode, resp.Header, nil, nil
+}
+
+// splitSystemPrompt separates system messages from the conversation turns
+func splitSystemPrompt(messages []ChatMessage) (string, []ChatMessage) {
+	var system string
+	turns := make([]ChatMessage, 0, len(messages))
+	for _, msg := range messages {
+		if msg.Role == "system" {
+			if system != "" {
+				system += "\n\n"
+			}
+			system += msg.Content
+			continue
+		}
+		turns = append(turns, msg)
+	}
+	return system, turns
+}
+
+// mergeConsecutiveTurns joins adjacent turns of the same role, for APIs that require
+// strictly alternating user/assistant messages starting with the user
+func mergeConsecutiveTurns(turns []ChatMessage) []ChatMessage {
+	merged := make([]ChatMessage, 0, len(turns))
+	for _, turn := range turns {
+		if len(merged) == 0 && turn.Role != "user" {
+			continue
+		}
+		if n := len(merged); n > 0 && merged[n-1].Role == turn.Role {
+			merged[n-1].Content += "\n" + turn.Content
+			continue
+		}
+		merged = append(merged, turn)
+	}
+	return merged
+}
@@ -0,0 +1,138 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	anthropicBaseURL   = "https://api.anthropic.com"
+	anthropicVersion   = "2023-06-01"
+	anthropicMaxTokens = 1024
+)
+
+func init() {
+	RegisterProvider("anthropic", func(config *AutoReplyConfig, client *http.Client) (Provider, error) {
+		settings := config.Anthropic
+		if settings.APIKey == "" {
+			return nil, fmt.Errorf("Anthropic API key not configured")
+		}
+		if settings.BaseURL == "" {
+			settings.BaseURL = anthropicBaseURL
+		}
+		return &anthropicProvider{settings: settings, client: client}, nil
+	})
+}
+
+// Anthropic Messages API structures
+type AnthropicRequest struct {
+	Model       string             `json:"model"`
+	System      string             `json:"system,omitempty"`
+	Messages    []AnthropicMessage `json:"messages"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature *float64           `json:"temperature,omitempty"`
+}
+
+type AnthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type AnthropicResponse struct {
+	Content []AnthropicContent `json:"content"`
+	Usage   struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+type AnthropicContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// anthropicProvider talks to the Anthropic Messages API
+type anthropicProvider struct {
+	settings ProviderSettings
+	client   *http.Client
+}
+
+func (p *anthropicProvider) Name() string {
+	return "anthropic"
+}
+
+// Generate generates a response using the Anthropic Messages API
+func (p *anthropicProvider) Generate(ctx context.Context, req GenerateRequest) (string, error) {
+	system, turns := splitSystemPrompt(req.Messages)
+
+	// The API requires alternating turns starting with the user
+	var messages []AnthropicMessage
+	for _, turn := range mergeConsecutiveTurns(turns) {
+		messages = append(messages, AnthropicMessage{Role: turn.Role, Content: turn.Content})
+	}
+	if len(messages) == 0 {
+		return "", fmt.Errorf("no user message to answer")
+	}
+
+	maxTokens := req.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = anthropicMaxTokens
+	}
+
+	request := AnthropicRequest{
+		Model:       p.settings.Model,
+		System:      system,
+		Messages:    messages,
+		MaxTokens:   maxTokens,
+		Temperature: req.Temperature,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	headers := map[string]string{
+		"x-api-key":         p.settings.APIKey,
+		"anthropic-version": anthropicVersion,
+	}
+
+	url := strings.TrimSuffix(p.settings.BaseURL, "/") + "/v1/messages"
+	status, header, body, err := postJSON(ctx, p.client, url, headers, request)
+	if err != nil {
+		return "", err
+	}
+
+	switch status {
+	case http.StatusOK:
+		// Success, continue processing
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return "", providerError(ErrorAuth, "invalid API key")
+	case http.StatusTooManyRequests:
+		return "", rateLimitError(header)
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, 529:
+		return "", providerError(ErrorTransient, "Anthropic service temporarily unavailable")
+	default:
+		return "", providerError(statusKind(status), "Anthropic API error (status %d): %s", status, string(body))
+	}
+
+	var anthropicResp AnthropicResponse
+	if err := json.Unmarshal(body, &anthropicResp); err != nil {
+		return "", fmt.Errorf("failed to parse response: %v", err)
+	}
+	req.Usage.add(anthropicResp.Usage.InputTokens, anthropicResp.Usage.OutputTokens)
+
+	var text strings.Builder
+	for _, content := range anthropicResp.Content {
+		if content.Type == "text" {
+			text.WriteString(content.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return "", fmt.Errorf("empty response from Anthropic")
+	}
+
+	return text.String(), nil
+}
@@ -0,0 +1,141 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const geminiBaseURL = "https://generativelanguage.googleapis.com"
+
+func init() {
+	RegisterProvider("gemini", func(config *AutoReplyConfig, client *http.Client) (Provider, error) {
+		settings := config.Gemini
+		if settings.APIKey == "" {
+			return nil, fmt.Errorf("Gemini API key not configured")
+		}
+		if settings.BaseURL == "" {
+			settings.BaseURL = geminiBaseURL
+		}
+		return &geminiProvider{settings: settings, client: client}, nil
+	})
+}
+
+// Gemini generateContent API structures
+type GeminiRequest struct {
+	SystemInstruction *GeminiContent        `json:"systemInstruction,omitempty"`
+	Contents          []GeminiContent       `json:"contents"`
+	GenerationConfig  *GeminiGenerateConfig `json:"generationConfig,omitempty"`
+}
+
+type GeminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []GeminiPart `json:"parts"`
+}
+
+type GeminiPart struct {
+	Text string `json:"text,omitempty"`
+}
+
+type GeminiGenerateConfig struct {
+	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
+	Temperature     *float64 `json:"temperature,omitempty"`
+}
+
+type GeminiResponse struct {
+	Candidates    []GeminiCandidate `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+type GeminiCandidate struct {
+	Content GeminiContent `json:"content"`
+}
+
+// geminiProvider talks to the Google Gemini generateContent API
+type geminiProvider struct {
+	settings ProviderSettings
+	client   *http.Client
+}
+
+func (p *geminiProvider) Name() string {
+	return "gemini"
+}
+
+// Generate generates a response using the Gemini generateContent API
+func (p *geminiProvider) Generate(ctx context.Context, req GenerateRequest) (string, error) {
+	system, turns := splitSystemPrompt(req.Messages)
+
+	request := GeminiRequest{}
+	if system != "" {
+		request.SystemInstruction = &GeminiContent{Parts: []GeminiPart{{Text: system}}}
+	}
+	for _, turn := range mergeConsecutiveTurns(turns) {
+		// Gemini calls the assistant role "model"
+		role := turn.Role
+		if role == "assistant" {
+			role = "model"
+		}
+		request.Contents = append(request.Contents, GeminiContent{
+			Role:  role,
+			Parts: []GeminiPart{{Text: turn.Content}},
+		})
+	}
+	if len(request.Contents) == 0 {
+		return "", fmt.Errorf("no user message to answer")
+	}
+	if req.MaxTokens > 0 || req.Temperature != nil {
+		request.GenerationConfig = &GeminiGenerateConfig{MaxOutputTokens: req.MaxTokens, Temperature: req.Temperature}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	headers := map[string]string{"x-goog-api-key": p.settings.APIKey}
+
+	endpoint := fmt.Sprintf("%s/v1beta/models/%s:generateContent",
+		strings.TrimSuffix(p.settings.BaseURL, "/"), url.PathEscape(p.settings.Model))
+	status, header, body, err := postJSON(ctx, p.client, endpoint, headers, request)
+	if err != nil {
+		return "", err
+	}
+
+	switch status {
+	case http.StatusOK:
+		// Success, continue processing
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return "", providerError(ErrorAuth, "invalid API key")
+	case http.StatusTooManyRequests:
+		return "", rateLimitError(header)
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable:
+		return "", providerError(ErrorTransient, "Gemini service temporarily unavailable")
+	default:
+		return "", providerError(statusKind(status), "Gemini API error (status %d): %s", status, string(body))
+	}
+
+	var geminiResp GeminiResponse
+	if err := json.Unmarshal(body, &geminiResp); err != nil {
+		return "", fmt.Errorf("failed to parse response: %v", err)
+	}
+	req.Usage.add(geminiResp.UsageMetadata.PromptTokenCount, geminiResp.UsageMetadata.CandidatesTokenCount)
+
+	if len(geminiResp.Candidates) == 0 {
+		return "", fmt.Errorf("no response candidates returned from Gemini")
+	}
+
+	var text strings.Builder
+	for _, part := range geminiResp.Candidates[0].Content.Parts {
+		text.WriteString(part.Text)
+	}
+	if text.Len() == 0 {
+		return "", fmt.Errorf("empty response from Gemini")
+	}
+
+	return text.String(), nil
+}
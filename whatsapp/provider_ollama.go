@@ -0,0 +1,228 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterProvider("ollama", func(config *AutoReplyConfig, client *http.Client) (Provider, error) {
+		if config.OllamaURL == "" {
+			return nil, fmt.Errorf("ollama URL not configured")
+		}
+		return &ollamaProvider{
+			baseURL: config.OllamaURL,
+			model:   config.OllamaModel,
+			client:  client,
+		}, nil
+	})
+}
+
+// Ollama API structures
+type OllamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []OllamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Options  *OllamaOptions  `json:"options,omitempty"`
+	Tools    []OpenAITool    `json:"tools,omitempty"`
+}
+
+type OllamaOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+}
+
+type OllamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []OllamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"` // tool a "tool" message answers
+	Images    []string         `json:"images,omitempty"`    // base64 encoded
+}
+
+type OllamaToolCall struct {
+	Function OllamaFunctionCall `json:"function"`
+}
+
+type OllamaFunctionCall struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"` // JSON object
+}
+
+type OllamaResponse struct {
+	Message         OllamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error,omitempty"` // reported mid-stream
+}
+
+// ollamaProvider talks to a local Ollama server through its chat API
+type ollamaProvider struct {
+	baseURL string
+	model   string
+	client  *http.Client
+}
+
+func (p *ollamaProvider) Name() string {
+	return "ollama"
+}
+
+// Generate generates response using Ollama chat API with enhanced error handling
+func (p *ollamaProvider) Generate(ctx context.Context, req GenerateRequest) (string, error) {
+	message, err := p.chat(ctx, req, nil)
+	if err != nil {
+		return "", err
+	}
+	if message.Content == "" {
+		return "", fmt.Errorf("empty response from Ollama")
+	}
+	return message.Content, nil
+}
+
+// GenerateWithTools generates a response that may call the given tools. Ollama doesn't
+// identify tool calls, so they are numbered.
+func (p *ollamaProvider) GenerateWithTools(ctx context.Context, req GenerateRequest, tools []ToolDefinition) (*ToolResponse, error) {
+	message, err := p.chat(ctx, req, tools)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &ToolResponse{Content: message.Content}
+	for i, call := range message.ToolCalls {
+		arguments := call.Function.Arguments
+		if len(arguments) == 0 {
+			arguments = json.RawMessage("{}")
+		}
+		resp.ToolCalls = append(resp.ToolCalls, ToolCall{
+			ID:        fmt.Sprintf("call_%d", i+1),
+			Name:      call.Function.Name,
+			Arguments: arguments,
+		})
+	}
+	if resp.Content == "" && len(resp.ToolCalls) == 0 {
+		return nil, fmt.Errorf("empty response from Ollama")
+	}
+	return resp, nil
+}
+
+// GenerateStream generates a response like Generate, streaming it as JSON lines
+func (p *ollamaProvider) GenerateStream(ctx context.Context, req GenerateRequest, onChunk func(string)) (string, error) {
+	request := p.request(req, nil)
+	request.Stream = true
+
+	var response strings.Builder
+	status, header, body, err := postStream(ctx, p.client, p.endpoint(), nil, request, func(line []byte) error {
+		var chunk OllamaResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return fmt.Errorf("failed to parse response: %v", err)
+		}
+		if chunk.Error != "" {
+			return fmt.Errorf("ollama error: %s", chunk.Error)
+		}
+		if chunk.Message.Content != "" {
+			response.WriteString(chunk.Message.Content)
+			onChunk(chunk.Message.Content)
+		}
+		if chunk.Done {
+			req.Usage.add(chunk.PromptEvalCount, chunk.EvalCount)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("%v (check if Ollama is running)", err)
+	}
+	if err := p.statusError(status, header, body, false); err != nil {
+		return "", err
+	}
+	if response.Len() == 0 {
+		return "", fmt.Errorf("empty response from Ollama")
+	}
+
+	return response.String(), nil
+}
+
+// chat sends a chat request and returns the reply message
+func (p *ollamaProvider) chat(ctx context.Context, req GenerateRequest, tools []ToolDefinition) (*OllamaMessage, error) {
+	request := p.request(req, tools)
+
+	// Create request with timeout context
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second) // Longer timeout for local models
+	defer cancel()
+
+	status, header, body, err := postJSON(ctx, p.client, p.endpoint(), nil, request)
+	if err != nil {
+		return nil, fmt.Errorf("%v (check if Ollama is running)", err)
+	}
+	if err := p.statusError(status, header, body, len(tools) > 0); err != nil {
+		return nil, err
+	}
+
+	var ollamaResp OllamaResponse
+	if err := json.Unmarshal(body, &ollamaResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %v", err)
+	}
+	req.Usage.add(ollamaResp.PromptEvalCount, ollamaResp.EvalCount)
+
+	return &ollamaResp.Message, nil
+}
+
+// request converts the conversation to a chat request
+func (p *ollamaProvider) request(req GenerateRequest, tools []ToolDefinition) OllamaRequest {
+	messages := make([]OllamaMessage, 0, len(req.Messages))
+	for _, msg := range req.Messages {
+		message := OllamaMessage{Role: msg.Role, Content: msg.Content, ToolName: msg.Name}
+		for _, image := range msg.Images {
+			message.Images = append(message.Images, image.base64())
+		}
+		for _, call := range msg.ToolCalls {
+			message.ToolCalls = append(message.ToolCalls, OllamaToolCall{
+				Function: OllamaFunctionCall{Name: call.Name, Arguments: call.Arguments},
+			})
+		}
+		messages = append(messages, message)
+	}
+
+	request := OllamaRequest{
+		Model:    p.model,
+		Messages: messages,
+		Stream:   false,
+		Tools:    openAITools(tools),
+	}
+	if req.Temperature != nil {
+		request.Options = &OllamaOptions{Temperature: req.Temperature}
+	}
+	return request
+}
+
+// endpoint returns the chat API URL
+func (p *ollamaProvider) endpoint() string {
+	return strings.TrimSuffix(p.baseURL, "/") + "/api/chat"
+}
+
+// statusError returns the classified error of an unsuccessful response
+func (p *ollamaProvider) statusError(status int, header http.Header, body []byte, withTools bool) error {
+	// Enhanced error handling for different HTTP status codes
+	switch status {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return providerError(ErrorPermanent, "model '%s' not found in Ollama", p.model)
+	case http.StatusBadRequest:
+		if withTools && strings.Contains(string(body), "does not support tools") {
+			return providerError(ErrorNoTools, "model '%s' does not support tools", p.model)
+		}
+		return providerError(ErrorPermanent, "ollama API error (status %d): %s", status, string(body))
+	case http.StatusTooManyRequests:
+		return rateLimitError(header)
+	case http.StatusInternalServerError:
+		return providerError(ErrorTransient, "ollama internal error: %s", string(body))
+	case http.StatusServiceUnavailable:
+		return providerError(ErrorTransient, "ollama service unavailable")
+	default:
+		return providerError(statusKind(status), "ollama API error (status %d): %s", status, string(body))
+	}
+}
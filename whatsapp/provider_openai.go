@@ -0,0 +1,297 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const openAIBaseURL = "https://api.openai.com/v1"
+
+func init() {
+	RegisterProvider("openai", func(config *AutoReplyConfig, client *http.Client) (Provider, error) {
+		if config.OpenAIAPIKey == "" {
+			return nil, fmt.Errorf("OpenAI API key not configured")
+		}
+		return &openAIProvider{
+			name:    "openai",
+			label:   "OpenAI",
+			baseURL: openAIBaseURL,
+			apiKey:  config.OpenAIAPIKey,
+			model:   config.OpenAIModel,
+			client:  client,
+		}, nil
+	})
+
+	RegisterProvider("openai_compatible", func(config *AutoReplyConfig, client *http.Client) (Provider, error) {
+		settings := config.OpenAICompatible
+		if settings.BaseURL == "" {
+			return nil, fmt.Errorf("OpenAI-compatible base URL not configured")
+		}
+		return &openAIProvider{
+			name:    "openai_compatible",
+			label:   "OpenAI-compatible server",
+			baseURL: settings.BaseURL,
+			apiKey:  settings.APIKey,
+			model:   settings.Model,
+			client:  client,
+		}, nil
+	})
+}
+
+// OpenAI API structures
+type OpenAIRequest struct {
+	Model       string          `json:"model"`
+	Messages    []OpenAIMessage `json:"messages"`
+	MaxTokens   int             `json:"max_tokens,omitempty"`
+	Temperature *float64        `json:"temperature,omitempty"`
+	Tools       []OpenAITool    `json:"tools,omitempty"`
+
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *OpenAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+// OpenAIStreamOptions asks for the token usage in the last chunk of a stream
+type OpenAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type OpenAIMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []OpenAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+	Images     []ChatImage      `json:"-"`
+}
+
+// OpenAIContentPart is a part of a message with images
+type OpenAIContentPart struct {
+	Type     string          `json:"type"` // "text" or "image_url"
+	Text     string          `json:"text,omitempty"`
+	ImageURL *OpenAIImageURL `json:"image_url,omitempty"`
+}
+
+type OpenAIImageURL struct {
+	URL string `json:"url"`
+}
+
+// MarshalJSON sends the content as text and image parts when the message has images
+func (m OpenAIMessage) MarshalJSON() ([]byte, error) {
+	type message OpenAIMessage
+	if len(m.Images) == 0 {
+		return json.Marshal(message(m))
+	}
+
+	parts := make([]OpenAIContentPart, 0, len(m.Images)+1)
+	if m.Content != "" {
+		parts = append(parts, OpenAIContentPart{Type: "text", Text: m.Content})
+	}
+	for _, image := range m.Images {
+		parts = append(parts, OpenAIContentPart{Type: "image_url", ImageURL: &OpenAIImageURL{URL: image.dataURL()}})
+	}
+	return json.Marshal(struct {
+		message
+		Content []OpenAIContentPart `json:"content"`
+	}{message(m), parts})
+}
+
+type OpenAIToolCall struct {
+	ID       string             `json:"id"`
+	Type     string             `json:"type"`
+	Function OpenAIFunctionCall `json:"function"`
+}
+
+type OpenAIFunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON encoded
+}
+
+type OpenAIResponse struct {
+	Choices []OpenAIChoice `json:"choices"`
+	Usage   *OpenAIUsage   `json:"usage,omitempty"`
+}
+
+type OpenAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+type OpenAIChoice struct {
+	Message OpenAIMessage `json:"message"`
+	Delta   OpenAIMessage `json:"delta"` // streamed chunk
+}
+
+// openAIProvider talks to the OpenAI chat completions API or any server implementing it
+// (LM Studio, vLLM, llama.cpp server, ...)
+type openAIProvider struct {
+	name    string
+	label   string
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+}
+
+func (p *openAIProvider) Name() string {
+	return p.name
+}
+
+// Generate generates a response using the chat completions API with enhanced error handling
+func (p *openAIProvider) Generate(ctx context.Context, req GenerateRequest) (string, error) {
+	message, err := p.chat(ctx, req, nil)
+	if err != nil {
+		return "", err
+	}
+	return message.Content, nil
+}
+
+// GenerateWithTools generates a response that may call the given tools
+func (p *openAIProvider) GenerateWithTools(ctx context.Context, req GenerateRequest, tools []ToolDefinition) (*ToolResponse, error) {
+	message, err := p.chat(ctx, req, tools)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &ToolResponse{Content: message.Content}
+	for _, call := range message.ToolCalls {
+		arguments := json.RawMessage(call.Function.Arguments)
+		if len(arguments) == 0 {
+			arguments = json.RawMessage("{}")
+		}
+		resp.ToolCalls = append(resp.ToolCalls, ToolCall{ID: call.ID, Name: call.Function.Name, Arguments: arguments})
+	}
+	return resp, nil
+}
+
+// GenerateStream generates a response like Generate, streaming it as server-sent events
+func (p *openAIProvider) GenerateStream(ctx context.Context, req GenerateRequest, onChunk func(string)) (string, error) {
+	request := p.request(req, nil)
+	request.Stream = true
+	request.StreamOptions = &OpenAIStreamOptions{IncludeUsage: true}
+
+	var response strings.Builder
+	status, header, body, err := postStream(ctx, p.client, p.endpoint(), p.headers(), request, func(line []byte) error {
+		data, ok := bytes.CutPrefix(line, []byte("data:"))
+		if !ok {
+			return nil
+		}
+		data = bytes.TrimSpace(data)
+		if string(data) == "[DONE]" {
+			return nil
+		}
+
+		var chunk OpenAIResponse
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("failed to parse response: %v", err)
+		}
+		if chunk.Usage != nil {
+			req.Usage.add(chunk.Usage.PromptTokens, chunk.Usage.CompletionTokens)
+		}
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			response.WriteString(chunk.Choices[0].Delta.Content)
+			onChunk(chunk.Choices[0].Delta.Content)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := p.statusError(status, header, body); err != nil {
+		return "", err
+	}
+	if response.Len() == 0 {
+		return "", fmt.Errorf("no response returned from %s", p.label)
+	}
+
+	return response.String(), nil
+}
+
+// chat sends a chat completions request and returns the reply message
+func (p *openAIProvider) chat(ctx context.Context, req GenerateRequest, tools []ToolDefinition) (*OpenAIMessage, error) {
+	request := p.request(req, tools)
+
+	// Create request with timeout context
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	status, header, body, err := postJSON(ctx, p.client, p.endpoint(), p.headers(), request)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.statusError(status, header, body); err != nil {
+		return nil, err
+	}
+
+	var openAIResp OpenAIResponse
+	if err := json.Unmarshal(body, &openAIResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %v", err)
+	}
+	if openAIResp.Usage != nil {
+		req.Usage.add(openAIResp.Usage.PromptTokens, openAIResp.Usage.CompletionTokens)
+	}
+
+	if len(openAIResp.Choices) == 0 {
+		return nil, fmt.Errorf("no response choices returned from %s", p.label)
+	}
+
+	return &openAIResp.Choices[0].Message, nil
+}
+
+// request converts the conversation to a chat completions request
+func (p *openAIProvider) request(req GenerateRequest, tools []ToolDefinition) OpenAIRequest {
+	messages := make([]OpenAIMessage, 0, len(req.Messages))
+	for _, msg := range req.Messages {
+		message := OpenAIMessage{Role: msg.Role, Content: msg.Content, ToolCallID: msg.ToolCallID, Images: msg.Images}
+		for _, call := range msg.ToolCalls {
+			message.ToolCalls = append(message.ToolCalls, OpenAIToolCall{
+				ID:       call.ID,
+				Type:     "function",
+				Function: OpenAIFunctionCall{Name: call.Name, Arguments: string(call.Arguments)},
+			})
+		}
+		messages = append(messages, message)
+	}
+
+	return OpenAIRequest{
+		Model:       p.model,
+		Messages:    messages,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		Tools:       openAITools(tools),
+	}
+}
+
+// endpoint returns the chat completions URL
+func (p *openAIProvider) endpoint() string {
+	return strings.TrimSuffix(p.baseURL, "/") + "/chat/completions"
+}
+
+// headers returns the request headers
+func (p *openAIProvider) headers() map[string]string {
+	headers := map[string]string{}
+	if p.apiKey != "" {
+		headers["Authorization"] = "Bearer " + p.apiKey
+	}
+	return headers
+}
+
+// statusError returns the classified error of an unsuccessful response
+func (p *openAIProvider) statusError(status int, header http.Header, body []byte) error {
+	// Enhanced error handling for different HTTP status codes
+	switch status {
+	case http.StatusOK:
+		return nil
+	case http.StatusUnauthorized:
+		return providerError(ErrorAuth, "invalid API key")
+	case http.StatusTooManyRequests:
+		return rateLimitError(header)
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable:
+		return providerError(ErrorTransient, "%s service temporarily unavailable", p.label)
+	default:
+		return providerError(statusKind(status), "%s API error (status %d): %s", p.label, status, string(body))
+	}
+}
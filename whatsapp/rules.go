@@ -0,0 +1,279 @@
+package whatsapp
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReplyRule is a canned reply sent when an incoming message matches its pattern
+type ReplyRule struct {
+	ID            int64  `json:"id"`
+	Name          string `json:"name"`
+	MatchType     string `json:"match_type"` // "exact", "contains", "starts_with", "regex"
+	Pattern       string `json:"pattern"`
+	CaseSensitive bool   `json:"case_sensitive"`
+	// Scope limits where the rule applies: "all" (private chats), "chat" (ScopeValue is a chat JID),
+	// "contact" (ScopeValue is a phone number) or "group" (ScopeValue is a group JID, empty for every group)
+	Scope        string `json:"scope"`
+	ScopeValue   string `json:"scope_value"`
+	Priority     int    `json:"priority"` // higher runs first
+	ResponseText string `json:"response_text"`
+	MediaPath    string `json:"media_path,omitempty"`
+	MediaType    string `json:"media_type,omitempty"` // "image", "video", "audio", "document"
+	Action       string `json:"action"`               // "stop" skips the AI, "continue" also asks the AI
+	Intent       string `json:"intent,omitempty"`     // only messages classified with this intent, empty for all
+	Enabled      bool   `json:"enabled"`
+	HitCount     int64  `json:"hit_count"`
+	LastHitAt    int64  `json:"last_hit_at"`
+}
+
+// Validate checks the rule fields and fills in defaults
+func (r *ReplyRule) Validate() error {
+	if strings.TrimSpace(r.Pattern) == "" {
+		return fmt.Errorf("pattern is required")
+	}
+	if r.ResponseText == "" && r.MediaPath == "" {
+		return fmt.Errorf("rule needs a response text or media file")
+	}
+
+	switch r.MatchType {
+	case "exact", "contains", "starts_with":
+	case "regex":
+		if _, err := compileRulePattern(r); err != nil {
+			return fmt.Errorf("invalid regex: %v", err)
+		}
+	default:
+		return fmt.Errorf("unsupported match type: %s", r.MatchType)
+	}
+
+	if r.Scope == "" {
+		r.Scope = "all"
+	}
+	switch r.Scope {
+	case "all", "group":
+	case "chat", "contact":
+		if r.ScopeValue == "" {
+			return fmt.Errorf("scope %s needs a value", r.Scope)
+		}
+	default:
+		return fmt.Errorf("unsupported scope: %s", r.Scope)
+	}
+
+	if r.MediaPath != "" {
+		switch r.MediaType {
+		case "image", "video", "audio", "document":
+		default:
+			return fmt.Errorf("unsupported media type: %s", r.MediaType)
+		}
+	}
+
+	if r.Action == "" {
+		r.Action = "stop"
+	}
+	if r.Action != "stop" && r.Action != "continue" {
+		return fmt.Errorf("unsupported action: %s", r.Action)
+	}
+
+	return nil
+}
+
+// compileRulePattern compiles the regex of a rule, honouring its case sensitivity
+func compileRulePattern(r *ReplyRule) (*regexp.Regexp, error) {
+	pattern := r.Pattern
+	if !r.CaseSensitive {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// RuleMessage is the part of an incoming message the rules are matched against
+type RuleMessage struct {
+	ChatJID string
+	Sender  string // phone number of the sender
+	IsGroup bool
+	Text    string
+	Intent  string // classified intent, empty when classification is off
+}
+
+// compiledRule is a rule prepared for matching
+type compiledRule struct {
+	rule  ReplyRule
+	regex *regexp.Regexp
+}
+
+// RuleEngine matches incoming messages against the reply rules
+type RuleEngine struct {
+	mu    sync.RWMutex
+	rules []compiledRule
+}
+
+// NewRuleEngine creates an empty rule engine
+func NewRuleEngine() *RuleEngine {
+	return &RuleEngine{}
+}
+
+// SetRules replaces the active rules. Disabled and invalid rules are skipped.
+func (e *RuleEngine) SetRules(rules []ReplyRule) {
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		c := compiledRule{rule: rule}
+		if rule.MatchType == "regex" {
+			regex, err := compileRulePattern(&rule)
+			if err != nil {
+				fmt.Printf("Skipping reply rule %d: invalid regex: %v\n", rule.ID, err)
+				continue
+			}
+			c.regex = regex
+		}
+		compiled = append(compiled, c)
+	}
+
+	// Highest priority first, oldest rule first on ties
+	sort.SliceStable(compiled, func(i, j int) bool {
+		if compiled[i].rule.Priority != compiled[j].rule.Priority {
+			return compiled[i].rule.Priority > compiled[j].rule.Priority
+		}
+		return compiled[i].rule.ID < compiled[j].rule.ID
+	})
+
+	e.mu.Lock()
+	e.rules = compiled
+	e.mu.Unlock()
+}
+
+// Match returns the first rule matching the message, or nil
+func (e *RuleEngine) Match(msg RuleMessage) *ReplyRule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for i := range e.rules {
+		c := &e.rules[i]
+		if !c.inScope(msg) || (c.rule.Intent != "" && c.rule.Intent != msg.Intent) || !c.matches(msg.Text) {
+			continue
+		}
+		rule := c.rule
+		return &rule
+	}
+	return nil
+}
+
+// inScope reports whether the rule applies to the chat the message came from
+func (c *compiledRule) inScope(msg RuleMessage) bool {
+	switch c.rule.Scope {
+	case "chat":
+		return msg.ChatJID == c.rule.ScopeValue
+	case "contact":
+		return msg.Sender == c.rule.ScopeValue
+	case "group":
+		return msg.IsGroup && (c.rule.ScopeValue == "" || msg.ChatJID == c.rule.ScopeValue)
+	default:
+		return !msg.IsGroup
+	}
+}
+
+// matches reports whether the text matches the rule pattern
+func (c *compiledRule) matches(text string) bool {
+	if c.regex != nil {
+		return c.regex.MatchString(text)
+	}
+
+	text = strings.TrimSpace(text)
+	pattern := c.rule.Pattern
+	if !c.rule.CaseSensitive {
+		text = strings.ToLower(text)
+		pattern = strings.ToLower(pattern)
+	}
+
+	switch c.rule.MatchType {
+	case "exact":
+		return text == pattern
+	case "contains":
+		return strings.Contains(text, pattern)
+	case "starts_with":
+		return strings.HasPrefix(text, pattern)
+	}
+	return false
+}
+
+// Reply rule methods
+
+// GetReplyRules returns all stored reply rules
+func (m *Manager) GetReplyRules() ([]ReplyRule, error) {
+	if m.messageDB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	return m.messageDB.GetReplyRules()
+}
+
+// SaveReplyRule creates or updates a reply rule and activates it
+func (m *Manager) SaveReplyRule(rule *ReplyRule) (*ReplyRule, error) {
+	if m.messageDB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := m.messageDB.SaveReplyRule(rule); err != nil {
+		return nil, err
+	}
+
+	m.reloadReplyRules()
+	return rule, nil
+}
+
+// DeleteReplyRule removes a reply rule
+func (m *Manager) DeleteReplyRule(id int64) error {
+	if m.messageDB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	if err := m.messageDB.DeleteReplyRule(id); err != nil {
+		return err
+	}
+
+	m.reloadReplyRules()
+	return nil
+}
+
+// reloadReplyRules loads the rules from the database into the auto-reply manager
+func (m *Manager) reloadReplyRules() {
+	if m.messageDB == nil || m.autoReply == nil {
+		return
+	}
+
+	rules, err := m.messageDB.GetReplyRules()
+	if err != nil {
+		m.log.Errorf("Failed to load reply rules: %v", err)
+		return
+	}
+	m.autoReply.rules.SetRules(rules)
+}
+
+// sendRuleReply sends the canned response of a matched rule and records the hit once
+// it is sent. It returns the ID of the sent message.
+func (m *Manager) sendRuleReply(chatJID string, rule *ReplyRule) (string, error) {
+	var id string
+	var err error
+	if rule.MediaPath != "" {
+		if id, err = m.SendMedia(chatJID, rule.MediaPath, rule.MediaType, rule.ResponseText); err != nil {
+			fmt.Printf("Failed to send reply rule %d media: %v\n", rule.ID, err)
+		}
+	} else if id, err = m.SendText(chatJID, rule.ResponseText); err != nil {
+		fmt.Printf("Failed to send reply rule %d: %v\n", rule.ID, err)
+	}
+
+	if err == nil && m.messageDB != nil {
+		if err := m.messageDB.RecordReplyRuleHit(rule.ID, time.Now()); err != nil {
+			m.log.Errorf("Failed to record reply rule hit: %v", err)
+		}
+	}
+	return id, err
+}
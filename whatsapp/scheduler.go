@@ -3,6 +3,7 @@ package whatsapp
 import (
 	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
@@ -79,6 +80,11 @@ type TaskExecution struct {
 	Results   []string  `json:"results,omitempty"` // Message IDs or status IDs
 }
 
+// cronParser accepts standard five-field expressions and an optional leading seconds field
+var cronParser = cron.NewParser(
+	cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
 // Scheduler manages scheduled tasks
 type Scheduler struct {
 	cron      *cron.Cron
@@ -92,7 +98,7 @@ type Scheduler struct {
 // NewScheduler creates a new scheduler instance
 func NewScheduler(manager *Manager, logger *log.Logger) *Scheduler {
 	return &Scheduler{
-		cron:    cron.New(cron.WithSeconds()),
+		cron:    cron.New(cron.WithParser(cronParser)),
 		tasks:   make(map[string]*ScheduledTask),
 		manager: manager,
 		logger:  logger,
@@ -123,6 +129,81 @@ func (s *Scheduler) Stop() {
 	s.logger.Println("Scheduler stopped")
 }
 
+// SaveState persists all tasks to the message database
+func (s *Scheduler) SaveState() error {
+	if s.manager == nil || s.manager.messageDB == nil {
+		return fmt.Errorf("message database not initialized")
+	}
+
+	s.tasksMux.RLock()
+	defer s.tasksMux.RUnlock()
+
+	if err := s.saveTasks(); err != nil {
+		return err
+	}
+
+	s.logger.Printf("Saved %d scheduled tasks", len(s.tasks))
+	return nil
+}
+
+// saveTasks writes all tasks to the message database. tasksMux must be held.
+func (s *Scheduler) saveTasks() error {
+	tasks := make([]*ScheduledTask, 0, len(s.tasks))
+	for _, task := range s.tasks {
+		tasks = append(tasks, task)
+	}
+	return s.manager.messageDB.SaveScheduledTasks(tasks)
+}
+
+// persist saves the tasks after a change, so it survives a crash. tasksMux must be held.
+func (s *Scheduler) persist() {
+	if s.manager == nil || s.manager.messageDB == nil {
+		return
+	}
+	if err := s.saveTasks(); err != nil {
+		s.logger.Printf("Failed to save scheduled tasks: %v", err)
+	}
+}
+
+// LoadState restores tasks persisted by SaveState and schedules the active ones
+func (s *Scheduler) LoadState() error {
+	if s.manager == nil || s.manager.messageDB == nil {
+		return fmt.Errorf("message database not initialized")
+	}
+
+	tasks, err := s.manager.messageDB.LoadScheduledTasks()
+	if err != nil {
+		return err
+	}
+
+	s.tasksMux.Lock()
+	defer s.tasksMux.Unlock()
+
+	for _, task := range tasks {
+		// A task interrupted by shutdown is ready to run again
+		if task.Status == TaskStatusRunning {
+			task.Status = TaskStatusPending
+		}
+
+		if task.IsActive {
+			taskID := task.ID
+			if _, err := s.cron.AddFunc(task.CronExpr, func() {
+				s.executeTask(taskID)
+			}); err != nil {
+				s.logger.Printf("Failed to restore task %s: %v", task.ID, err)
+				task.Status = TaskStatusFailed
+				task.ErrorMsg = err.Error()
+				task.IsActive = false
+			}
+		}
+
+		s.tasks[task.ID] = task
+	}
+
+	s.logger.Printf("Restored %d scheduled tasks", len(tasks))
+	return nil
+}
+
 // AddTask adds a new scheduled task
 func (s *Scheduler) AddTask(task *ScheduledTask) error {
 	s.tasksMux.Lock()
@@ -141,7 +222,7 @@ func (s *Scheduler) AddTask(task *ScheduledTask) error {
 	task.IsActive = true
 
 	// Validate cron expression
-	schedule, err := cron.ParseStandard(task.CronExpr)
+	schedule, err := cronParser.Parse(task.CronExpr)
 	if err != nil {
 		return fmt.Errorf("invalid cron expression: %v", err)
 	}
@@ -161,6 +242,7 @@ func (s *Scheduler) AddTask(task *ScheduledTask) error {
 	// Store task
 	s.tasks[task.ID] = task
 	s.logger.Printf("Added scheduled task: %s (ID: %s, Cron ID: %d)", task.Name, task.ID, cronID)
+	s.persist()
 
 	return nil
 }
@@ -179,6 +261,7 @@ func (s *Scheduler) RemoveTask(taskID string) error {
 	task.Status = TaskStatusCancelled
 	task.IsActive = false
 	task.UpdatedAt = time.Now()
+	s.persist()
 
 	s.logger.Printf("Removed scheduled task: %s (ID: %s)", task.Name, taskID)
 	return nil
@@ -231,7 +314,7 @@ func (s *Scheduler) UpdateTask(taskID string, updatedTask *ScheduledTask) error
 	task.UpdatedAt = time.Now()
 
 	// Validate new cron expression
-	schedule, err := cron.ParseStandard(task.CronExpr)
+	schedule, err := cronParser.Parse(task.CronExpr)
 	if err != nil {
 		return fmt.Errorf("invalid cron expression: %v", err)
 	}
@@ -239,6 +322,7 @@ func (s *Scheduler) UpdateTask(taskID string, updatedTask *ScheduledTask) error
 	// Update next run time
 	nextRun := schedule.Next(time.Now())
 	task.NextRun = &nextRun
+	s.persist()
 
 	s.logger.Printf("Updated scheduled task: %s (ID: %s)", task.Name, taskID)
 	return nil
@@ -289,7 +373,7 @@ func (s *Scheduler) executeTask(taskID string) {
 	}
 
 	// Calculate next run time
-	schedule, _ := cron.ParseStandard(task.CronExpr)
+	schedule, _ := cronParser.Parse(task.CronExpr)
 	nextRun := schedule.Next(time.Now())
 	task.NextRun = &nextRun
 	task.UpdatedAt = time.Now()
@@ -353,22 +437,19 @@ func (s *Scheduler) processContent(content TaskContent) TaskContent {
 	processedContent := content
 
 	// Replace variables in text
-	if content.Variables != nil {
-		text := content.Text
-		for key, value := range content.Variables {
-			placeholder := fmt.Sprintf("{{%s}}", key)
-			text = fmt.Sprintf("%s %s", placeholder, value)
-		}
-		processedContent.Text = text
+	text := content.Text
+	for key, value := range content.Variables {
+		text = strings.ReplaceAll(text, fmt.Sprintf("{{%s}}", key), value)
 	}
 
 	// Add timestamp variables
 	now := time.Now()
-	processedContent.Text = fmt.Sprintf(processedContent.Text,
+	text = strings.NewReplacer(
 		"{{date}}", now.Format("2006-01-02"),
 		"{{time}}", now.Format("15:04:05"),
 		"{{datetime}}", now.Format("2006-01-02 15:04:05"),
-	)
+	).Replace(text)
+	processedContent.Text = text
 
 	return processedContent
 }
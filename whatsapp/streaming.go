@@ -0,0 +1,138 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// StreamingConfig controls streamed generation of AI replies
+type StreamingConfig struct {
+	Enabled   bool `json:"enabled"`    // stream replies from providers that support it
+	EarlySend bool `json:"early_send"` // send the first paragraph while the rest is generated
+}
+
+// GetDefaultStreamingConfig returns the default streaming settings
+func GetDefaultStreamingConfig() StreamingConfig {
+	return StreamingConfig{
+		Enabled:   true,
+		EarlySend: false,
+	}
+}
+
+// typingRefreshInterval is how often the typing indicator is renewed while a reply streams in.
+// WhatsApp clears it by itself after about 25 seconds.
+const typingRefreshInterval = 10 * time.Second
+
+// generateStream returns the reply of the provider, passing the text to onChunk as it arrives.
+// Providers that cannot stream pass the whole reply at once.
+func generateStream(ctx context.Context, provider Provider, req GenerateRequest, onChunk func(string)) (string, error) {
+	if streamer, ok := provider.(Streamer); ok {
+		return streamer.GenerateStream(ctx, req, onChunk)
+	}
+
+	response, err := provider.Generate(ctx, req)
+	if err == nil && response != "" {
+		onChunk(response)
+	}
+	return response, err
+}
+
+// replyStream follows an AI reply streaming in: it keeps the typing indicator alive and
+// may send the first paragraph as soon as it is complete
+type replyStream struct {
+	arm       *AutoReplyManager
+	manager   *Manager
+	job       *replyJob
+	earlySend bool
+
+	text       strings.Builder
+	lastTyping time.Time
+	sentText   string   // raw text of the paragraph sent early
+	sentIDs    []string // messages sent early
+}
+
+// newReplyStream starts following the reply of a job. Drafts for approval and dry runs
+// are never sent early.
+func (arm *AutoReplyManager) newReplyStream(manager *Manager, job *replyJob) *replyStream {
+	config := job.config
+	return &replyStream{
+		arm:        arm,
+		manager:    manager,
+		job:        job,
+		earlySend:  config.Streaming.EarlySend && !job.decision.DryRun && !config.Approval.supervised(job.sender),
+		lastTyping: time.Now(),
+	}
+}
+
+// onChunk handles the next piece of the reply
+func (s *replyStream) onChunk(chunk string) {
+	s.text.WriteString(chunk)
+
+	if time.Since(s.lastTyping) >= typingRefreshInterval {
+		s.arm.setPresence(s.manager, s.job, types.ChatPresenceComposing)
+		s.lastTyping = time.Now()
+	}
+
+	if s.earlySend && s.sentText == "" {
+		if paragraph, ok := firstParagraph(s.text.String()); ok {
+			s.sendEarly(paragraph)
+		}
+	}
+}
+
+// sendEarly sends the first paragraph of the reply. It is given up if the chat was taken
+// over in the meantime, which the final check of respond then handles.
+func (s *replyStream) sendEarly(paragraph string) {
+	s.earlySend = false
+	if s.manager.isAutoReplyPaused(s.job.chatJID) {
+		return
+	}
+
+	id, err := s.manager.SendText(s.job.chatJID, markdownToWhatsApp(strings.TrimSpace(paragraph)))
+	if err != nil {
+		fmt.Printf("Failed to send first paragraph early: %v\n", err)
+		return
+	}
+	s.sentText = paragraph
+	s.sentIDs = append(s.sentIDs, id)
+
+	// Keep typing for the rest of the reply
+	s.arm.setPresence(s.manager, s.job, types.ChatPresenceComposing)
+	s.lastTyping = time.Now()
+}
+
+// sent reports whether part of the reply already went out
+func (s *replyStream) sent() bool {
+	return s.sentText != ""
+}
+
+// remainder returns the part of the full reply that was not sent early
+func (s *replyStream) remainder(response string) string {
+	if !s.sent() {
+		return response
+	}
+	return strings.TrimSpace(strings.TrimPrefix(response, s.sentText))
+}
+
+// firstParagraph returns the text up to the end of its first paragraph once a blank line
+// ends it. A paragraph opening a code block is not complete until the block is closed.
+func firstParagraph(text string) (string, bool) {
+	start := len(text) - len(strings.TrimLeft(text, "\r\n"))
+	end := strings.Index(text[start:], "\n\n")
+	for end >= 0 {
+		paragraph := text[:start+end]
+		if strings.TrimSpace(paragraph) != "" && strings.Count(paragraph, "```")%2 == 0 {
+			return paragraph, true
+		}
+		next := strings.Index(text[start+end+2:], "\n\n")
+		if next < 0 {
+			break
+		}
+		end += 2 + next
+	}
+	return "", false
+}
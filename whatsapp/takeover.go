@@ -0,0 +1,165 @@
+package whatsapp
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// TakeoverConfig controls how auto-reply steps back when we answer a chat ourselves
+type TakeoverConfig struct {
+	Enabled      bool   `json:"enabled"`
+	PauseMinutes int    `json:"pause_minutes"` // pause after a manual reply
+	OffKeyword   string `json:"off_keyword"`   // sent by us in a chat to pause until resumed
+	OnKeyword    string `json:"on_keyword"`    // sent by us in a chat to resume
+}
+
+// GetDefaultTakeoverConfig returns the default takeover settings
+func GetDefaultTakeoverConfig() TakeoverConfig {
+	return TakeoverConfig{
+		Enabled:      true,
+		PauseMinutes: 30,
+		OffKeyword:   "/bot off",
+		OnKeyword:    "/bot on",
+	}
+}
+
+// ChatPause is a chat where auto-reply is suspended
+type ChatPause struct {
+	ChatJID     string `json:"chat_jid"`
+	PausedUntil int64  `json:"paused_until"` // 0 means until resumed
+	Reason      string `json:"reason"`       // "manual_reply", "keyword" or "operator"
+	Paused      bool   `json:"paused"`       // false when the pause was lifted
+}
+
+// handleOwnMessage reacts to a message we sent from another device: keywords switch
+// auto-reply for the chat and any other message pauses it for a while
+func (arm *AutoReplyManager) handleOwnMessage(evt *events.Message, manager *Manager) {
+	config := arm.config.Takeover
+	if !config.Enabled {
+		return
+	}
+	chatJID := evt.Info.Chat.String()
+	text := strings.TrimSpace(arm.extractMessageText(evt))
+
+	if config.OffKeyword != "" && strings.EqualFold(text, config.OffKeyword) {
+		if err := manager.PauseAutoReply(chatJID, 0, "keyword"); err != nil {
+			fmt.Printf("Failed to pause auto-reply: %v\n", err)
+		}
+		return
+	}
+	if config.OnKeyword != "" && strings.EqualFold(text, config.OnKeyword) {
+		if err := manager.ResumeAutoReply(chatJID); err != nil {
+			fmt.Printf("Failed to resume auto-reply: %v\n", err)
+		}
+		return
+	}
+
+	manager.noteManualReply(chatJID)
+}
+
+// noteManualReply pauses auto-reply in the chat after we answered it ourselves
+func (m *Manager) noteManualReply(chatJID string) {
+	if m.messageDB == nil || m.autoReply == nil || m.autoReply.config == nil {
+		return
+	}
+	config := m.autoReply.config.Takeover
+	if !config.Enabled || config.PauseMinutes <= 0 {
+		return
+	}
+
+	// Don't shorten a pause that lasts until resumed
+	if pause, err := m.messageDB.GetChatPause(chatJID); err == nil && pause != nil && pause.PausedUntil == 0 {
+		return
+	}
+
+	if err := m.PauseAutoReply(chatJID, config.PauseMinutes, "manual_reply"); err != nil {
+		m.log.Errorf("Failed to pause auto-reply after manual reply: %v", err)
+	}
+}
+
+// isAutoReplyPaused reports whether auto-reply is suspended in the chat
+func (m *Manager) isAutoReplyPaused(chatJID string) bool {
+	if m.messageDB == nil {
+		return false
+	}
+
+	pause, err := m.messageDB.GetChatPause(chatJID)
+	if err != nil {
+		m.log.Errorf("Failed to load chat pause: %v", err)
+		return false
+	}
+	if pause == nil {
+		return false
+	}
+	return pause.PausedUntil == 0 || time.Now().Unix() < pause.PausedUntil
+}
+
+// Takeover methods
+
+// PauseAutoReply suspends auto-reply in a chat for the given minutes, 0 pauses until resumed
+func (m *Manager) PauseAutoReply(chatJID string, minutes int, reason string) error {
+	if m.messageDB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	if minutes < 0 {
+		return fmt.Errorf("pause duration cannot be negative")
+	}
+
+	pause := ChatPause{ChatJID: chatJID, Reason: reason, Paused: true}
+	if minutes > 0 {
+		pause.PausedUntil = time.Now().Add(time.Duration(minutes) * time.Minute).Unix()
+	}
+
+	if err := m.messageDB.SaveChatPause(&pause); err != nil {
+		return err
+	}
+
+	m.emitEvent(ConnectionEvent{
+		Type:    "autoreply_pause",
+		Message: "Auto-reply paused",
+		Payload: pause,
+	})
+	return nil
+}
+
+// ResumeAutoReply lifts the auto-reply pause of a chat
+func (m *Manager) ResumeAutoReply(chatJID string) error {
+	if m.messageDB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	if err := m.messageDB.DeleteChatPause(chatJID); err != nil {
+		return err
+	}
+
+	m.emitEvent(ConnectionEvent{
+		Type:    "autoreply_pause",
+		Message: "Auto-reply resumed",
+		Payload: ChatPause{ChatJID: chatJID},
+	})
+	return nil
+}
+
+// GetPausedChats returns the chats where auto-reply is currently suspended
+func (m *Manager) GetPausedChats() ([]ChatPause, error) {
+	if m.messageDB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	return m.messageDB.GetActiveChatPauses(time.Now())
+}
+
+// SendManualMessage sends a message typed by the operator and pauses auto-reply in the chat
+func (m *Manager) SendManualMessage(chatID, text string) error {
+	if err := m.SendMessage(chatID, text); err != nil {
+		return err
+	}
+
+	if jid, err := types.ParseJID(chatID); err == nil {
+		m.noteManualReply(jid.String())
+	}
+	return nil
+}
@@ -0,0 +1,705 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Built-in tools
+const (
+	ToolLookupContact  = "lookup_contact"
+	ToolSearchHistory  = "search_history"
+	ToolCreateReminder = "create_reminder"
+	ToolSendMedia      = "send_media"
+	ToolHandoff        = "handoff_to_human"
+)
+
+// maxToolResult bounds the characters of a tool result passed back to the model
+const maxToolResult = 4000
+
+// ToolsConfig controls function calling of the AI assistant
+type ToolsConfig struct {
+	Enabled        bool        `json:"enabled"`
+	DisabledTools  []string    `json:"disabled_tools"`  // built-in tools not offered to the model
+	TimeoutSeconds int         `json:"timeout_seconds"` // per tool call
+	MaxRounds      int         `json:"max_rounds"`      // tool call rounds per reply
+	MediaLibrary   []MediaFile `json:"media_library"`   // files the send_media tool may send
+	HTTPTools      []HTTPTool  `json:"http_tools"`
+}
+
+// MediaFile is a stored file the assistant may send
+type MediaFile struct {
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	Type        string `json:"type"` // image, video, audio or document
+	Description string `json:"description"`
+}
+
+// HTTPTool is a user-defined tool that calls an HTTP endpoint. Arguments are sent as
+// query parameters for GET and as a JSON body otherwise, and fill {{name}} placeholders
+// in the URL.
+type HTTPTool struct {
+	Name           string            `json:"name"`
+	Description    string            `json:"description"`
+	Method         string            `json:"method"`
+	URL            string            `json:"url"`
+	Headers        map[string]string `json:"headers"`
+	Parameters     []ToolParameter   `json:"parameters"`
+	TimeoutSeconds int               `json:"timeout_seconds"` // 0 uses the global tool timeout
+}
+
+// sideEffects reports whether calling the tool may change something. Only GET requests
+// are treated as read-only.
+func (t *HTTPTool) sideEffects() bool {
+	return t.Method != http.MethodGet
+}
+
+// ToolParameter is an argument of a user-defined tool
+type ToolParameter struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"` // string, number, integer or boolean
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+}
+
+// GetDefaultToolsConfig returns the default function calling settings
+func GetDefaultToolsConfig() ToolsConfig {
+	return ToolsConfig{
+		Enabled:        false,
+		DisabledTools:  []string{},
+		TimeoutSeconds: 10,
+		MaxRounds:      4,
+		MediaLibrary:   []MediaFile{},
+		HTTPTools:      []HTTPTool{},
+	}
+}
+
+var toolNameRe = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// Validate checks the tool settings
+func (c *ToolsConfig) Validate() error {
+	if c.TimeoutSeconds <= 0 {
+		return fmt.Errorf("tool timeout must be positive")
+	}
+	if c.MaxRounds <= 0 {
+		return fmt.Errorf("at least one tool round is required")
+	}
+
+	for _, file := range c.MediaLibrary {
+		if file.Name == "" || file.Path == "" {
+			return fmt.Errorf("media files need a name and a path")
+		}
+		switch file.Type {
+		case "image", "video", "audio", "document":
+		default:
+			return fmt.Errorf("unsupported media type for %s: %s", file.Name, file.Type)
+		}
+	}
+
+	builtIn := []string{ToolLookupContact, ToolSearchHistory, ToolCreateReminder, ToolSendMedia, ToolHandoff}
+	seen := map[string]bool{}
+	for i := range c.HTTPTools {
+		tool := &c.HTTPTools[i]
+		if !toolNameRe.MatchString(tool.Name) {
+			return fmt.Errorf("invalid tool name %q (use letters, digits, _ and -)", tool.Name)
+		}
+		if containsString(builtIn, tool.Name) || seen[tool.Name] {
+			return fmt.Errorf("duplicate tool name: %s", tool.Name)
+		}
+		seen[tool.Name] = true
+
+		tool.Method = strings.ToUpper(tool.Method)
+		if tool.Method == "" {
+			tool.Method = http.MethodGet
+		}
+		if tool.Method != http.MethodGet && tool.Method != http.MethodPost && tool.Method != http.MethodPut {
+			return fmt.Errorf("unsupported method for %s: %s", tool.Name, tool.Method)
+		}
+		if u, err := url.Parse(tool.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("invalid URL for %s", tool.Name)
+		}
+		if tool.TimeoutSeconds < 0 {
+			return fmt.Errorf("timeout of %s cannot be negative", tool.Name)
+		}
+		for _, param := range tool.Parameters {
+			switch param.Type {
+			case "string", "number", "integer", "boolean":
+			default:
+				return fmt.Errorf("unsupported type of %s.%s: %s", tool.Name, param.Name, param.Type)
+			}
+		}
+	}
+	return nil
+}
+
+// ToolAuditEntry records a tool call made by the assistant
+type ToolAuditEntry struct {
+	ID         int64  `json:"id"`
+	ChatJID    string `json:"chat_jid"`
+	MessageID  string `json:"message_id"` // incoming message being answered
+	Tool       string `json:"tool"`
+	Arguments  string `json:"arguments"`
+	Result     string `json:"result"`
+	Error      string `json:"error"`
+	DurationMs int64  `json:"duration_ms"`
+	CreatedAt  int64  `json:"created_at"`
+}
+
+// toolSession runs the tool calls of a single reply
+type toolSession struct {
+	manager  *Manager
+	job      *replyJob
+	config   ToolsConfig
+	readOnly bool // no tools with side effects, for replies that wait for approval
+
+	mu        sync.Mutex
+	results   map[string]string // call signature -> result, so retries don't repeat side effects
+	handedOff bool
+}
+
+// newToolSession prepares the tools for a reply, or returns nil when tools are disabled
+func (m *Manager) newToolSession(job *replyJob, readOnly bool) *toolSession {
+	if !job.config.Tools.Enabled {
+		return nil
+	}
+	return &toolSession{
+		manager:  m,
+		job:      job,
+		config:   job.config.Tools,
+		readOnly: readOnly,
+		results:  make(map[string]string),
+	}
+}
+
+// definitions returns the tools offered to the model
+func (s *toolSession) definitions() []ToolDefinition {
+	var tools []ToolDefinition
+	offer := func(name string, sideEffects bool) bool {
+		return !containsString(s.config.DisabledTools, name) && !(sideEffects && s.readOnly)
+	}
+
+	if offer(ToolLookupContact, false) {
+		tools = append(tools, ToolDefinition{
+			Name:        ToolLookupContact,
+			Description: "Look up what is known about the person you are talking to: name, phone number and tags.",
+			Parameters:  objectSchema(nil),
+		})
+	}
+	if offer(ToolSearchHistory, false) {
+		tools = append(tools, ToolDefinition{
+			Name:        ToolSearchHistory,
+			Description: "Search earlier messages of this chat for a word or phrase.",
+			Parameters: objectSchema(map[string]interface{}{
+				"query": stringProperty("Text to search for"),
+				"limit": map[string]interface{}{"type": "integer", "description": "Maximum messages to return, default 10"},
+			}, "query"),
+		})
+	}
+	if offer(ToolCreateReminder, true) {
+		tools = append(tools, ToolDefinition{
+			Name:        ToolCreateReminder,
+			Description: "Schedule a reminder message to this chat. Use it when the person asks to be reminded later.",
+			Parameters: objectSchema(map[string]interface{}{
+				"message": stringProperty("Reminder text to send"),
+				"time":    stringProperty("Local time to send it, formatted as YYYY-MM-DD HH:MM"),
+			}, "message", "time"),
+		})
+	}
+	if offer(ToolSendMedia, true) && len(s.config.MediaLibrary) > 0 {
+		var names, lines []string
+		for _, file := range s.config.MediaLibrary {
+			names = append(names, file.Name)
+			lines = append(lines, fmt.Sprintf("%s (%s): %s", file.Name, file.Type, file.Description))
+		}
+		tools = append(tools, ToolDefinition{
+			Name:        ToolSendMedia,
+			Description: "Send one of these stored files to the chat:\n" + strings.Join(lines, "\n"),
+			Parameters: objectSchema(map[string]interface{}{
+				"name":    map[string]interface{}{"type": "string", "enum": names, "description": "File to send"},
+				"caption": stringProperty("Optional caption"),
+			}, "name"),
+		})
+	}
+	if offer(ToolHandoff, true) {
+		tools = append(tools, ToolDefinition{
+			Name: ToolHandoff,
+			Description: "Hand the conversation over to a human colleague and stop answering automatically. " +
+				"Use it for complaints, refunds or anything you cannot handle.",
+			Parameters: objectSchema(map[string]interface{}{
+				"reason": stringProperty("Why a human is needed"),
+			}, "reason"),
+		})
+	}
+
+	for _, tool := range s.config.HTTPTools {
+		if s.readOnly && tool.sideEffects() {
+			continue
+		}
+		properties := map[string]interface{}{}
+		var required []string
+		for _, param := range tool.Parameters {
+			properties[param.Name] = map[string]interface{}{"type": param.Type, "description": param.Description}
+			if param.Required {
+				required = append(required, param.Name)
+			}
+		}
+		tools = append(tools, ToolDefinition{
+			Name:        tool.Name,
+			Description: tool.Description,
+			Parameters:  objectSchema(properties, required...),
+		})
+	}
+	return tools
+}
+
+// objectSchema builds the JSON schema of a tool's arguments
+func objectSchema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	if properties == nil {
+		properties = map[string]interface{}{}
+	}
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// stringProperty is the schema of a string argument
+func stringProperty(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "string", "description": description}
+}
+
+// run generates a reply, executing the tool calls the model asks for in between
+func (s *toolSession) run(ctx context.Context, provider ToolCaller, req GenerateRequest) (string, error) {
+	tools := s.definitions()
+	if len(tools) == 0 {
+		return provider.Generate(ctx, req)
+	}
+
+	// Tool turns are appended to a copy, the job's conversation is reused on retries
+	req.Messages = append([]ChatMessage(nil), req.Messages...)
+
+	for round := 0; round < s.config.MaxRounds; round++ {
+		resp, err := provider.GenerateWithTools(ctx, req, tools)
+		if err != nil {
+			if round == 0 && errorKind(err) == ErrorNoTools {
+				fmt.Printf("%v, replying without tools\n", err)
+				return provider.Generate(ctx, req)
+			}
+			return "", err
+		}
+		if len(resp.ToolCalls) == 0 {
+			return resp.Content, nil
+		}
+
+		req.Messages = append(req.Messages, ChatMessage{Role: "assistant", Content: resp.Content, ToolCalls: resp.ToolCalls})
+		for _, call := range resp.ToolCalls {
+			req.Messages = append(req.Messages, ChatMessage{
+				Role:       "tool",
+				Content:    s.call(ctx, call),
+				ToolCallID: call.ID,
+				Name:       call.Name,
+			})
+		}
+	}
+
+	return "", fmt.Errorf("assistant still calling tools after %d rounds", s.config.MaxRounds)
+}
+
+// call executes a tool call with a timeout and records it in the audit log. Failures are
+// returned to the model as the result so it can explain or try something else.
+func (s *toolSession) call(ctx context.Context, call ToolCall) string {
+	signature := call.Name + string(call.Arguments)
+	s.mu.Lock()
+	if result, ok := s.results[signature]; ok {
+		s.mu.Unlock()
+		return result
+	}
+	s.mu.Unlock()
+
+	timeout := time.Duration(s.config.TimeoutSeconds) * time.Second
+	if tool := s.httpTool(call.Name); tool != nil && tool.TimeoutSeconds > 0 {
+		timeout = time.Duration(tool.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	started := time.Now()
+	type outcome struct {
+		result string
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := s.execute(ctx, call)
+		done <- outcome{result, err}
+	}()
+
+	var out outcome
+	select {
+	case out = <-done:
+	case <-ctx.Done():
+		if s.sideEffects(call.Name) {
+			// These tools give up at ctx themselves; waiting for them tells the model
+			// truthfully whether the action happened
+			out = <-done
+		} else {
+			out.err = fmt.Errorf("tool timed out after %v", timeout)
+		}
+	}
+
+	entry := &ToolAuditEntry{
+		ChatJID:    s.job.chatJID,
+		MessageID:  s.job.messageID,
+		Tool:       call.Name,
+		Arguments:  string(call.Arguments),
+		Result:     out.result,
+		DurationMs: time.Since(started).Milliseconds(),
+		CreatedAt:  started.Unix(),
+	}
+	if out.err != nil {
+		entry.Error = out.err.Error()
+		out.result = "Error: " + out.err.Error()
+	}
+	if s.manager.messageDB != nil {
+		if err := s.manager.messageDB.AddToolAuditEntry(entry); err != nil {
+			s.manager.log.Errorf("Failed to write tool audit log: %v", err)
+		}
+	}
+	fmt.Printf("Tool %s for %s took %dms\n", call.Name, s.job.chatJID, entry.DurationMs)
+
+	result := truncateRunes(out.result, maxToolResult)
+	s.mu.Lock()
+	s.results[signature] = result
+	s.mu.Unlock()
+	return result
+}
+
+// builtInSideEffects lists the built-in tools and whether they change something
+var builtInSideEffects = map[string]bool{
+	ToolLookupContact:  false,
+	ToolSearchHistory:  false,
+	ToolCreateReminder: true,
+	ToolSendMedia:      true,
+	ToolHandoff:        true,
+}
+
+// sideEffects reports whether the named tool may change something
+func (s *toolSession) sideEffects(name string) bool {
+	if sideEffects, ok := builtInSideEffects[name]; ok {
+		return sideEffects
+	}
+	if tool := s.httpTool(name); tool != nil {
+		return tool.sideEffects()
+	}
+	return false
+}
+
+// execute runs a tool call
+func (s *toolSession) execute(ctx context.Context, call ToolCall) (string, error) {
+	_, builtIn := builtInSideEffects[call.Name]
+	if builtIn && containsString(s.config.DisabledTools, call.Name) {
+		return "", fmt.Errorf("tool %s is not available", call.Name)
+	}
+	if s.readOnly && s.sideEffects(call.Name) {
+		return "", fmt.Errorf("tool %s is not available", call.Name)
+	}
+
+	switch call.Name {
+	case ToolLookupContact:
+		return s.lookupContact()
+	case ToolSearchHistory:
+		return s.searchHistory(call.Arguments)
+	case ToolCreateReminder:
+		return s.createReminder(ctx, call.Arguments)
+	case ToolSendMedia:
+		return s.sendMedia(ctx, call.Arguments)
+	case ToolHandoff:
+		return s.handoff(ctx, call.Arguments)
+	}
+
+	if tool := s.httpTool(call.Name); tool != nil {
+		return s.callHTTPTool(ctx, tool, call.Arguments)
+	}
+	return "", fmt.Errorf("unknown tool: %s", call.Name)
+}
+
+// lookupContact describes the sender of the message being answered
+func (s *toolSession) lookupContact() (string, error) {
+	senderJID, err := types.ParseJID(s.job.senderJID)
+	if err != nil {
+		return "", fmt.Errorf("unknown sender")
+	}
+
+	info := map[string]interface{}{
+		"name":  s.manager.getContactName(senderJID.String()),
+		"phone": "+" + s.job.sender,
+		"tags":  s.manager.contactTags(senderJID),
+	}
+	if s.manager.client != nil && s.manager.client.Store != nil && s.manager.client.Store.Contacts != nil {
+		if contact, err := s.manager.client.Store.Contacts.GetContact(context.Background(), senderJID); err == nil && contact.Found {
+			if contact.FullName != "" {
+				info["saved_name"] = contact.FullName
+			}
+			if contact.BusinessName != "" {
+				info["business_name"] = contact.BusinessName
+			}
+		}
+	}
+
+	data, err := json.Marshal(info)
+	return string(data), err
+}
+
+// searchHistory finds earlier messages of the chat containing the query
+func (s *toolSession) searchHistory(arguments json.RawMessage) (string, error) {
+	var args struct {
+		Query string `json:"query"`
+		Limit int    `json:"limit"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %v", err)
+	}
+	if strings.TrimSpace(args.Query) == "" {
+		return "", fmt.Errorf("query is required")
+	}
+	if args.Limit <= 0 || args.Limit > 50 {
+		args.Limit = 10
+	}
+
+	messages, err := s.manager.messageDB.SearchChatMessages(s.job.chatJID, args.Query, args.Limit)
+	if err != nil {
+		return "", err
+	}
+	if len(messages) == 0 {
+		return "No messages found.", nil
+	}
+
+	var lines []string
+	for _, msg := range messages {
+		author := "Customer"
+		if msg.IsFromMe {
+			author = "Us"
+		}
+		lines = append(lines, fmt.Sprintf("[%s] %s: %s",
+			time.Unix(msg.Timestamp, 0).Format("2006-01-02 15:04"), author, msg.Content))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// createReminder schedules a one-off message to the chat
+func (s *toolSession) createReminder(ctx context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Message string `json:"message"`
+		Time    string `json:"time"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %v", err)
+	}
+	if strings.TrimSpace(args.Message) == "" {
+		return "", fmt.Errorf("message is required")
+	}
+	if s.manager.scheduler == nil {
+		return "", fmt.Errorf("scheduler not available")
+	}
+
+	at, err := time.ParseInLocation("2006-01-02 15:04", strings.TrimSpace(args.Time), time.Local)
+	if err != nil {
+		return "", fmt.Errorf("time must be formatted as YYYY-MM-DD HH:MM")
+	}
+	now := time.Now()
+	if !at.After(now) {
+		return "", fmt.Errorf("reminder time is in the past")
+	}
+	if at.After(now.AddDate(1, 0, 0)) {
+		return "", fmt.Errorf("reminders can be at most a year ahead")
+	}
+
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	task := &ScheduledTask{
+		Name:       "Reminder for " + s.manager.getContactName(s.job.chatJID),
+		Type:       TaskTypeMessage,
+		CronExpr:   fmt.Sprintf("%d %d %d %d *", at.Minute(), at.Hour(), at.Day(), int(at.Month())),
+		Recipients: []string{s.job.chatJID},
+		Content:    TaskContent{Text: args.Message},
+		MaxRuns:    1,
+	}
+	if err := s.manager.scheduler.AddTask(task); err != nil {
+		return "", err
+	}
+	if err := s.manager.scheduler.SaveState(); err != nil {
+		s.manager.log.Errorf("Failed to save scheduled tasks: %v", err)
+	}
+
+	return fmt.Sprintf("Reminder scheduled for %s.", at.Format("Monday 2 January 2006 15:04")), nil
+}
+
+// sendMedia sends a file of the media library to the chat
+func (s *toolSession) sendMedia(ctx context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Name    string `json:"name"`
+		Caption string `json:"caption"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %v", err)
+	}
+
+	for _, file := range s.config.MediaLibrary {
+		if file.Name == args.Name {
+			if _, err := s.manager.sendMedia(ctx, s.job.chatJID, file.Path, file.Type, args.Caption); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Sent %s.", file.Name), nil
+		}
+	}
+	return "", fmt.Errorf("no media file named %q", args.Name)
+}
+
+// handoff pauses auto-reply in the chat until the operator resumes it
+func (s *toolSession) handoff(ctx context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %v", err)
+	}
+
+	reason := "handoff"
+	if args.Reason != "" {
+		reason = "handoff: " + args.Reason
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if err := s.manager.PauseAutoReply(s.job.chatJID, 0, reason); err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	s.handedOff = true
+	s.mu.Unlock()
+	return "A human colleague will take over this chat. Tell the person they will be contacted shortly.", nil
+}
+
+// handedOffToHuman reports whether the model handed the chat over during this reply
+func (s *toolSession) handedOffToHuman() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.handedOff
+}
+
+// httpTool returns the user-defined tool with the given name
+func (s *toolSession) httpTool(name string) *HTTPTool {
+	for i := range s.config.HTTPTools {
+		if s.config.HTTPTools[i].Name == name {
+			return &s.config.HTTPTools[i]
+		}
+	}
+	return nil
+}
+
+// callHTTPTool calls the endpoint of a user-defined tool and returns the response body
+func (s *toolSession) callHTTPTool(ctx context.Context, tool *HTTPTool, arguments json.RawMessage) (string, error) {
+	args := map[string]interface{}{}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %v", err)
+	}
+	for _, param := range tool.Parameters {
+		if _, ok := args[param.Name]; param.Required && !ok {
+			return "", fmt.Errorf("missing argument: %s", param.Name)
+		}
+	}
+
+	// Fill URL placeholders, the remaining arguments go in the query or body
+	target := tool.URL
+	for name, value := range args {
+		placeholder := "{{" + name + "}}"
+		if strings.Contains(target, placeholder) {
+			target = strings.ReplaceAll(target, placeholder, url.PathEscape(fmt.Sprint(value)))
+			delete(args, name)
+		}
+	}
+
+	var body io.Reader
+	if tool.Method == http.MethodGet {
+		u, err := url.Parse(target)
+		if err != nil {
+			return "", fmt.Errorf("invalid URL: %v", err)
+		}
+		query := u.Query()
+		for name, value := range args {
+			query.Set(name, fmt.Sprint(value))
+		}
+		u.RawQuery = query.Encode()
+		target = u.String()
+	} else {
+		data, err := json.Marshal(args)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode arguments: %v", err)
+		}
+		body = strings.NewReader(string(data))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, tool.Method, target, body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range tool.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("endpoint returned status %d: %s", resp.StatusCode, truncateRunes(string(data), 200))
+	}
+	return string(data), nil
+}
+
+// truncateRunes cuts text to at most max characters without splitting a character
+func truncateRunes(text string, max int) string {
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	return string([]rune(text)[:max]) + "…"
+}
+
+// Tool methods
+
+// GetToolAuditLog returns the latest tool calls, of one chat or of all chats when chatJID is empty
+func (m *Manager) GetToolAuditLog(chatJID string, limit int) ([]ToolAuditEntry, error) {
+	if m.messageDB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+	return m.messageDB.GetToolAuditLog(chatJID, limit)
+}
@@ -0,0 +1,232 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// TranscriptionConfig controls speech-to-text of incoming voice notes
+type TranscriptionConfig struct {
+	Enabled    bool   `json:"enabled"`
+	BaseURL    string `json:"base_url"` // OpenAI-compatible API, e.g. a local whisper server
+	APIKey     string `json:"api_key"`  // empty uses the OpenAI key when BaseURL is the OpenAI API
+	Model      string `json:"model"`
+	Language   string `json:"language"`    // ISO-639-1 hint, empty detects the language
+	MaxSeconds int    `json:"max_seconds"` // longer voice notes are not transcribed, 0 is unlimited
+}
+
+// GetDefaultTranscriptionConfig returns the default voice note transcription settings
+func GetDefaultTranscriptionConfig() TranscriptionConfig {
+	return TranscriptionConfig{
+		Enabled:    false,
+		BaseURL:    "http://localhost:8000/v1",
+		Model:      "whisper-1",
+		MaxSeconds: 300,
+	}
+}
+
+// Validate checks the transcription settings
+func (c *TranscriptionConfig) Validate() error {
+	if c.MaxSeconds < 0 {
+		return fmt.Errorf("maximum duration cannot be negative")
+	}
+	if !c.Enabled {
+		return nil
+	}
+	if u, err := url.Parse(c.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("base URL must be an http(s) URL")
+	}
+	if c.Model == "" {
+		return fmt.Errorf("model is required")
+	}
+	return nil
+}
+
+// transcriptionClient is used for speech-to-text requests, which take a while on local CPUs
+var transcriptionClient = &http.Client{Timeout: 2 * time.Minute}
+
+// TranscriptUpdate is sent to the frontend when a voice note has been transcribed
+type TranscriptUpdate struct {
+	ChatID    string `json:"chatId"`
+	MessageID string `json:"messageId"`
+	Text      string `json:"text"`
+}
+
+// transcribes reports whether the message is a voice note that should be transcribed first
+func (arm *AutoReplyManager) transcribes(evt *events.Message) bool {
+	audio := evt.Message.GetAudioMessage()
+	if audio == nil || !arm.config.Transcription.Enabled {
+		return false
+	}
+	max := arm.config.Transcription.MaxSeconds
+	return max == 0 || int(audio.GetSeconds()) <= max
+}
+
+// transcribeVoiceNote downloads the audio of the message, transcribes it and stores the
+// transcript on the message. It returns an empty string when the note could not be
+// transcribed or the AI budget is spent.
+func (m *Manager) transcribeVoiceNote(ctx context.Context, replyConfig *AutoReplyConfig, evt *events.Message) string {
+	audio := evt.Message.GetAudioMessage()
+	if audio == nil || m.client == nil {
+		return ""
+	}
+	if m.budgetExceeded(replyConfig) {
+		fmt.Printf("AI budget reached, voice note %s not transcribed\n", evt.Info.ID)
+		return ""
+	}
+
+	data, err := m.client.Download(ctx, audio)
+	if err != nil {
+		m.log.Errorf("Failed to download voice note %s: %v", evt.Info.ID, err)
+		return ""
+	}
+
+	config := replyConfig.Transcription
+	provider := "openai_compatible"
+	apiKey := config.APIKey
+	if strings.TrimSuffix(config.BaseURL, "/") == openAIBaseURL {
+		provider = "openai"
+		if apiKey == "" {
+			apiKey = replyConfig.OpenAIAPIKey
+		}
+	}
+
+	start := time.Now()
+	text, err := transcribeAudio(ctx, transcriptionClient, config, apiKey, data, audio.GetMimetype())
+
+	entry := &UsageEntry{
+		Provider:  provider,
+		Model:     config.Model,
+		Purpose:   "transcription",
+		ChatJID:   evt.Info.Chat.String(),
+		LatencyMs: time.Since(start).Milliseconds(),
+		Outcome:   "ok",
+		Cost:      replyConfig.Usage.audioCost(provider, config.Model, float64(audio.GetSeconds())),
+		CreatedAt: start.Unix(),
+	}
+	if err != nil {
+		entry.Outcome = "error"
+		entry.Error = truncateRunes(err.Error(), 500)
+		entry.Cost = 0
+	}
+	m.usage.record(&replyConfig.Usage, entry)
+
+	if err != nil {
+		m.log.Errorf("Failed to transcribe voice note %s: %v", evt.Info.ID, err)
+		return ""
+	}
+	if text == "" {
+		return ""
+	}
+
+	if m.messageDB != nil {
+		if err := m.messageDB.SetMessageTranscript(evt.Info.ID, text); err != nil {
+			m.log.Errorf("Failed to store transcript: %v", err)
+		}
+	}
+
+	m.emitEvent(ConnectionEvent{
+		Type: "transcript",
+		Payload: TranscriptUpdate{
+			ChatID:    evt.Info.Chat.String(),
+			MessageID: evt.Info.ID,
+			Text:      text,
+		},
+	})
+	return text
+}
+
+// transcribeAudio sends the audio to the /audio/transcriptions endpoint and returns the text
+func transcribeAudio(ctx context.Context, client *http.Client, config TranscriptionConfig, apiKey string, data []byte, mimetype string) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+
+	file, err := form.CreateFormFile("file", "voice"+audioExtension(mimetype))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	fields := map[string]string{
+		"model":           config.Model,
+		"language":        config.Language,
+		"response_format": "json",
+	}
+	for name, value := range fields {
+		if value == "" {
+			continue
+		}
+		if err := form.WriteField(name, value); err != nil {
+			return "", fmt.Errorf("failed to create request: %v", err)
+		}
+	}
+	if err := form.Close(); err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+
+	endpoint := strings.TrimSuffix(config.BaseURL, "/") + "/audio/transcriptions"
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, &body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("network error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Text  string `json:"text"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	decodeErr := json.NewDecoder(resp.Body).Decode(&result)
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return "", fmt.Errorf("invalid API key")
+	case resp.StatusCode != http.StatusOK && result.Error != nil:
+		return "", fmt.Errorf("transcription API error (status %d): %s", resp.StatusCode, result.Error.Message)
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("transcription API error (status %d)", resp.StatusCode)
+	case decodeErr != nil:
+		return "", fmt.Errorf("failed to parse transcription: %v", decodeErr)
+	}
+
+	return strings.TrimSpace(result.Text), nil
+}
+
+// audioExtension returns the file extension servers use to detect the audio format.
+// Voice notes are Opus in an Ogg container.
+func audioExtension(mimetype string) string {
+	mediaType, _, _ := mime.ParseMediaType(mimetype)
+	switch mediaType {
+	case "audio/mpeg":
+		return ".mp3"
+	case "audio/mp4", "audio/aac":
+		return ".m4a"
+	case "audio/wav", "audio/x-wav":
+		return ".wav"
+	case "audio/webm":
+		return ".webm"
+	default:
+		return ".ogg"
+	}
+}
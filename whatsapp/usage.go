@@ -0,0 +1,450 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UsageConfig holds the price table and spending limits of AI calls
+type UsageConfig struct {
+	Prices        []ModelPrice `json:"prices"`
+	DailyBudget   float64      `json:"daily_budget"`   // 0 is unlimited
+	MonthlyBudget float64      `json:"monthly_budget"` // 0 is unlimited
+}
+
+// ModelPrice is the price of a model per million tokens
+type ModelPrice struct {
+	Provider        string  `json:"provider"` // empty matches any provider
+	Model           string  `json:"model"`
+	PromptPrice     float64 `json:"prompt_price"`
+	CompletionPrice float64 `json:"completion_price"`
+	AudioPrice      float64 `json:"audio_price"` // per minute of audio, for speech-to-text models
+}
+
+// GetDefaultUsageConfig returns the default price table without budgets. Prices are
+// list prices at the time of writing and should be checked against the provider.
+func GetDefaultUsageConfig() UsageConfig {
+	return UsageConfig{
+		Prices: []ModelPrice{
+			{Model: "gpt-3.5-turbo", PromptPrice: 0.5, CompletionPrice: 1.5},
+			{Model: "gpt-4o-mini", PromptPrice: 0.15, CompletionPrice: 0.6},
+			{Model: "gpt-4o", PromptPrice: 2.5, CompletionPrice: 10},
+			{Model: "claude-3-5-haiku-latest", PromptPrice: 0.8, CompletionPrice: 4},
+			{Model: "gemini-1.5-flash", PromptPrice: 0.075, CompletionPrice: 0.3},
+			{Model: "text-embedding-3-small", PromptPrice: 0.02},
+			{Provider: "openai", Model: "whisper-1", AudioPrice: 0.006},
+		},
+	}
+}
+
+// Validate checks the price table and budgets
+func (c *UsageConfig) Validate() error {
+	if c.DailyBudget < 0 || c.MonthlyBudget < 0 {
+		return fmt.Errorf("budgets cannot be negative")
+	}
+	for _, price := range c.Prices {
+		if strings.TrimSpace(price.Model) == "" {
+			return fmt.Errorf("price entries need a model")
+		}
+		if price.PromptPrice < 0 || price.CompletionPrice < 0 || price.AudioPrice < 0 {
+			return fmt.Errorf("price of %s cannot be negative", price.Model)
+		}
+	}
+	return nil
+}
+
+// price returns the price entry of the model, preferring an entry for the provider
+// over a provider-less one, or nil if the model has none
+func (c *UsageConfig) price(provider, model string) *ModelPrice {
+	var match *ModelPrice
+	for i := range c.Prices {
+		price := &c.Prices[i]
+		if price.Model != model || (price.Provider != "" && price.Provider != provider) {
+			continue
+		}
+		if match == nil || price.Provider != "" {
+			match = price
+		}
+	}
+	return match
+}
+
+// cost returns the price of the tokens. Models without a price cost nothing.
+func (c *UsageConfig) cost(provider, model string, usage TokenUsage) float64 {
+	match := c.price(provider, model)
+	if match == nil {
+		return 0
+	}
+	return (float64(usage.PromptTokens)*match.PromptPrice + float64(usage.CompletionTokens)*match.CompletionPrice) / 1e6
+}
+
+// audioCost returns the price of transcribing the audio. Models without a price cost nothing.
+func (c *UsageConfig) audioCost(provider, model string, seconds float64) float64 {
+	match := c.price(provider, model)
+	if match == nil {
+		return 0
+	}
+	return seconds / 60 * match.AudioPrice
+}
+
+// TokenUsage counts the tokens of an AI call as reported by the provider
+type TokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// add records tokens reported by a provider. It is a no-op on a nil usage.
+func (u *TokenUsage) add(prompt, completion int) {
+	if u == nil {
+		return
+	}
+	u.PromptTokens += prompt
+	u.CompletionTokens += completion
+}
+
+// UsageEntry is a logged AI call
+type UsageEntry struct {
+	ID               int64   `json:"id"`
+	Provider         string  `json:"provider"`
+	Model            string  `json:"model"`
+	Purpose          string  `json:"purpose"` // e.g. "reply", "test", "embedding" or "transcription"
+	ChatJID          string  `json:"chat_jid"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	LatencyMs        int64   `json:"latency_ms"`
+	Outcome          string  `json:"outcome"` // "ok" or "error"
+	Error            string  `json:"error,omitempty"`
+	Cost             float64 `json:"cost"`
+	CreatedAt        int64   `json:"created_at"`
+}
+
+// UsageGroup sums the AI calls sharing a report key
+type UsageGroup struct {
+	Key              string  `json:"key"`
+	Calls            int     `json:"calls"`
+	Errors           int     `json:"errors"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+	AvgLatencyMs     int64   `json:"avg_latency_ms"`
+}
+
+// UsageReport is the usage of a time range, in total and grouped by day, model, provider or chat
+type UsageReport struct {
+	From    int64        `json:"from"`
+	To      int64        `json:"to"`
+	GroupBy string       `json:"group_by"`
+	Total   UsageGroup   `json:"total"`
+	Groups  []UsageGroup `json:"groups"`
+}
+
+// UsageStatus is the spending of the current day and month against the budgets
+type UsageStatus struct {
+	DailySpent    float64 `json:"daily_spent"`
+	DailyBudget   float64 `json:"daily_budget"`
+	MonthlySpent  float64 `json:"monthly_spent"`
+	MonthlyBudget float64 `json:"monthly_budget"`
+	Exceeded      string  `json:"exceeded,omitempty"` // "daily" or "monthly" when auto-reply is stopped
+}
+
+// BudgetAlert is sent to the frontend when a budget stops AI replies
+type BudgetAlert struct {
+	Period string  `json:"period"` // "daily" or "monthly"
+	Spent  float64 `json:"spent"`
+	Budget float64 `json:"budget"`
+}
+
+// UsageTracker logs AI calls and checks them against the budgets
+type UsageTracker struct {
+	db *MessageDB
+
+	mu       sync.Mutex
+	notified string // budget period already announced, e.g. "daily:2024-05-01"
+}
+
+// NewUsageTracker creates a tracker storing its log in the message database
+func NewUsageTracker(db *MessageDB) *UsageTracker {
+	return &UsageTracker{db: db}
+}
+
+// record prices the call by its tokens, unless the caller priced it, and adds it to the usage log
+func (t *UsageTracker) record(config *UsageConfig, entry *UsageEntry) {
+	if t == nil || t.db == nil {
+		return
+	}
+	if entry.Cost == 0 {
+		entry.Cost = config.cost(entry.Provider, entry.Model, TokenUsage{
+			PromptTokens:     entry.PromptTokens,
+			CompletionTokens: entry.CompletionTokens,
+		})
+	}
+	if err := t.db.AddUsageEntry(entry); err != nil {
+		fmt.Printf("Failed to log AI usage: %v\n", err)
+	}
+}
+
+// status returns the spending of the current day and month
+func (t *UsageTracker) status(config *UsageConfig, now time.Time) (*UsageStatus, error) {
+	status := &UsageStatus{DailyBudget: config.DailyBudget, MonthlyBudget: config.MonthlyBudget}
+	if t == nil || t.db == nil {
+		return status, nil
+	}
+
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	var err error
+	if status.DailySpent, err = t.db.GetUsageCost(day.Unix()); err != nil {
+		return nil, err
+	}
+	if status.MonthlySpent, err = t.db.GetUsageCost(month.Unix()); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case config.MonthlyBudget > 0 && status.MonthlySpent >= config.MonthlyBudget:
+		status.Exceeded = "monthly"
+	case config.DailyBudget > 0 && status.DailySpent >= config.DailyBudget:
+		status.Exceeded = "daily"
+	}
+	return status, nil
+}
+
+// budgetExceeded reports whether a budget stops AI replies. The first time a budget
+// is hit in a period the alert is returned so it can be announced once.
+func (t *UsageTracker) budgetExceeded(config *UsageConfig, now time.Time) (bool, *BudgetAlert) {
+	if config.DailyBudget <= 0 && config.MonthlyBudget <= 0 {
+		return false, nil
+	}
+
+	status, err := t.status(config, now)
+	if err != nil {
+		// Without the log we can't tell, so keep replying
+		fmt.Printf("Failed to check AI budget: %v\n", err)
+		return false, nil
+	}
+	if status.Exceeded == "" {
+		return false, nil
+	}
+
+	alert := &BudgetAlert{Period: status.Exceeded, Spent: status.DailySpent, Budget: status.DailyBudget}
+	period := "daily:" + now.Format("2006-01-02")
+	if status.Exceeded == "monthly" {
+		alert.Spent, alert.Budget = status.MonthlySpent, status.MonthlyBudget
+		period = "monthly:" + now.Format("2006-01")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.notified == period {
+		return true, nil
+	}
+	t.notified = period
+	return true, alert
+}
+
+// meteredProvider logs every call of the wrapped provider
+type meteredProvider struct {
+	Provider
+	model   string
+	chatJID string
+	purpose string
+	record  func(entry *UsageEntry)
+}
+
+func (p *meteredProvider) Generate(ctx context.Context, req GenerateRequest) (string, error) {
+	usage := &TokenUsage{}
+	req.Usage = usage
+	start := time.Now()
+	response, err := p.Provider.Generate(ctx, req)
+	p.log(usage, start, err)
+	return response, err
+}
+
+func (p *meteredProvider) GenerateStream(ctx context.Context, req GenerateRequest, onChunk func(string)) (string, error) {
+	usage := &TokenUsage{}
+	req.Usage = usage
+	start := time.Now()
+	response, err := generateStream(ctx, p.Provider, req, onChunk)
+	p.log(usage, start, err)
+	return response, err
+}
+
+// log adds the outcome of a call to the usage log
+func (p *meteredProvider) log(usage *TokenUsage, start time.Time, err error) {
+	entry := &UsageEntry{
+		Provider:         p.Name(),
+		Model:            p.model,
+		Purpose:          p.purpose,
+		ChatJID:          p.chatJID,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		LatencyMs:        time.Since(start).Milliseconds(),
+		Outcome:          "ok",
+		CreatedAt:        start.Unix(),
+	}
+	if err != nil {
+		entry.Outcome = "error"
+		entry.Error = truncateRunes(err.Error(), 500)
+	}
+	p.record(entry)
+}
+
+// meteredToolCaller logs every call of a provider supporting function calling
+type meteredToolCaller struct {
+	*meteredProvider
+	caller ToolCaller
+}
+
+func (p *meteredToolCaller) GenerateWithTools(ctx context.Context, req GenerateRequest, tools []ToolDefinition) (*ToolResponse, error) {
+	usage := &TokenUsage{}
+	req.Usage = usage
+	start := time.Now()
+	response, err := p.caller.GenerateWithTools(ctx, req, tools)
+	p.log(usage, start, err)
+	return response, err
+}
+
+// meterProvider wraps the provider so its calls are added to the usage log
+func (m *Manager) meterProvider(provider Provider, config *AutoReplyConfig, chatJID, purpose string) Provider {
+	if m.usage == nil {
+		return provider
+	}
+
+	metered := &meteredProvider{
+		Provider: provider,
+		model:    config.modelOf(provider.Name()),
+		chatJID:  chatJID,
+		purpose:  purpose,
+		record: func(entry *UsageEntry) {
+			m.usage.record(&config.Usage, entry)
+		},
+	}
+	if caller, ok := provider.(ToolCaller); ok {
+		return &meteredToolCaller{meteredProvider: metered, caller: caller}
+	}
+	return metered
+}
+
+// meteredEmbedder logs every call of the wrapped embedder and refuses them once a budget is spent
+type meteredEmbedder struct {
+	Embedder
+	provider string
+	chatJID  string
+	exceeded func() bool
+	record   func(entry *UsageEntry)
+}
+
+func (e *meteredEmbedder) Embed(ctx context.Context, texts []string, usage *TokenUsage) ([][]float32, error) {
+	if e.exceeded() {
+		return nil, fmt.Errorf("AI budget reached")
+	}
+
+	callUsage := &TokenUsage{}
+	start := time.Now()
+	vectors, err := e.Embedder.Embed(ctx, texts, callUsage)
+	usage.add(callUsage.PromptTokens, callUsage.CompletionTokens)
+
+	entry := &UsageEntry{
+		Provider:     e.provider,
+		Model:        e.Model(),
+		Purpose:      "embedding",
+		ChatJID:      e.chatJID,
+		PromptTokens: callUsage.PromptTokens,
+		LatencyMs:    time.Since(start).Milliseconds(),
+		Outcome:      "ok",
+		CreatedAt:    start.Unix(),
+	}
+	if err != nil {
+		entry.Outcome = "error"
+		entry.Error = truncateRunes(err.Error(), 500)
+	}
+	e.record(entry)
+	return vectors, err
+}
+
+// meterEmbedder wraps the knowledge base embedder so its calls are added to the usage log
+// and stop when a budget is spent
+func (m *Manager) meterEmbedder(embedder Embedder, config *AutoReplyConfig, chatJID string) Embedder {
+	if m.usage == nil {
+		return embedder
+	}
+	return &meteredEmbedder{
+		Embedder: embedder,
+		provider: config.Knowledge.EmbeddingProvider,
+		chatJID:  chatJID,
+		exceeded: func() bool {
+			return m.budgetExceeded(config)
+		},
+		record: func(entry *UsageEntry) {
+			m.usage.record(&config.Usage, entry)
+		},
+	}
+}
+
+// budgetExceeded reports whether a budget stops AI replies, announcing it once per period
+func (m *Manager) budgetExceeded(config *AutoReplyConfig) bool {
+	exceeded, alert := m.usage.budgetExceeded(&config.Usage, time.Now())
+	if alert != nil {
+		fmt.Printf("AI %s budget of %.2f reached (spent %.2f), auto-reply stopped\n", alert.Period, alert.Budget, alert.Spent)
+		m.emitEvent(ConnectionEvent{
+			Type:    "budget_exceeded",
+			Message: fmt.Sprintf("AI %s budget reached, auto-reply stopped", alert.Period),
+			Payload: *alert,
+		})
+	}
+	return exceeded
+}
+
+// GetUsageReport returns the AI usage between from and to (unix seconds), grouped by
+// "day", "model", "provider" or "chat"
+func (m *Manager) GetUsageReport(from, to int64, groupBy string) (*UsageReport, error) {
+	if m.messageDB == nil {
+		return nil, fmt.Errorf("message database not initialized")
+	}
+	if to <= 0 {
+		to = time.Now().Unix()
+	}
+	if from > to {
+		return nil, fmt.Errorf("report range starts after it ends")
+	}
+
+	report, err := m.messageDB.GetUsageReport(from, to, groupBy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build usage report: %v", err)
+	}
+	return report, nil
+}
+
+// GetUsageStatus returns the spending of the current day and month against the budgets
+func (m *Manager) GetUsageStatus() (*UsageStatus, error) {
+	if m.messageDB == nil {
+		return nil, fmt.Errorf("message database not initialized")
+	}
+
+	config := m.autoReply.GetConfig()
+	status, err := m.usage.status(&config.Usage, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load usage: %v", err)
+	}
+	return status, nil
+}
+
+// GetUsageLog returns the latest AI calls, optionally of one chat
+func (m *Manager) GetUsageLog(chatJID string, limit int) ([]UsageEntry, error) {
+	if m.messageDB == nil {
+		return nil, fmt.Errorf("message database not initialized")
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+
+	entries, err := m.messageDB.GetUsageLog(chatJID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load usage log: %v", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,90 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+)
+
+// VisionConfig controls passing incoming images to vision-capable models
+type VisionConfig struct {
+	Enabled    bool `json:"enabled"`
+	MaxImageKB int  `json:"max_image_kb"` // larger images reach the AI as text only
+}
+
+// GetDefaultVisionConfig returns the default image understanding settings
+func GetDefaultVisionConfig() VisionConfig {
+	return VisionConfig{
+		Enabled:    false,
+		MaxImageKB: 2048,
+	}
+}
+
+// Validate checks the image understanding settings
+func (c *VisionConfig) Validate() error {
+	if c.MaxImageKB < 1 {
+		return fmt.Errorf("maximum image size must be at least 1 KB")
+	}
+	return nil
+}
+
+// maxVisionImages is the number of images of a burst of messages sent along with a reply
+const maxVisionImages = 4
+
+// ChatImage is an image attached to a user turn of the conversation
+type ChatImage struct {
+	MimeType string
+	Data     []byte
+}
+
+// base64 returns the image data base64 encoded
+func (i ChatImage) base64() string {
+	return base64.StdEncoding.EncodeToString(i.Data)
+}
+
+// dataURL returns the image as a data: URL
+func (i ChatImage) dataURL() string {
+	return "data:" + i.MimeType + ";base64," + i.base64()
+}
+
+// attachImages downloads the images of a job and attaches them to the incoming message,
+// the last turn of the conversation. Images over the size limit or failing to download
+// are left out, so the model still answers from the text.
+func (m *Manager) attachImages(ctx context.Context, config VisionConfig, conversation []ChatMessage, images []*waProto.ImageMessage) []ChatMessage {
+	if !config.Enabled || len(images) == 0 || len(conversation) == 0 || m.client == nil {
+		return conversation
+	}
+
+	maxBytes := uint64(config.MaxImageKB) * 1024
+	var attached []ChatImage
+	for _, image := range images {
+		if image.GetFileLength() > maxBytes {
+			m.log.Infof("Image of %d bytes exceeds the vision limit, sending text only", image.GetFileLength())
+			continue
+		}
+
+		downloadCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		data, err := m.client.Download(downloadCtx, image)
+		cancel()
+		if err != nil {
+			m.log.Errorf("Failed to download image for the AI: %v", err)
+			continue
+		}
+
+		mimeType := image.GetMimetype()
+		if mimeType == "" {
+			mimeType = "image/jpeg"
+		}
+		attached = append(attached, ChatImage{MimeType: mimeType, Data: data})
+	}
+	if len(attached) == 0 {
+		return conversation
+	}
+
+	messages := append([]ChatMessage(nil), conversation...)
+	messages[len(messages)-1].Images = attached
+	return messages
+}